@@ -0,0 +1,129 @@
+// casm is a command-line tool for running and inspecting casm hosts.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/lthibault/casm/pkg/config"
+	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var flags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "listen, l",
+		Usage: "listen address, as proto://addr",
+		Value: "tcp://127.0.0.1:0",
+	},
+	cli.StringFlag{
+		Name:   "config",
+		Usage:  "load host configuration from a YAML, TOML or JSON file",
+		EnvVar: "CASM_CONFIG",
+	},
+	cli.BoolFlag{
+		Name:  "verbose, v",
+		Usage: "enable debug logging",
+	},
+}
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "casm"
+	app.Usage = "run and inspect casm hosts"
+	app.Flags = flags
+	app.Commands = []cli.Command{
+		startCmd,
+		connectCmd,
+		ncCmd,
+		serveCmd,
+		pingCmd,
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// signalContext expires on SIGINT.
+func signalContext() context.Context {
+	c, cancel := context.WithCancel(context.Background())
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		<-ch
+		cancel()
+	}()
+
+	return c
+}
+
+// parseListenAddr parses an address of the form proto://addr, and assigns it a
+// fresh peer ID.
+func parseListenAddr(s string) (net.Addr, error) {
+	if strings.Contains(s, "@") {
+		return net.ParseAddr(s)
+	}
+	return net.ParseAddr(fmt.Sprintf("%s@%s", net.New(), s))
+}
+
+// loadConfig returns the config file specified by the global flags, or nil if
+// none was specified.
+func loadConfig(ctx *cli.Context) (*config.Config, error) {
+	if path := ctx.GlobalString("config"); path != "" {
+		return config.Load(path)
+	}
+	return nil, nil
+}
+
+// startHost builds and starts a host from the global flags.  If a config file
+// is specified, it takes precedence over the listen flag.
+func startHost(c context.Context, ctx *cli.Context, opt ...host.Option) (*host.Host, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return startHostFrom(c, ctx, cfg, opt...)
+}
+
+// startHostFrom builds and starts a host from cfg, or from the listen flag if
+// cfg is nil.
+func startHostFrom(c context.Context, ctx *cli.Context, cfg *config.Config, opt ...host.Option) (*host.Host, error) {
+	if cfg != nil {
+		return cfg.Start(c, opt...)
+	}
+
+	a, err := parseListenAddr(ctx.GlobalString("listen"))
+	if err != nil {
+		return nil, err
+	}
+
+	h := host.New(append([]host.Option{
+		host.OptLogger(log.New(log.OptLevel(logLevel(ctx, nil)))),
+	}, opt...)...)
+
+	return h, errors.Wrap(h.Start(c, a), "start host")
+}
+
+// logLevel returns the level from cfg, if it is not nil, or from the verbose
+// flag.
+func logLevel(ctx *cli.Context, cfg *config.Config) log.Level {
+	if cfg != nil {
+		return cfg.LogLevel()
+	}
+
+	if ctx.GlobalBool("verbose") {
+		return log.DebugLevel
+	}
+
+	return log.FatalLevel
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var ncCmd = cli.Command{
+	Name:      "nc",
+	Usage:     "open a stream and pipe it to stdin/stdout",
+	ArgsUsage: "<peer addr> <path>",
+	Action:    nc,
+}
+
+func nc(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return errors.New("expected peer address and path")
+	}
+
+	a, err := net.ParseAddr(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	c := signalContext()
+	h, err := startHost(c, ctx)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.Open(c, a, ctx.Args().Get(1))
+	if err != nil {
+		return errors.Wrap(err, "open stream")
+	}
+	defer s.Close()
+
+	return pipe(c, s, os.Stdin, os.Stdout)
+}
+
+// pipe copies r to the stream, and the stream to w, until the remote host
+// closes the stream or c expires.  The stream is half-closed when r is
+// exhausted, so that the remote host sees EOF.
+func pipe(c context.Context, s host.Stream, r io.Reader, w io.Writer) error {
+	go func() {
+		if _, err := io.Copy(s, r); err == nil {
+			s.CloseWrite()
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(w, s)
+		done <- err
+	}()
+
+	select {
+	case <-c.Done():
+		return nil
+	case err := <-done:
+		return err
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var pingCmd = cli.Command{
+	Name:      "ping",
+	Usage:     "measure round-trip time to a peer",
+	ArgsUsage: "<peer id>@<proto>://<addr>",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "count, c",
+			Usage: "stop after n pings (0 means forever)",
+		},
+	},
+	Action: ping,
+}
+
+func ping(ctx *cli.Context) error {
+	a, err := net.ParseAddr(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	c := signalContext()
+	h, err := startHost(c, ctx)
+	if err != nil {
+		return err
+	}
+
+	ch, err := h.Ping(c, a)
+	if err != nil {
+		return errors.Wrap(err, "ping")
+	}
+
+	var n int
+	var total time.Duration
+	for res := range ch {
+		if res.Err != nil {
+			return res.Err
+		}
+
+		n++
+		total += res.RTT
+		fmt.Printf("%s: seq=%d time=%s\n", host.PathPing, n, res.RTT)
+
+		if n == ctx.Int("count") {
+			break
+		}
+	}
+
+	if n > 0 {
+		fmt.Printf("%d pings, avg %s\n", n, total/time.Duration(n))
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var serveCmd = cli.Command{
+	Name:  "serve",
+	Usage: "serve a path by running a command for each stream",
+	Description: `Each incoming stream on the path is connected to the stdin and stdout of a
+   new instance of the command, inetd-style.  The remote peer's address and the
+   path are passed in the CASM_REMOTE_ADDR and CASM_PATH environment variables.`,
+	ArgsUsage: "<path> <command> [args...]",
+	Action:    serve,
+}
+
+func serve(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		return errors.New("expected path and command")
+	}
+
+	path, argv := ctx.Args().First(), ctx.Args().Tail()
+
+	c := signalContext()
+	h, err := startHost(c, ctx)
+	if err != nil {
+		return err
+	}
+
+	h.Register(path, execHandler(argv))
+
+	fmt.Fprintln(os.Stderr, net.FormatAddr(h.Addr()))
+	<-c.Done()
+	return nil
+}
+
+func execHandler(argv []string) host.Handler {
+	return host.HandlerFunc(func(s host.Stream) {
+		defer s.Close()
+
+		cmd := exec.CommandContext(s.Context(), argv[0], argv[1:]...)
+		cmd.Stdout = s
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"CASM_REMOTE_ADDR="+net.FormatAddr(s.RemoteAddr()),
+			"CASM_PATH="+s.Path())
+
+		// Setting cmd.Stdin = s would cause Wait to block until the remote
+		// host closes the stream, even if the command has exited.
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			log.Get(s.Context()).WithError(err).Error("failed to create stdin pipe")
+			return
+		}
+
+		if err = cmd.Start(); err != nil {
+			log.Get(s.Context()).WithError(err).Warn("command failed")
+			return
+		}
+
+		go func() {
+			io.Copy(stdin, s)
+			stdin.Close()
+		}()
+
+		if err = cmd.Wait(); err != nil {
+			log.Get(s.Context()).WithError(err).Warn("command failed")
+		}
+	})
+}
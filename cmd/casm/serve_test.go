@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id0, id1 := net.New(), net.New()
+	h0, h1 := host.New(opt...), host.New(opt...)
+	a1 := net.NewAddr(id1, "", "inproc", "/serve/"+id1.String())
+	assert.NoError(t, h0.Start(c, net.NewAddr(id0, "", "inproc", "/serve/"+id0.String())))
+	assert.NoError(t, h1.Start(c, a1))
+
+	h1.Register("/cat", execHandler([]string{"cat"}))
+	h1.Register("/true", execHandler([]string{"true"}))
+
+	// pipe returns once the remote command exits
+	run := func(t *testing.T, path string, r io.Reader) (string, error) {
+		s, err := h0.Open(c, a1, path)
+		if !assert.NoError(t, err) {
+			return "", err
+		}
+		defer s.Close()
+
+		cx, cancel := context.WithTimeout(c, time.Second*5)
+		defer cancel()
+
+		var buf bytes.Buffer
+		err = pipe(cx, s, r, &buf)
+		assert.NoError(t, cx.Err(), "pipe did not return")
+		return buf.String(), err
+	}
+
+	t.Run("EOF", func(t *testing.T) {
+		out, err := run(t, "/cat", strings.NewReader("hello\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello\n", out)
+	})
+
+	t.Run("EarlyExit", func(t *testing.T) {
+		r, w := io.Pipe()
+		defer w.Close()
+
+		out, err := run(t, "/true", r)
+		assert.NoError(t, err)
+		assert.Empty(t, out)
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	gonet "net"
+	"net/http"
+	"os"
+
+	"github.com/lthibault/casm/pkg/admin"
+	"github.com/lthibault/casm/pkg/debug"
+	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/metrics"
+	"github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var startCmd = cli.Command{
+	Name:      "start",
+	Usage:     "start a host and run until interrupted",
+	ArgsUsage: "[peer addr...]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "metrics",
+			Usage: "serve Prometheus metrics over HTTP at addr/metrics",
+		},
+		cli.StringFlag{
+			Name:  "debug",
+			Usage: "serve JSON snapshots of the host over HTTP at addr/debug/casm/",
+		},
+		cli.StringFlag{
+			Name:   "admin",
+			Usage:  "serve the admin API on a unix socket at `path`",
+			EnvVar: "CASM_ADMIN_SOCKET",
+		},
+	},
+	Action: start,
+}
+
+var connectCmd = cli.Command{
+	Name:      "connect",
+	Usage:     "check that peers are reachable",
+	ArgsUsage: "<peer addr>...",
+	Action:    connect,
+}
+
+func start(ctx *cli.Context) error {
+	c, shutdown := context.WithCancel(signalContext())
+	defer shutdown()
+
+	var opt []host.Option
+	r := metrics.NewRegistry()
+	if ctx.String("metrics") != "" {
+		host.DescribeMetrics(r)
+		opt = append(opt, host.OptMetrics(r))
+	}
+
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var ll *admin.LevelLogger
+	if ctx.String("admin") != "" {
+		ll = admin.NewLevelLogger(logLevel(ctx, cfg))
+		opt = append(opt, host.OptLogger(ll))
+	}
+
+	h, err := startHostFrom(c, ctx, cfg, opt...)
+	if err != nil {
+		return err
+	}
+
+	if addr := ctx.String("metrics"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", r)
+		if err = serveHTTP(c, addr, mux); err != nil {
+			return errors.Wrap(err, "serve metrics")
+		}
+	}
+
+	if addr := ctx.String("debug"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/casm/", http.StripPrefix("/debug/casm", debug.New(h)))
+		if err = serveHTTP(c, addr, mux); err != nil {
+			return errors.Wrap(err, "serve debug")
+		}
+	}
+
+	if path := ctx.String("admin"); path != "" {
+		srv := admin.NewServer(h,
+			admin.OptLogger(ll.WithLocus("admin")),
+			admin.OptLevelSetter(ll),
+			admin.OptShutdown(shutdown))
+
+		go func() {
+			if err := srv.ListenAndServe(c, path); err != nil {
+				fmt.Fprintln(os.Stderr, errors.Wrap(err, "serve admin"))
+				shutdown()
+			}
+		}()
+	}
+
+	if err = connectAll(c, h, ctx.Args()); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, net.FormatAddr(h.Addr()))
+	<-c.Done()
+	return nil
+}
+
+func connect(ctx *cli.Context) error {
+	if !ctx.Args().Present() {
+		return errors.New("no peers specified")
+	}
+
+	c := signalContext()
+	h, err := startHost(c, ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = connectAll(c, h, ctx.Args()); err != nil {
+		return err
+	}
+
+	for _, s := range ctx.Args() {
+		fmt.Printf("%s: connected\n", s)
+	}
+
+	return nil
+}
+
+// serveHTTP serves h on addr until c expires.
+func serveHTTP(c context.Context, addr string, h http.Handler) error {
+	l, err := gonet.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "listen")
+	}
+
+	srv := &http.Server{Handler: h}
+	go func() {
+		<-c.Done()
+		srv.Close()
+	}()
+	go srv.Serve(l)
+
+	return nil
+}
+
+// connectAll connects to each peer address in turn.
+func connectAll(c context.Context, h *host.Host, addrs []string) error {
+	for _, s := range addrs {
+		a, err := net.ParseAddr(s)
+		if err != nil {
+			return err
+		}
+
+		if err = h.Connect(c, a); err != nil && errors.Cause(err) != host.ErrAlreadyConnected {
+			return errors.Wrapf(err, "connect %s", s)
+		}
+	}
+
+	return nil
+}
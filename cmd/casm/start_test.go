@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestStartBadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "casm")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	app := cli.NewApp()
+	app.Flags = flags
+	app.Commands = []cli.Command{startCmd}
+
+	assert.NotPanics(t, func() {
+		err = app.Run([]string{"casm",
+			"--config", filepath.Join(dir, "missing.yaml"),
+			"start", "--admin", filepath.Join(dir, "admin.sock")})
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+// casmctl controls a running casm host through its admin socket.  The host
+// must have been started with an admin socket, e.g.: casm start --admin <path>.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/admin"
+	"github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var flags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "socket, s",
+		Usage:  "path to the host's admin socket",
+		EnvVar: "CASM_ADMIN_SOCKET",
+	},
+	cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "abort calls after this duration",
+		Value: time.Second * 10,
+	},
+}
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "casmctl"
+	app.Usage = "control a running casm host"
+	app.Flags = flags
+	app.Commands = []cli.Command{
+		addrCmd("connect", "connect to a peer", (*admin.Client).Connect),
+		peerCmd("disconnect", "close the connection to a peer", (*admin.Client).Disconnect),
+		peerCmd("ban", "ban a peer, closing any connection to it", (*admin.Client).Ban),
+		peerCmd("unban", "remove a peer from the ban list", (*admin.Client).Unban),
+		addrCmd("pin", "keep a peer connected", (*admin.Client).Pin),
+		peerCmd("unpin", "stop keeping a peer connected", (*admin.Client).Unpin),
+		peerCmd("evict", "evict a peer from the host's graph vertex", (*admin.Client).Evict),
+		{
+			Name:      "log-level",
+			Usage:     "change the host's log level",
+			ArgsUsage: "<trace|debug|info|warn|error|fatal|none>",
+			Action: run(func(c context.Context, cl *admin.Client, ctx *cli.Context) error {
+				lvl, err := arg(ctx)
+				if err != nil {
+					return err
+				}
+				return cl.SetLogLevel(c, lvl)
+			}),
+		},
+		{
+			Name:  "shutdown",
+			Usage: "shut the host down gracefully",
+			Action: run(func(c context.Context, cl *admin.Client, _ *cli.Context) error {
+				return cl.Shutdown(c)
+			}),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run fn with a client for the admin socket.
+func run(fn func(context.Context, *admin.Client, *cli.Context) error) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		path := ctx.GlobalString("socket")
+		if path == "" {
+			return errors.New("no admin socket specified")
+		}
+
+		c, cancel := context.WithTimeout(context.Background(), ctx.GlobalDuration("timeout"))
+		defer cancel()
+
+		cl := admin.NewClient(path)
+		defer cl.Close()
+
+		return fn(c, cl, ctx)
+	}
+}
+
+// arg returns the command's single argument.
+func arg(ctx *cli.Context) (string, error) {
+	if ctx.NArg() != 1 {
+		return "", errors.Errorf("usage: %s %s", ctx.Command.Name, ctx.Command.ArgsUsage)
+	}
+	return ctx.Args().First(), nil
+}
+
+// addrCmd is a command whose argument is a peer address.
+func addrCmd(name, usage string, fn func(*admin.Client, context.Context, net.Addr) error) cli.Command {
+	return cli.Command{
+		Name:      name,
+		Usage:     usage,
+		ArgsUsage: "<id@proto://addr>",
+		Action: run(func(c context.Context, cl *admin.Client, ctx *cli.Context) error {
+			s, err := arg(ctx)
+			if err != nil {
+				return err
+			}
+
+			a, err := net.ParseAddr(s)
+			if err != nil {
+				return err
+			}
+
+			return fn(cl, c, a)
+		}),
+	}
+}
+
+// peerCmd is a command whose argument is a peer ID.
+func peerCmd(name, usage string, fn func(*admin.Client, context.Context, casm.IDer) error) cli.Command {
+	return cli.Command{
+		Name:      name,
+		Usage:     usage,
+		ArgsUsage: "<peer id>",
+		Action: run(func(c context.Context, cl *admin.Client, ctx *cli.Context) error {
+			s, err := arg(ctx)
+			if err != nil {
+				return err
+			}
+
+			id, err := net.ParsePeerID(s)
+			if err != nil {
+				return err
+			}
+
+			return fn(cl, c, id)
+		}),
+	}
+}
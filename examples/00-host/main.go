@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/msgio"
 	"github.com/lthibault/casm/pkg/net"
 	log "github.com/lthibault/log/pkg"
 )
@@ -22,21 +23,23 @@ func main() {
 	log := log.New(log.OptLevel(log.DebugLevel))
 
 	h0 := host.New(host.OptLogger(log))
-	h0.Register("/echo", host.HandlerFunc(func(s host.Stream) {
-		defer s.Close() // Users SHOULD close streams explicitly
+	h0.Register("/echo", host.HandlerFunc(func(hs host.Stream) {
+		defer hs.Close() // Users SHOULD close streams explicitly
 
-		b := make([]byte, 11)
+		s := msgio.Wrap(hs, 0)
 		for {
 			select {
 			case <-s.Context().Done():
 				return
 			default:
-				n, err := s.Read(b)
+				b, err := s.ReadMsg()
 				if err != nil {
 					log.Fatal(err)
 				}
 
-				if _, err = s.Write(b[:n]); err != nil {
+				err = s.WriteMsg(b)
+				s.ReleaseMsg(b)
+				if err != nil {
 					log.Fatal(err)
 				}
 			}
@@ -58,19 +61,21 @@ func main() {
 		log.Fatal(err)
 	}
 
-	s, err := h1.Open(h0, "/echo")
+	hs, err := h1.Open(c, h0, "/echo")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if _, err = s.Write([]byte("hello world")); err != nil {
+	s := msgio.Wrap(hs, 0)
+	if err = s.WriteMsg([]byte("hello world")); err != nil {
 		log.Fatal(err)
 	}
 
-	b := make([]byte, 11)
-	if _, err = s.Read(b); err != nil {
+	b, err := s.ReadMsg()
+	if err != nil {
 		log.Fatal(err)
 	}
+	defer s.ReleaseMsg(b)
 
 	fmt.Println(string(b))
 }
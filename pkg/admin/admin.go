@@ -0,0 +1,229 @@
+// Package admin implements a control API for operating a live Host.
+//
+// The API is served on a local unix socket, whose file permissions restrict
+// access to the operator.  Calls use the same framing and RPC protocol as
+// casm peers (see package rpc), so the socket simply carries a single RPC
+// session.  The casmctl command is a client for this API.
+package admin
+
+import (
+	"context"
+	"io/ioutil"
+	gonet "net"
+	"os"
+	"path/filepath"
+
+	casm "github.com/lthibault/casm/pkg"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/rpc"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+)
+
+// Path reported by streams on the admin socket.
+const Path = "/casm/admin/1.0.0"
+
+const (
+	methodConnect    = "admin.Connect"
+	methodDisconnect = "admin.Disconnect"
+	methodBan        = "admin.Ban"
+	methodUnban      = "admin.Unban"
+	methodPin        = "admin.Pin"
+	methodUnpin      = "admin.Unpin"
+	methodEvict      = "admin.Evict"
+	methodLogLevel   = "admin.SetLogLevel"
+	methodShutdown   = "admin.Shutdown"
+)
+
+// Host is the subset of *host.Host controlled by the Server.
+type Host interface {
+	Connect(context.Context, casm.IDer) error
+	Disconnect(casm.IDer)
+	Ban(casm.IDer)
+	Unban(casm.IDer)
+	Pin(casm.Addresser)
+	Unpin(casm.IDer)
+}
+
+// Evicter removes edges from a graph vertex.  It is satisfied by
+// graph.Neighborhood.
+type Evicter interface {
+	Evict(casm.IDer)
+}
+
+// Server executes admin calls against a Host.
+type Server struct {
+	log      log.Logger
+	h        Host
+	g        Evicter
+	lvl      LevelSetter
+	shutdown func()
+
+	srv *rpc.Server
+}
+
+// NewServer for the specified Host.  Calls that require an Evicter, a
+// LevelSetter or a shutdown function fail unless the corresponding option is
+// provided.
+func NewServer(h Host, opt ...Option) *Server {
+	s := &Server{h: h}
+	for _, fn := range setDefaultOpts(opt) {
+		fn(s)
+	}
+
+	s.srv = rpc.NewServer(s.log)
+	s.srv.RegisterUnary(methodConnect, s.handleConnect)
+	s.srv.RegisterUnary(methodDisconnect, s.peer(s.h.Disconnect))
+	s.srv.RegisterUnary(methodBan, s.peer(s.h.Ban))
+	s.srv.RegisterUnary(methodUnban, s.peer(s.h.Unban))
+	s.srv.RegisterUnary(methodPin, s.handlePin)
+	s.srv.RegisterUnary(methodUnpin, s.peer(s.h.Unpin))
+	s.srv.RegisterUnary(methodEvict, s.handleEvict)
+	s.srv.RegisterUnary(methodLogLevel, s.handleLogLevel)
+	s.srv.RegisterUnary(methodShutdown, s.handleShutdown)
+
+	return s
+}
+
+// ListenAndServe on a unix socket at the specified path, until c expires.  A
+// stale socket file is removed, and the new one is only accessible to the
+// current user.
+func (s *Server) ListenAndServe(c context.Context, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "remove stale socket")
+	}
+
+	l, err := listenPrivate(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	return s.Serve(c, l)
+}
+
+// listenPrivate binds the socket inside a directory that only the current
+// user can access, and moves it to path once its permissions are restricted.
+// The socket is therefore never reachable by other users, regardless of the
+// process umask.
+func listenPrivate(path string) (*gonet.UnixListener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".casm-admin")
+	if err != nil {
+		return nil, errors.Wrap(err, "create socket dir")
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "admin.sock")
+	l, err := gonet.ListenUnix("unix", &gonet.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, errors.Wrap(err, "listen")
+	}
+	l.SetUnlinkOnClose(false) // the socket is moved; the caller removes it
+
+	if err = os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, errors.Wrap(err, "chmod socket")
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		l.Close()
+		return nil, errors.Wrap(err, "move socket")
+	}
+
+	return l, nil
+}
+
+// Serve connections accepted from l until c expires.  Each connection carries
+// a single RPC session.
+func (s *Server) Serve(c context.Context, l gonet.Listener) error {
+	go func() {
+		<-c.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-c.Done():
+				return nil
+			default:
+				return errors.Wrap(err, "accept")
+			}
+		}
+
+		go s.srv.Serve(newSocketStream(c, conn))
+	}
+}
+
+// peer adapts fn to an rpc.UnaryHandler whose request is a PeerID.
+func (s *Server) peer(fn func(casm.IDer)) rpc.UnaryHandler {
+	return func(_ context.Context, req []byte) ([]byte, error) {
+		id, err := net.ParsePeerID(string(req))
+		if err != nil {
+			return nil, err
+		}
+
+		fn(id)
+		return nil, nil
+	}
+}
+
+func (s *Server) handleConnect(c context.Context, req []byte) ([]byte, error) {
+	a, err := net.ParseAddr(string(req))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, s.h.Connect(c, a)
+}
+
+func (s *Server) handlePin(_ context.Context, req []byte) ([]byte, error) {
+	a, err := net.ParseAddr(string(req))
+	if err != nil {
+		return nil, err
+	}
+
+	s.h.Pin(a)
+	return nil, nil
+}
+
+func (s *Server) handleEvict(c context.Context, req []byte) ([]byte, error) {
+	if s.g == nil {
+		return nil, rpc.Errorf(rpc.CodeUnavailable, "no graph")
+	}
+
+	return s.peer(s.g.Evict)(c, req)
+}
+
+func (s *Server) handleLogLevel(_ context.Context, req []byte) ([]byte, error) {
+	if s.lvl == nil {
+		return nil, rpc.Errorf(rpc.CodeUnavailable, "log level is not adjustable")
+	}
+
+	lvl, err := ParseLevel(string(req))
+	if err != nil {
+		return nil, err
+	}
+
+	s.lvl.SetLevel(lvl)
+	s.log.WithField("level", string(req)).Warn("log level changed")
+	return nil, nil
+}
+
+func (s *Server) handleShutdown(c context.Context, _ []byte) ([]byte, error) {
+	if s.shutdown == nil {
+		return nil, rpc.Errorf(rpc.CodeUnavailable, "shutdown is not supported")
+	}
+
+	s.log.Warn("shutdown requested")
+
+	// The call's context expires once the response has been written, so the
+	// caller sees success before the host goes away.
+	go func() {
+		<-c.Done()
+		s.shutdown()
+	}()
+
+	return nil, nil
+}
@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/rpc"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a Host, Evicter and LevelSetter that records the calls it
+// receives.
+type recorder struct {
+	sync.Mutex
+	calls []string
+	lvl   log.Level
+}
+
+func (r *recorder) record(method string, id casm.IDer) {
+	r.Lock()
+	r.calls = append(r.calls, method+" "+id.ID().String())
+	r.Unlock()
+}
+
+func (r *recorder) Calls() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func (r *recorder) Connect(_ context.Context, id casm.IDer) error {
+	r.record("connect", id)
+	return nil
+}
+
+func (r *recorder) Disconnect(id casm.IDer) { r.record("disconnect", id) }
+func (r *recorder) Ban(id casm.IDer)        { r.record("ban", id) }
+func (r *recorder) Unban(id casm.IDer)      { r.record("unban", id) }
+func (r *recorder) Pin(a casm.Addresser)    { r.record("pin", a.Addr()) }
+func (r *recorder) Unpin(id casm.IDer)      { r.record("unpin", id) }
+func (r *recorder) Evict(id casm.IDer)      { r.record("evict", id) }
+
+func (r *recorder) SetLevel(lvl log.Level) {
+	r.Lock()
+	r.lvl = lvl
+	r.Unlock()
+}
+
+func (r *recorder) Level() log.Level {
+	r.Lock()
+	defer r.Unlock()
+	return r.lvl
+}
+
+func TestAdmin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "casm-admin")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := new(recorder)
+	shutdown := make(chan struct{})
+	srv := NewServer(rec,
+		OptLogger(log.New(log.OptLevel(log.NullLevel))),
+		OptGraph(rec),
+		OptLevelSetter(rec),
+		OptShutdown(func() { close(shutdown) }))
+
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe(c, path) }()
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, time.Millisecond)
+
+	cl := NewClient(path)
+	defer cl.Close()
+
+	a := net.NewAddr(net.New(), "tcp", "tcp", "127.0.0.1:2020")
+	id := a.ID().String()
+
+	t.Run("Permissions", func(t *testing.T) {
+		info, err := os.Stat(path)
+		if assert.NoError(t, err) {
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		}
+
+		fs, err := ioutil.ReadDir(dir)
+		if assert.NoError(t, err) {
+			assert.Len(t, fs, 1, "temporary socket directory was not removed")
+		}
+	})
+
+	t.Run("Peers", func(t *testing.T) {
+		assert.NoError(t, cl.Connect(c, a))
+		assert.NoError(t, cl.Disconnect(c, a))
+		assert.NoError(t, cl.Ban(c, a))
+		assert.NoError(t, cl.Unban(c, a))
+		assert.NoError(t, cl.Pin(c, a))
+		assert.NoError(t, cl.Unpin(c, a))
+		assert.NoError(t, cl.Evict(c, a))
+
+		assert.Equal(t, []string{
+			"connect " + id,
+			"disconnect " + id,
+			"ban " + id,
+			"unban " + id,
+			"pin " + id,
+			"unpin " + id,
+			"evict " + id,
+		}, rec.Calls())
+	})
+
+	t.Run("SetLogLevel", func(t *testing.T) {
+		assert.NoError(t, cl.SetLogLevel(c, "debug"))
+		assert.Equal(t, log.DebugLevel, rec.Level())
+
+		err := cl.SetLogLevel(c, "loud")
+		if assert.Error(t, err) {
+			assert.IsType(t, &rpc.Error{}, errors.Cause(err))
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		other := filepath.Join(dir, "other.sock")
+		go NewServer(rec, OptLogger(log.New(log.OptLevel(log.NullLevel)))).
+			ListenAndServe(c, other)
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(other)
+			return err == nil
+		}, time.Second, time.Millisecond)
+
+		cl := NewClient(other)
+		defer cl.Close()
+
+		err := cl.Evict(c, a)
+		if assert.Error(t, err) {
+			assert.Equal(t, rpc.CodeUnavailable, errors.Cause(err).(*rpc.Error).Code)
+		}
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		assert.NoError(t, cl.Shutdown(c))
+		select {
+		case <-shutdown:
+		case <-time.After(time.Second):
+			t.Error("shutdown function was not called")
+		}
+	})
+
+	cancel()
+	assert.NoError(t, <-served)
+}
+
+func TestLevelLogger(t *testing.T) {
+	l := NewLevelLogger(log.InfoLevel)
+	derived := l.WithField("foo", "bar").(*LevelLogger)
+
+	l.SetLevel(log.DebugLevel)
+	assert.Equal(t, log.DebugLevel, derived.Level(), "derived loggers should share level")
+
+	lvl, err := ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, log.WarnLevel, lvl)
+
+	_, err = ParseLevel("loud")
+	assert.Error(t, err)
+}
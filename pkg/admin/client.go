@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"context"
+	gonet "net"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/rpc"
+	log "github.com/lthibault/log/pkg"
+)
+
+// Client issues admin calls over a unix socket.
+type Client struct {
+	a  net.Addr
+	cl *rpc.Client
+}
+
+// NewClient for the admin socket at the specified path.  The socket is dialed
+// on the first call.
+func NewClient(path string) *Client {
+	return &Client{
+		a:  net.NewAddr(0, "unix", "unix", path),
+		cl: rpc.NewClientForPath(log.New(log.OptLevel(log.NullLevel)), dialer{}, Path),
+	}
+}
+
+// Close the connection to the socket.
+func (c *Client) Close() error { return c.cl.Close() }
+
+// Connect the host to a peer.
+func (c *Client) Connect(ctx context.Context, a net.Addr) error {
+	return c.call(ctx, methodConnect, net.FormatAddr(a))
+}
+
+// Disconnect the host from a peer.
+func (c *Client) Disconnect(ctx context.Context, id casm.IDer) error {
+	return c.call(ctx, methodDisconnect, id.ID().String())
+}
+
+// Ban a peer.
+func (c *Client) Ban(ctx context.Context, id casm.IDer) error {
+	return c.call(ctx, methodBan, id.ID().String())
+}
+
+// Unban a peer.
+func (c *Client) Unban(ctx context.Context, id casm.IDer) error {
+	return c.call(ctx, methodUnban, id.ID().String())
+}
+
+// Pin a peer, keeping it connected.
+func (c *Client) Pin(ctx context.Context, a net.Addr) error {
+	return c.call(ctx, methodPin, net.FormatAddr(a))
+}
+
+// Unpin a peer.
+func (c *Client) Unpin(ctx context.Context, id casm.IDer) error {
+	return c.call(ctx, methodUnpin, id.ID().String())
+}
+
+// Evict a peer from the host's graph vertex.
+func (c *Client) Evict(ctx context.Context, id casm.IDer) error {
+	return c.call(ctx, methodEvict, id.ID().String())
+}
+
+// SetLogLevel changes the host's log level, e.g.: "debug".
+func (c *Client) SetLogLevel(ctx context.Context, lvl string) error {
+	return c.call(ctx, methodLogLevel, lvl)
+}
+
+// Shutdown the host gracefully.
+func (c *Client) Shutdown(ctx context.Context) error {
+	return c.call(ctx, methodShutdown, "")
+}
+
+func (c *Client) call(ctx context.Context, method, req string) error {
+	_, err := c.cl.Call(ctx, c.a, method, []byte(req))
+	return err
+}
+
+// dialer satisfies rpc.Opener by dialing the admin socket.
+type dialer struct{}
+
+func (dialer) Open(c context.Context, a casm.Addresser, _ string) (host.Stream, error) {
+	var d gonet.Dialer
+	conn, err := d.DialContext(c, "unix", a.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+
+	return newSocketStream(context.Background(), conn), nil
+}
@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"strings"
+	"sync/atomic"
+
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+)
+
+var levels = map[string]log.Level{
+	"trace": log.TraceLevel,
+	"debug": log.DebugLevel,
+	"info":  log.InfoLevel,
+	"warn":  log.WarnLevel,
+	"error": log.ErrorLevel,
+	"fatal": log.FatalLevel,
+	"none":  log.NullLevel,
+}
+
+var allLevels = []log.Level{
+	log.NullLevel,
+	log.DebugLevel,
+	log.InfoLevel,
+	log.WarnLevel,
+	log.ErrorLevel,
+	log.FatalLevel,
+	log.TraceLevel,
+}
+
+// ParseLevel parses a level name, e.g.: "debug".
+func ParseLevel(s string) (log.Level, error) {
+	if lvl, ok := levels[strings.ToLower(s)]; ok {
+		return lvl, nil
+	}
+	return 0, errors.Errorf("invalid log level %q", s)
+}
+
+// LevelSetter changes the level of a logger at runtime.
+type LevelSetter interface {
+	SetLevel(log.Level)
+}
+
+// LevelLogger is a log.Logger whose level can be changed at runtime.  Loggers
+// derived from it with WithField, WithError, etc. share its level.
+type LevelLogger struct {
+	lvl *int32
+	ls  map[log.Level]log.Logger
+}
+
+// NewLevelLogger returns a LevelLogger at the specified level.
+func NewLevelLogger(lvl log.Level) *LevelLogger {
+	l := &LevelLogger{lvl: new(int32), ls: make(map[log.Level]log.Logger, len(allLevels))}
+	for _, v := range allLevels {
+		l.ls[v] = log.New(log.OptLevel(v))
+	}
+	l.SetLevel(lvl)
+	return l
+}
+
+// SetLevel satisfies LevelSetter.
+func (l *LevelLogger) SetLevel(lvl log.Level) { atomic.StoreInt32(l.lvl, int32(lvl)) }
+
+// Level returns the current level.
+func (l *LevelLogger) Level() log.Level { return log.Level(atomic.LoadInt32(l.lvl)) }
+
+func (l *LevelLogger) get() log.Logger { return l.ls[l.Level()] }
+
+func (l *LevelLogger) derive(fn func(log.Logger) log.Logger) log.Logger {
+	d := &LevelLogger{lvl: l.lvl, ls: make(map[log.Level]log.Logger, len(l.ls))}
+	for lvl, ll := range l.ls {
+		d.ls[lvl] = fn(ll)
+	}
+	return d
+}
+
+// WithLocus satisfies log.Logger.
+func (l *LevelLogger) WithLocus(s string) log.Logger {
+	return l.derive(func(ll log.Logger) log.Logger { return ll.WithLocus(s) })
+}
+
+// WithField satisfies log.Logger.
+func (l *LevelLogger) WithField(k string, v interface{}) log.Logger {
+	return l.derive(func(ll log.Logger) log.Logger { return ll.WithField(k, v) })
+}
+
+// WithFields satisfies log.Logger.
+func (l *LevelLogger) WithFields(f log.F) log.Logger {
+	return l.derive(func(ll log.Logger) log.Logger { return ll.WithFields(f) })
+}
+
+// WithError satisfies log.Logger.
+func (l *LevelLogger) WithError(err error) log.Logger {
+	return l.derive(func(ll log.Logger) log.Logger { return ll.WithError(err) })
+}
+
+// Debug satisfies log.Logger.
+func (l *LevelLogger) Debug(v ...interface{}) { l.get().Debug(v...) }
+
+// Debugf satisfies log.Logger.
+func (l *LevelLogger) Debugf(f string, v ...interface{}) { l.get().Debugf(f, v...) }
+
+// Info satisfies log.Logger.
+func (l *LevelLogger) Info(v ...interface{}) { l.get().Info(v...) }
+
+// Infof satisfies log.Logger.
+func (l *LevelLogger) Infof(f string, v ...interface{}) { l.get().Infof(f, v...) }
+
+// Warn satisfies log.Logger.
+func (l *LevelLogger) Warn(v ...interface{}) { l.get().Warn(v...) }
+
+// Warnf satisfies log.Logger.
+func (l *LevelLogger) Warnf(f string, v ...interface{}) { l.get().Warnf(f, v...) }
+
+// Error satisfies log.Logger.
+func (l *LevelLogger) Error(v ...interface{}) { l.get().Error(v...) }
+
+// Errorf satisfies log.Logger.
+func (l *LevelLogger) Errorf(f string, v ...interface{}) { l.get().Errorf(f, v...) }
+
+// Fatal satisfies log.Logger.
+func (l *LevelLogger) Fatal(v ...interface{}) { l.get().Fatal(v...) }
+
+// Fatalf satisfies log.Logger.
+func (l *LevelLogger) Fatalf(f string, v ...interface{}) { l.get().Fatalf(f, v...) }
@@ -0,0 +1,51 @@
+package admin
+
+import log "github.com/lthibault/log/pkg"
+
+// Option for Server.
+type Option func(*Server) (prev Option)
+
+func setDefaultOpts(opt []Option) []Option {
+	return append([]Option{OptLogger(nil)}, opt...)
+}
+
+// OptLogger sets the logger.
+func OptLogger(l log.Logger) Option {
+	if l == nil {
+		l = log.New()
+	}
+
+	return func(s *Server) (prev Option) {
+		prev = OptLogger(s.log)
+		s.log = l
+		return
+	}
+}
+
+// OptGraph enables the Evict call.
+func OptGraph(g Evicter) Option {
+	return func(s *Server) (prev Option) {
+		prev = OptGraph(s.g)
+		s.g = g
+		return
+	}
+}
+
+// OptLevelSetter enables the SetLogLevel call.
+func OptLevelSetter(l LevelSetter) Option {
+	return func(s *Server) (prev Option) {
+		prev = OptLevelSetter(s.lvl)
+		s.lvl = l
+		return
+	}
+}
+
+// OptShutdown enables the Shutdown call.  The function is called once the
+// caller has been answered, and should initiate a graceful shutdown.
+func OptShutdown(fn func()) Option {
+	return func(s *Server) (prev Option) {
+		prev = OptShutdown(s.shutdown)
+		s.shutdown = fn
+		return
+	}
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"context"
+	gonet "net"
+
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+// socketStream adapts a connection on the admin socket to host.Stream, so that
+// it can be served by an rpc.Server.
+type socketStream struct {
+	gonet.Conn
+	c      context.Context
+	cancel context.CancelFunc
+	local  net.Addr
+	remote net.Addr
+}
+
+func newSocketStream(c context.Context, conn gonet.Conn) *socketStream {
+	s := &socketStream{
+		Conn:   conn,
+		local:  socketAddr(conn.LocalAddr()),
+		remote: socketAddr(conn.RemoteAddr()),
+	}
+	s.c, s.cancel = context.WithCancel(c)
+	return s
+}
+
+func socketAddr(a gonet.Addr) net.Addr {
+	if a == nil {
+		return net.NewAddr(0, "unix", "unix", "")
+	}
+	return net.NewAddr(0, a.Network(), a.Network(), a.String())
+}
+
+func (s *socketStream) Path() string              { return Path }
+func (s *socketStream) Version() (v host.Version) { return }
+func (s *socketStream) Param(string) string       { return "" }
+func (s *socketStream) Header() host.Header       { return nil }
+func (s *socketStream) Context() context.Context  { return s.c }
+func (s *socketStream) StreamID() uint32          { return 0 }
+func (s *socketStream) LocalAddr() net.Addr       { return s.local }
+func (s *socketStream) RemoteAddr() net.Addr      { return s.remote }
+
+func (s *socketStream) Close() error {
+	s.cancel()
+	return s.Conn.Close()
+}
+
+func (s *socketStream) CloseWrite() error {
+	if cw, ok := s.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return s.Close()
+}
+
+// Reset closes the connection.  The admin socket carries a single stream, so
+// there is no framing with which to send the code.
+func (s *socketStream) Reset(uint32) error { return s.Close() }
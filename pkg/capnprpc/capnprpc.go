@@ -0,0 +1,87 @@
+// Package capnprpc serves Cap'n Proto interfaces on host paths.
+//
+// Each stream opened on an exported path carries a single Cap'n Proto RPC
+// connection, whose bootstrap capability is the exported interface.  Promise
+// pipelining and capability passing work as they would over any other
+// transport.
+//
+// A typed client is obtained by wrapping the result of Bootstrap with the
+// generated client type:
+//
+//	c, err := capnprpc.Bootstrap(ctx, h, peer, "/myapp/foo/1.0.0")
+//	foo := myapp.Foo{Client: c}
+package capnprpc
+
+import (
+	"context"
+
+	casm "github.com/lthibault/casm/pkg"
+	host "github.com/lthibault/casm/pkg/host"
+	"github.com/pkg/errors"
+	capnp "zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
+)
+
+// Registerer binds handlers to paths.  It is satisfied by host.Host and
+// *host.Mux.
+type Registerer interface {
+	Register(string, host.Handler)
+}
+
+// Opener opens streams to remote peers.  It is satisfied by host.Host.
+type Opener interface {
+	Open(context.Context, casm.Addresser, string) (host.Stream, error)
+}
+
+// Export the capability on the path.  Each incoming stream is served by a
+// separate RPC connection, which is torn down when the stream closes.
+func Export(r Registerer, path string, c capnp.Client) {
+	r.Register(path, Handler(c))
+}
+
+// Handler serves the capability as the bootstrap interface of each stream.
+func Handler(c capnp.Client) host.Handler {
+	return host.HandlerFunc(func(s host.Stream) {
+		defer s.Close()
+
+		conn := rpc.NewConn(rpc.StreamTransport(s), rpc.MainInterface(c))
+		defer conn.Close()
+
+		select {
+		case <-s.Context().Done():
+		case <-waitConn(conn):
+		}
+	})
+}
+
+func waitConn(conn *rpc.Conn) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+// Bootstrap opens a stream to the path on the remote peer, and returns the
+// capability exported there.  Closing the returned client closes the stream.
+func Bootstrap(c context.Context, o Opener, a casm.Addresser, path string) (capnp.Client, error) {
+	s, err := o.Open(c, a, path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open stream")
+	}
+
+	conn := rpc.NewConn(rpc.StreamTransport(s))
+	return client{Client: conn.Bootstrap(c), conn: conn}, nil
+}
+
+// client releases the underlying connection when closed.
+type client struct {
+	capnp.Client
+	conn *rpc.Conn
+}
+
+func (c client) Close() error {
+	c.Client.Close()
+	return c.conn.Close()
+}
@@ -0,0 +1,93 @@
+package capnprpc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lthibault/casm/pkg/capnprpc"
+	"github.com/lthibault/casm/pkg/capnprpc/internal/echotest"
+	host "github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+const path = "/test/echo/1.0.0"
+
+type echoServer struct{}
+
+func (echoServer) Echo(call echotest.Echo_echo) error {
+	s, err := call.Params.Text()
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetText(strings.ToUpper(s))
+}
+
+// startHosts returns two started hosts on a shared transport, the second of
+// which exports an echotest.Echo on path.  The hosts are not connected; the
+// first dials the second when it opens a stream.
+func startHosts(c context.Context, name string) (h0, h1 *host.Host, err error) {
+	transpt := net.NewTransport(inproc.New())
+	opt := []host.Option{
+		host.OptTransport(transpt),
+		host.OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	h0, h1 = host.New(opt...), host.New(opt...)
+	if err = h0.Start(c, net.NewAddr(net.New(), "", "inproc", name+"/h0")); err != nil {
+		return
+	}
+	if err = h1.Start(c, net.NewAddr(net.New(), "", "inproc", name+"/h1")); err != nil {
+		return
+	}
+
+	capnprpc.Export(h1, path, echotest.Echo_ServerToClient(echoServer{}).Client)
+	return
+}
+
+func TestRoundTrip(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1, err := startHosts(c, "/capnprpc/"+net.New().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	client, err := capnprpc.Bootstrap(c, h0, h1.Addr(), path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	echo := echotest.Echo{Client: client}
+
+	t.Run("Call", func(t *testing.T) {
+		res, err := echo.Echo(c, func(p echotest.Msg) error {
+			return p.SetText("hello")
+		}).Struct()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		s, err := res.Text()
+		assert.NoError(t, err)
+		assert.Equal(t, "HELLO", s)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		assert.NoError(t, echo.Client.Close())
+
+		_, err := echo.Echo(c, func(p echotest.Msg) error {
+			return p.SetText("hello")
+		}).Struct()
+		assert.Error(t, err)
+	})
+
+	t.Run("NoHandler", func(t *testing.T) {
+		_, err := capnprpc.Bootstrap(c, h0, h1.Addr(), "/test/missing/1.0.0")
+		assert.Error(t, err)
+	})
+}
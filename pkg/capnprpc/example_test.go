@@ -0,0 +1,40 @@
+package capnprpc_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lthibault/casm/pkg/capnprpc"
+	"github.com/lthibault/casm/pkg/capnprpc/internal/echotest"
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+func ExampleBootstrap() {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1, err := startHosts(c, "/example/"+net.New().String())
+	if err != nil {
+		panic(err)
+	}
+
+	client, err := capnprpc.Bootstrap(c, h0, h1.Addr(), path)
+	if err != nil {
+		panic(err)
+	}
+	defer client.Close()
+
+	// wrap the capability in the generated client type
+	echo := echotest.Echo{Client: client}
+
+	res, err := echo.Echo(c, func(p echotest.Msg) error {
+		return p.SetText("hello")
+	}).Struct()
+	if err != nil {
+		panic(err)
+	}
+
+	s, _ := res.Text()
+	fmt.Println(s)
+	// Output: HELLO
+}
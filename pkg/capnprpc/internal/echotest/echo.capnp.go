@@ -0,0 +1,125 @@
+// Package echotest provides a Cap'n Proto interface for testing capnprpc.
+//
+// The bindings below follow capnpc-go's output for echo.capnp.  The embedded
+// schema table is omitted, so the types do not implement fmt.Stringer.
+package echotest
+
+import (
+	context "context"
+
+	capnp "zombiezen.com/go/capnproto2"
+	server "zombiezen.com/go/capnproto2/server"
+)
+
+type Msg struct{ capnp.Struct }
+
+// Msg_TypeID is the unique identifier for the type Msg.
+const Msg_TypeID = 0x9f29cec1bb1fc673
+
+func NewMsg(s *capnp.Segment) (Msg, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Msg{st}, err
+}
+
+func NewRootMsg(s *capnp.Segment) (Msg, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Msg{st}, err
+}
+
+func ReadRootMsg(msg *capnp.Message) (Msg, error) {
+	root, err := msg.RootPtr()
+	return Msg{root.Struct()}, err
+}
+
+func (s Msg) Text() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s Msg) HasText() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Msg) TextBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s Msg) SetText(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+// Msg_Promise is a wrapper for a Msg promised by a client call.
+type Msg_Promise struct{ *capnp.Pipeline }
+
+func (p Msg_Promise) Struct() (Msg, error) {
+	s, err := p.Pipeline.Struct()
+	return Msg{s}, err
+}
+
+// Echo is a test interface for capnprpc
+type Echo struct{ Client capnp.Client }
+
+// Echo_TypeID is the unique identifier for the type Echo.
+const Echo_TypeID = 0x92d69c6beff006c1
+
+func (c Echo) Echo(ctx context.Context, params func(Msg) error, opts ...capnp.CallOption) Msg_Promise {
+	if c.Client == nil {
+		return Msg_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0x92d69c6beff006c1,
+			MethodID:      0,
+			InterfaceName: "echo.capnp:Echo",
+			MethodName:    "echo",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Msg{Struct: s}) }
+	}
+	return Msg_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+
+type Echo_Server interface {
+	Echo(Echo_echo) error
+}
+
+func Echo_ServerToClient(s Echo_Server) Echo {
+	c, _ := s.(server.Closer)
+	return Echo{Client: server.New(Echo_Methods(nil, s), s, c)}
+}
+
+func Echo_Methods(methods []server.Method, s Echo_Server) []server.Method {
+	if cap(methods) == 0 {
+		methods = make([]server.Method, 0, 1)
+	}
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0x92d69c6beff006c1,
+			MethodID:      0,
+			InterfaceName: "echo.capnp:Echo",
+			MethodName:    "echo",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Echo_echo{c, opts, Msg{Struct: p}, Msg{Struct: r}}
+			return s.Echo(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	return methods
+}
+
+// Echo_echo holds the arguments for a server call to Echo.echo.
+type Echo_echo struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Msg
+	Results Msg
+}
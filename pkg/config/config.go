@@ -0,0 +1,162 @@
+// Package config builds Hosts from declarative configuration files.
+//
+// Configuration may be written in YAML, TOML or JSON; the format is inferred
+// from the file extension.  Any field may be overridden by an environment
+// variable (see Env).
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Format of a configuration file.
+type Format string
+
+// Supported formats
+const (
+	YAML Format = "yaml"
+	TOML Format = "toml"
+	JSON Format = "json"
+)
+
+// FormatOf infers the format from a file's extension.
+func FormatOf(path string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	case ".json":
+		return JSON, nil
+	default:
+		return "", errors.Errorf("unsupported config format %q", ext)
+	}
+}
+
+// Config describes a Host.
+type Config struct {
+	// Listen address, as proto://addr.  Supported protocols are "tcp" and
+	// "inproc".
+	Listen string `json:"listen" yaml:"listen" toml:"listen"`
+
+	// Identity is the path of the file holding the Host's peer ID.  It is
+	// created with a random ID if it does not exist.  If empty, a random ID is
+	// used on each start.
+	Identity string `json:"identity" yaml:"identity" toml:"identity"`
+
+	// Bootstrap peers to connect to on start, as <peer id>@<proto>://<addr>.
+	Bootstrap []string `json:"bootstrap" yaml:"bootstrap" toml:"bootstrap"`
+
+	Limits Limits `json:"limits" yaml:"limits" toml:"limits"`
+	Log    Log    `json:"log" yaml:"log" toml:"log"`
+}
+
+// Limits on the Host's resource usage.
+type Limits struct {
+	// MaxInboundStreams that may be handled concurrently.  Zero means no
+	// limit.
+	MaxInboundStreams int `json:"max_inbound_streams" yaml:"max_inbound_streams" toml:"max_inbound_streams"`
+
+	// ReconnectMin and ReconnectMax bound the delay between attempts to
+	// reconnect to pinned peers.  Unset bounds take the host's defaults.
+	ReconnectMin Duration `json:"reconnect_min" yaml:"reconnect_min" toml:"reconnect_min"`
+	ReconnectMax Duration `json:"reconnect_max" yaml:"reconnect_max" toml:"reconnect_max"`
+}
+
+// Log configuration.
+type Log struct {
+	// Level is one of "trace", "debug", "info", "warn", "error", "fatal" or
+	// "none".  Defaults to "info".
+	Level string `json:"level" yaml:"level" toml:"level"`
+}
+
+// Duration is a time.Duration that is written as a string, e.g. "1m30s".
+type Duration time.Duration
+
+// UnmarshalText satisfies encoding.TextUnmarshaler (JSON and TOML).
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	*d = Duration(v)
+	return err
+}
+
+// UnmarshalYAML satisfies yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+// MarshalText satisfies encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load a configuration file, apply environment overrides, and validate the
+// result.
+func Load(path string) (*Config, error) {
+	f, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cfg, err := Parse(r, f)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	if err = cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Parse a configuration.  Environment overrides are not applied, and the
+// result is not validated.
+func Parse(r io.Reader, f Format) (*Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(Config)
+	switch f {
+	case YAML:
+		err = yaml.UnmarshalStrict(b, cfg)
+	case TOML:
+		var md toml.MetaData
+		if md, err = toml.Decode(string(b), cfg); err == nil {
+			if keys := md.Undecoded(); len(keys) > 0 {
+				err = errors.Errorf("unknown field %q", keys[0].String())
+			}
+		}
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		err = errors.Errorf("unsupported config format %q", f)
+	}
+
+	return cfg, errors.Wrapf(err, "parse %s", f)
+}
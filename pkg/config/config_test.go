@@ -0,0 +1,224 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	yamlConfig = `
+listen: tcp://0.0.0.0:9020
+bootstrap: ["0000000000000001@tcp://127.0.0.1:9020"]
+limits:
+  max_inbound_streams: 64
+  reconnect_min: 1s
+  reconnect_max: 30s
+log:
+  level: debug
+`
+
+	tomlConfig = `
+listen = "tcp://0.0.0.0:9020"
+bootstrap = ["0000000000000001@tcp://127.0.0.1:9020"]
+
+[limits]
+max_inbound_streams = 64
+reconnect_min = "1s"
+reconnect_max = "30s"
+
+[log]
+level = "debug"
+`
+
+	jsonConfig = `{
+	"listen": "tcp://0.0.0.0:9020",
+	"bootstrap": ["0000000000000001@tcp://127.0.0.1:9020"],
+	"limits": {
+		"max_inbound_streams": 64,
+		"reconnect_min": "1s",
+		"reconnect_max": "30s"
+	},
+	"log": {"level": "debug"}
+}`
+)
+
+func TestParse(t *testing.T) {
+	want := &Config{
+		Listen:    "tcp://0.0.0.0:9020",
+		Bootstrap: []string{"0000000000000001@tcp://127.0.0.1:9020"},
+		Limits: Limits{
+			MaxInboundStreams: 64,
+			ReconnectMin:      Duration(time.Second),
+			ReconnectMax:      Duration(time.Second * 30),
+		},
+		Log: Log{Level: "debug"},
+	}
+
+	for f, src := range map[Format]string{
+		YAML: yamlConfig,
+		TOML: tomlConfig,
+		JSON: jsonConfig,
+	} {
+		t.Run(string(f), func(t *testing.T) {
+			cfg, err := Parse(strings.NewReader(src), f)
+			assert.NoError(t, err)
+			assert.Equal(t, want, cfg)
+			assert.NoError(t, cfg.Validate())
+		})
+	}
+
+	t.Run("UnknownField", func(t *testing.T) {
+		for f, src := range map[Format]string{
+			YAML: "listne: []",
+			TOML: "listne = []",
+			JSON: `{"listne": []}`,
+		} {
+			_, err := Parse(strings.NewReader(src), f)
+			assert.Error(t, err, f)
+		}
+	})
+}
+
+func TestFormatOf(t *testing.T) {
+	for path, want := range map[string]Format{
+		"casm.yml":  YAML,
+		"casm.YAML": YAML,
+		"casm.toml": TOML,
+		"casm.json": JSON,
+	} {
+		f, err := FormatOf(path)
+		assert.NoError(t, err)
+		assert.Equal(t, want, f)
+	}
+
+	_, err := FormatOf("casm.ini")
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Config{
+		Listen:    "udp://127.0.0.1:0",
+		Bootstrap: []string{"nope"},
+		Limits: Limits{
+			MaxInboundStreams: -1,
+			ReconnectMin:      Duration(time.Minute),
+			ReconnectMax:      Duration(time.Second),
+		},
+		Log: Log{Level: "loud"},
+	}
+
+	err := cfg.Validate()
+	assert.IsType(t, ValidationError{}, err)
+
+	var fields []string
+	for _, fe := range err.(ValidationError) {
+		fields = append(fields, fe.Field)
+	}
+
+	assert.Equal(t, []string{
+		"listen",
+		"bootstrap[0]",
+		"limits.max_inbound_streams",
+		"limits",
+		"log.level",
+	}, fields)
+
+	t.Run("BootstrapTransport", func(t *testing.T) {
+		cfg := Config{
+			Listen: "tcp://127.0.0.1:0",
+			Bootstrap: []string{
+				"0000000000000001@tcp://127.0.0.1:9020",
+				"0000000000000002@inproc:///peer",
+			},
+		}
+
+		err := cfg.Validate()
+		if assert.IsType(t, ValidationError{}, err) {
+			assert.Len(t, err.(ValidationError), 1)
+			assert.Equal(t, "bootstrap[1]", err.(ValidationError)[0].Field)
+		}
+	})
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"CASM_LISTEN":              "tcp://0.0.0.0:9020",
+		"CASM_BOOTSTRAP":           "0000000000000001@tcp://a:1, 0000000000000002@tcp://b:2",
+		"CASM_MAX_INBOUND_STREAMS": "8",
+		"CASM_RECONNECT_MAX":       "1m",
+		"CASM_LOG_LEVEL":           "warn",
+	}
+	lookup := func(k string) (v string, ok bool) {
+		v, ok = env[k]
+		return
+	}
+
+	cfg := Config{Listen: "inproc:///x", Log: Log{Level: "debug"}}
+	assert.NoError(t, cfg.ApplyEnv(lookup))
+	assert.Equal(t, Config{
+		Listen: "tcp://0.0.0.0:9020",
+		Bootstrap: []string{
+			"0000000000000001@tcp://a:1",
+			"0000000000000002@tcp://b:2",
+		},
+		Limits: Limits{
+			MaxInboundStreams: 8,
+			ReconnectMax:      Duration(time.Minute),
+		},
+		Log: Log{Level: "warn"},
+	}, cfg)
+
+	env["CASM_MAX_INBOUND_STREAMS"] = "lots"
+	assert.Error(t, cfg.ApplyEnv(lookup))
+}
+
+func TestPeerID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "casm-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := Config{Identity: filepath.Join(dir, "identity")}
+
+	id, err := cfg.PeerID()
+	assert.NoError(t, err)
+
+	again, err := cfg.PeerID()
+	assert.NoError(t, err)
+	assert.Equal(t, id, again, "identity was not persisted")
+}
+
+func TestStart(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := Config{
+		Listen:    "inproc:///config/start",
+		Bootstrap: []string{net.New().String() + "@inproc:///config/unreachable"},
+		Log:       Log{Level: "none"},
+	}
+
+	h, err := cfg.Start(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "/config/start", h.Addr().String())
+
+	t.Run("Bootstrap", func(t *testing.T) {
+		// hosts built from configs share the inproc transport
+		cfg := Config{
+			Listen:    "inproc:///config/start/peer",
+			Bootstrap: []string{net.FormatAddr(h.Addr())},
+			Log:       Log{Level: "none"},
+		}
+
+		peer, err := cfg.Start(c)
+		assert.NoError(t, err)
+		assert.Equal(t, []net.PeerID{h.ID()}, peer.Connected())
+	})
+}
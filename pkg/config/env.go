@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Env lists the environment variables that override configuration fields.
+// List-valued variables are comma-separated.
+var Env = []struct {
+	Name string
+	set  func(*Config, string) error
+}{
+	{"CASM_LISTEN", func(cfg *Config, v string) error {
+		cfg.Listen = v
+		return nil
+	}},
+	{"CASM_IDENTITY", func(cfg *Config, v string) error {
+		cfg.Identity = v
+		return nil
+	}},
+	{"CASM_BOOTSTRAP", func(cfg *Config, v string) error {
+		cfg.Bootstrap = splitList(v)
+		return nil
+	}},
+	{"CASM_MAX_INBOUND_STREAMS", func(cfg *Config, v string) (err error) {
+		cfg.Limits.MaxInboundStreams, err = strconv.Atoi(v)
+		return
+	}},
+	{"CASM_RECONNECT_MIN", func(cfg *Config, v string) error {
+		return cfg.Limits.ReconnectMin.UnmarshalText([]byte(v))
+	}},
+	{"CASM_RECONNECT_MAX", func(cfg *Config, v string) error {
+		return cfg.Limits.ReconnectMax.UnmarshalText([]byte(v))
+	}},
+	{"CASM_LOG_LEVEL", func(cfg *Config, v string) error {
+		cfg.Log.Level = v
+		return nil
+	}},
+}
+
+// ApplyEnv overrides fields with the values of any environment variables
+// that are set.  Typically, lookup is os.LookupEnv.
+func (cfg *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	for _, e := range Env {
+		if v, ok := lookup(e.Name); ok {
+			if err := e.set(cfg, v); err != nil {
+				return errors.Wrapf(err, "invalid %s", e.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	pipe "github.com/lthibault/pipewerks/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/lthibault/pipewerks/pkg/transport/tcp"
+	"github.com/pkg/errors"
+)
+
+// PeerID returns the ID stored in the identity file, creating the file if
+// necessary.  If no identity file is configured, a random ID is returned.
+func (cfg Config) PeerID() (net.PeerID, error) {
+	if cfg.Identity == "" {
+		return net.New(), nil
+	}
+
+	b, err := ioutil.ReadFile(cfg.Identity)
+	if os.IsNotExist(err) {
+		id := net.New()
+		err = ioutil.WriteFile(cfg.Identity, []byte(id.String()+"\n"), 0600)
+		return id, errors.Wrap(err, "write identity")
+	} else if err != nil {
+		return 0, errors.Wrap(err, "read identity")
+	}
+
+	id, err := net.ParsePeerID(strings.TrimSpace(string(b)))
+	return id, errors.Wrap(err, "read identity")
+}
+
+// Addr on which the Host listens.
+func (cfg Config) Addr() (net.Addr, error) {
+	if cfg.Listen == "" {
+		return nil, errors.New("no listen address")
+	}
+
+	id, err := cfg.PeerID()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseListenAddr(cfg.Listen, id)
+}
+
+// Options for host.New.
+func (cfg Config) Options() ([]host.Option, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	a, err := parseListenAddr(cfg.Listen, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := []host.Option{
+		host.OptLogger(cfg.Logger()),
+		host.OptTransport(net.NewTransport(newTransport(a.Proto()))),
+		host.OptMaxInboundStreams(cfg.Limits.MaxInboundStreams),
+	}
+
+	if min, max := cfg.Limits.ReconnectMin, cfg.Limits.ReconnectMax; min != 0 || max != 0 {
+		opt = append(opt, host.OptReconnectBackoff(time.Duration(min), time.Duration(max)))
+	}
+
+	return opt, nil
+}
+
+// LogLevel is the configured log level.
+func (cfg Config) LogLevel() log.Level { return levels[strings.ToLower(cfg.Log.Level)] }
+
+// Logger at the configured level.
+func (cfg Config) Logger() log.Logger { return log.New(log.OptLevel(cfg.LogLevel())) }
+
+// Start a Host.  Bootstrap peers are dialed before Start returns; failure to
+// reach them is logged, but is not an error.
+func (cfg Config) Start(c context.Context, opt ...host.Option) (*host.Host, error) {
+	base, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := cfg.Addr()
+	if err != nil {
+		return nil, err
+	}
+
+	h := host.New(append(base, opt...)...)
+	if err = h.Start(c, a); err != nil {
+		return nil, errors.Wrap(err, "start host")
+	}
+
+	for _, s := range cfg.Bootstrap {
+		b, _ := net.ParseAddr(s) // validated above
+		if err = h.Connect(c, b); err != nil && errors.Cause(err) != host.ErrAlreadyConnected {
+			cfg.Logger().WithError(err).WithField("peer", s).Warn("bootstrap failed")
+		}
+	}
+
+	return h, nil
+}
+
+// parseListenAddr parses an address of the form proto://addr.
+func parseListenAddr(s string, id net.PeerID) (net.Addr, error) {
+	a, err := net.ParseAddr(id.String() + "@" + s)
+	if err != nil {
+		return nil, errors.Errorf("invalid address %q", s)
+	}
+
+	if !transports[a.Proto()] {
+		return nil, errors.Errorf("unsupported transport %q", a.Proto())
+	}
+
+	return a, nil
+}
+
+// sharedInproc is the inproc transport used by every Host built from a
+// Config, so that such Hosts can reach each other.
+var sharedInproc = inproc.New()
+
+func newTransport(proto string) pipe.Transport {
+	if proto == "inproc" {
+		return sharedInproc
+	}
+	return tcp.New()
+}
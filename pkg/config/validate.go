@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+)
+
+// FieldError describes an invalid configuration field.
+type FieldError struct {
+	Field string
+	Msg   string
+}
+
+func (e FieldError) Error() string { return fmt.Sprintf("%s: %s", e.Field, e.Msg) }
+
+// ValidationError is returned by Validate.  It lists every invalid field.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+var levels = map[string]log.Level{
+	"":      log.InfoLevel,
+	"trace": log.TraceLevel,
+	"debug": log.DebugLevel,
+	"info":  log.InfoLevel,
+	"warn":  log.WarnLevel,
+	"error": log.ErrorLevel,
+	"fatal": log.FatalLevel,
+	"none":  log.NullLevel,
+}
+
+var transports = map[string]bool{"tcp": true, "inproc": true}
+
+// Validate the configuration.  The returned error, if any, is a
+// ValidationError.
+func (cfg Config) Validate() error {
+	var errs ValidationError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	var proto string // of the listen address, if valid
+	if cfg.Listen == "" {
+		fail("listen", "required")
+	} else if a, err := parseListenAddr(cfg.Listen, 0); err != nil {
+		fail("listen", "%s", err)
+	} else {
+		proto = a.Proto()
+	}
+
+	// the Host has a single transport, so bootstrap peers must use it
+	for i, s := range cfg.Bootstrap {
+		if a, err := net.ParseAddr(s); err != nil {
+			fail(fmt.Sprintf("bootstrap[%d]", i), "%s", err)
+		} else if !transports[a.Proto()] {
+			fail(fmt.Sprintf("bootstrap[%d]", i), "unsupported transport %q", a.Proto())
+		} else if proto != "" && a.Proto() != proto {
+			fail(fmt.Sprintf("bootstrap[%d]", i), "transport %q does not match listen address", a.Proto())
+		}
+	}
+
+	if cfg.Limits.MaxInboundStreams < 0 {
+		fail("limits.max_inbound_streams", "must not be negative")
+	}
+
+	if cfg.Limits.ReconnectMin < 0 || cfg.Limits.ReconnectMax < 0 {
+		fail("limits", "reconnect delays must not be negative")
+	} else if cfg.Limits.ReconnectMax != 0 && cfg.Limits.ReconnectMin > cfg.Limits.ReconnectMax {
+		fail("limits", "reconnect_min exceeds reconnect_max")
+	}
+
+	if _, ok := levels[strings.ToLower(cfg.Log.Level)]; !ok {
+		fail("log.level", "unknown level %q", cfg.Log.Level)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
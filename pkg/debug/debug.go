@@ -0,0 +1,273 @@
+// Package debug serves JSON snapshots of a running Host's internal state over
+// HTTP, for use by operators.  It is opt-in: nothing is exposed unless the
+// Handler is explicitly mounted, e.g.:
+//
+//	http.Handle("/debug/casm/", http.StripPrefix("/debug/casm", debug.New(h)))
+//
+// The Handler serves a complete snapshot at its root, and each section of the
+// snapshot at its own path: /peers, /conns, /streams, /paths, /edges and
+// /errors.
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+// Host is the subset of *host.Host inspected by the Handler.
+type Host interface {
+	Addr() net.Addr
+	PeerRecords() []host.PeerRecord
+	Conns() []host.ConnInfo
+	Streams() []host.StreamInfo
+	Paths() []string
+	HandshakeErrors() []host.HandshakeError
+}
+
+// EdgeLister reports the edges of a graph vertex.  It is satisfied by
+// graph.Neighborhood.
+type EdgeLister interface {
+	Edges() []net.PeerID
+}
+
+// Handler serves snapshots of a Host.
+type Handler struct {
+	h   Host
+	g   EdgeLister // nil if no graph was provided
+	now func() time.Time
+	mux *http.ServeMux
+}
+
+// New Handler for the specified Host.
+func New(h Host, opt ...Option) *Handler {
+	d := &Handler{h: h, now: time.Now, mux: http.NewServeMux()}
+	for _, fn := range opt {
+		fn(d)
+	}
+
+	d.mux.HandleFunc("/", d.root(d.section(func() interface{} { return d.Snapshot() })))
+	d.mux.HandleFunc("/peers", d.section(func() interface{} { return d.peers() }))
+	d.mux.HandleFunc("/conns", d.section(func() interface{} { return d.conns() }))
+	d.mux.HandleFunc("/streams", d.section(func() interface{} { return d.streams() }))
+	d.mux.HandleFunc("/paths", d.section(func() interface{} { return d.h.Paths() }))
+	d.mux.HandleFunc("/edges", d.section(func() interface{} { return d.edges() }))
+	d.mux.HandleFunc("/errors", d.section(func() interface{} { return d.errors() }))
+
+	return d
+}
+
+// Option for Handler.
+type Option func(*Handler) (prev Option)
+
+// OptGraph includes the edges of a graph vertex in snapshots.
+func OptGraph(g EdgeLister) Option {
+	return func(d *Handler) (prev Option) {
+		prev = OptGraph(d.g)
+		d.g = g
+		return
+	}
+}
+
+// ServeHTTP satisfies http.Handler.
+func (d *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+func (d *Handler) section(fn func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(fn())
+	}
+}
+
+// root restricts h to the root path, which would otherwise match every path
+// not registered elsewhere.
+func (d *Handler) root(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// Snapshot of the Host's state.
+type Snapshot struct {
+	Time    time.Time        `json:"time"`
+	Addr    string           `json:"addr,omitempty"`
+	Peers   []Peer           `json:"peers"`
+	Conns   []Conn           `json:"conns"`
+	Streams []Stream         `json:"streams"`
+	Paths   []string         `json:"paths"`
+	Edges   []string         `json:"edges,omitempty"`
+	Errors  []HandshakeError `json:"errors"`
+}
+
+// Peer is an entry in the address book.
+type Peer struct {
+	ID     string            `json:"id"`
+	Addrs  []Addr            `json:"addrs,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+	Banned bool              `json:"banned,omitempty"`
+}
+
+// Addr is a known address of a peer.
+type Addr struct {
+	Addr    string     `json:"addr"`
+	Source  string     `json:"source"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Conn is an open connection.
+type Conn struct {
+	Peer   string    `json:"peer"`
+	Addr   string    `json:"addr"`
+	Opened time.Time `json:"opened"`
+	Age    string    `json:"age"`
+	Pinned bool      `json:"pinned,omitempty"`
+}
+
+// Stream is an open stream.
+type Stream struct {
+	ID        uint32    `json:"id"`
+	Peer      string    `json:"peer"`
+	Path      string    `json:"path"`
+	Direction string    `json:"direction"`
+	Opened    time.Time `json:"opened"`
+	Age       string    `json:"age"`
+}
+
+// HandshakeError is a recent failed negotiation.
+type HandshakeError struct {
+	Time      time.Time `json:"time"`
+	Peer      string    `json:"peer,omitempty"`
+	Direction string    `json:"direction"`
+	Reason    string    `json:"reason"`
+	Err       string    `json:"error,omitempty"`
+}
+
+// Snapshot the Host's state.
+func (d *Handler) Snapshot() Snapshot {
+	s := Snapshot{
+		Time:    d.now(),
+		Peers:   d.peers(),
+		Conns:   d.conns(),
+		Streams: d.streams(),
+		Paths:   d.h.Paths(),
+		Edges:   d.edges(),
+		Errors:  d.errors(),
+	}
+
+	if a := d.h.Addr(); a != nil {
+		s.Addr = net.FormatAddr(a)
+	}
+
+	return s
+}
+
+func (d *Handler) peers() []Peer {
+	rs := d.h.PeerRecords()
+	ps := make([]Peer, len(rs))
+	for i, r := range rs {
+		ps[i] = Peer{ID: r.ID.String(), Meta: r.Meta, Banned: r.Banned}
+		for _, a := range r.Addrs {
+			pa := Addr{
+				Addr:   net.FormatAddr(net.NewAddr(r.ID, a.Network, a.Proto, a.Addr)),
+				Source: a.Source.String(),
+			}
+			if !a.Expires.IsZero() {
+				exp := a.Expires
+				pa.Expires = &exp
+			}
+			ps[i].Addrs = append(ps[i].Addrs, pa)
+		}
+	}
+	return ps
+}
+
+func (d *Handler) conns() []Conn {
+	now := d.now()
+	infos := d.h.Conns()
+	cs := make([]Conn, len(infos))
+	for i, c := range infos {
+		cs[i] = Conn{
+			Peer:   c.Peer.String(),
+			Addr:   net.FormatAddr(c.Addr),
+			Opened: c.Opened,
+			Age:    age(now, c.Opened),
+			Pinned: c.Pinned,
+		}
+	}
+	return cs
+}
+
+func (d *Handler) streams() []Stream {
+	now := d.now()
+	infos := d.h.Streams()
+	ss := make([]Stream, len(infos))
+	for i, s := range infos {
+		dir := "outbound"
+		if s.Inbound {
+			dir = "inbound"
+		}
+
+		ss[i] = Stream{
+			ID:        s.ID,
+			Peer:      s.Peer.String(),
+			Path:      s.Path,
+			Direction: dir,
+			Opened:    s.Opened,
+			Age:       age(now, s.Opened),
+		}
+	}
+	return ss
+}
+
+func (d *Handler) edges() []string {
+	if d.g == nil {
+		return nil
+	}
+
+	ids := d.g.Edges()
+	es := make([]string, len(ids))
+	for i, id := range ids {
+		es[i] = id.String()
+	}
+	sort.Strings(es)
+	return es
+}
+
+func (d *Handler) errors() []HandshakeError {
+	infos := d.h.HandshakeErrors()
+	es := make([]HandshakeError, len(infos))
+	for i, e := range infos {
+		es[i] = HandshakeError{
+			Time:      e.Time,
+			Direction: e.Direction,
+			Reason:    e.Reason,
+			Err:       e.Err,
+		}
+		if e.Peer != 0 {
+			es[i].Peer = e.Peer.String()
+		}
+	}
+	return es
+}
+
+func age(now, t time.Time) string {
+	return now.Sub(t).Truncate(time.Millisecond).String()
+}
@@ -0,0 +1,114 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	t0    = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	local = net.NewAddr(1, "", "inproc", "/local")
+	peer  = net.NewAddr(2, "", "inproc", "/peer")
+)
+
+type stubHost struct{}
+
+func (stubHost) Addr() net.Addr { return local }
+
+func (stubHost) PeerRecords() []host.PeerRecord {
+	return []host.PeerRecord{{
+		ID: peer.ID(),
+		Addrs: []host.AddrRecord{{
+			Network: "", Proto: "inproc", Addr: "/peer",
+			Source: host.SourceDialback,
+		}},
+	}}
+}
+
+func (stubHost) Conns() []host.ConnInfo {
+	return []host.ConnInfo{{Peer: peer.ID(), Addr: peer, Opened: t0}}
+}
+
+func (stubHost) Streams() []host.StreamInfo {
+	return []host.StreamInfo{{ID: 3, Peer: peer.ID(), Path: "/echo", Inbound: true, Opened: t0}}
+}
+
+func (stubHost) Paths() []string { return []string{"/echo"} }
+
+func (stubHost) HandshakeErrors() []host.HandshakeError {
+	return []host.HandshakeError{{Time: t0, Direction: "inbound", Reason: "no handler"}}
+}
+
+type stubGraph []net.PeerID
+
+func (g stubGraph) Edges() []net.PeerID { return g }
+
+func TestHandler(t *testing.T) {
+	d := New(stubHost{}, OptGraph(stubGraph{peer.ID()}))
+	d.now = func() time.Time { return t0.Add(time.Minute) }
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("Snapshot", func(t *testing.T) {
+		rec := get("/")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var s Snapshot
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		assert.Equal(t, net.FormatAddr(local), s.Addr)
+		assert.Equal(t, []string{"/echo"}, s.Paths)
+		assert.Equal(t, []string{peer.ID().String()}, s.Edges)
+
+		if assert.Len(t, s.Peers, 1) && assert.Len(t, s.Peers[0].Addrs, 1) {
+			assert.Equal(t, net.FormatAddr(peer), s.Peers[0].Addrs[0].Addr)
+			assert.Equal(t, host.SourceDialback.String(), s.Peers[0].Addrs[0].Source)
+			assert.Nil(t, s.Peers[0].Addrs[0].Expires)
+		}
+
+		if assert.Len(t, s.Conns, 1) {
+			assert.Equal(t, "1m0s", s.Conns[0].Age)
+		}
+	})
+
+	t.Run("Section", func(t *testing.T) {
+		var ss []Stream
+		assert.NoError(t, json.Unmarshal(get("/streams").Body.Bytes(), &ss))
+		assert.Equal(t, []Stream{{
+			ID:        3,
+			Peer:      peer.ID().String(),
+			Path:      "/echo",
+			Direction: "inbound",
+			Opened:    t0,
+			Age:       "1m0s",
+		}}, ss)
+
+		var es []HandshakeError
+		assert.NoError(t, json.Unmarshal(get("/errors").Body.Bytes(), &es))
+		if assert.Len(t, es, 1) {
+			assert.Empty(t, es[0].Peer)
+			assert.Equal(t, "no handler", es[0].Reason)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/bogus").Code)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/peers", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
@@ -0,0 +1,366 @@
+// Package dht implements a Kademlia distributed hash table over host streams.
+//
+// PeerIDs and keys share a 64-bit keyspace, in which the distance between two
+// points is their bitwise XOR.  Each node maintains a routing table of
+// k-buckets, and locates peers or provider records with iterative lookups.
+// Addresses learned through the DHT are recorded in the host's address book,
+// so that any PeerID it returns can be passed directly to Host.Connect.
+package dht
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/rpc"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+)
+
+// Path on which the DHT's RPC server is registered.
+const Path = "/casm/dht/1.0.0"
+
+// providerGCInterval is the period at which expired provider records are
+// dropped.
+const providerGCInterval = time.Minute
+
+var (
+	// ErrNotFound is returned when a lookup fails to locate its target.
+	ErrNotFound = errors.New("not found")
+
+	// ErrNoPeers is returned when the routing table is empty.
+	ErrNoPeers = errors.New("routing table is empty")
+)
+
+// Host is the subset of *host.Host used by the DHT.
+type Host interface {
+	casm.IDer
+	Addr() net.Addr
+	Register(string, host.Handler)
+	Open(context.Context, casm.Addresser, string) (host.Stream, error)
+	Addrs(casm.IDer) []net.Addr
+	AddAddr(casm.Addresser, host.AddrSource, time.Duration)
+	Connected() []net.PeerID
+	Subscribe() (<-chan host.Event, func())
+}
+
+// DHT is a node in a Kademlia distributed hash table.
+type DHT struct {
+	log log.Logger
+	h   Host
+
+	k, alpha, maxProvided         int
+	addrTTL, providerTTL, timeout time.Duration
+
+	rt *routingTable
+	ps *providerStore
+	cl *rpc.Client
+}
+
+// New DHT node.  The host MUST be started.  The node answers queries
+// immediately, but only populates its routing table once Start is called.
+func New(h Host, opt ...Option) *DHT {
+	d := &DHT{h: h}
+	for _, fn := range setDefaultOpts(opt) {
+		fn(d)
+	}
+
+	d.rt = newRoutingTable(h.ID(), d.k)
+	d.ps = newProviderStore(d.providerTTL)
+	d.cl = rpc.NewClientForPath(d.log, h, Path)
+
+	srv := rpc.NewServer(d.log)
+	srv.Register(methodFindNode, d.handler(d.handleFindNode))
+	srv.Register(methodGetProviders, d.handler(d.handleGetProviders))
+	srv.Register(methodAddProvider, d.handler(d.handleAddProvider))
+	h.Register(Path, srv)
+
+	return d
+}
+
+// Start adding connected peers to the routing table, and expiring provider
+// records, until c expires.
+func (d *DHT) Start(c context.Context) {
+	ch, cancel := d.h.Subscribe()
+
+	for _, id := range d.h.Connected() {
+		d.seen(id)
+	}
+
+	go func() {
+		defer cancel()
+		defer d.cl.Close()
+
+		ticker := time.NewTicker(providerGCInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-ticker.C:
+				d.ps.GC()
+			case e := <-ch:
+				if e.Type == host.EvtConnected || e.Type == host.EvtReconnected {
+					d.seen(e.Peer)
+				}
+			}
+		}
+	}()
+}
+
+// Bootstrap populates the routing table by looking up the node's own ID.
+func (d *DHT) Bootstrap(c context.Context) error {
+	_, err := d.lookup(c, Key(d.h.ID()), d.findNode)
+	return err
+}
+
+// FindPeer returns the addresses of the peer.  The address book is consulted
+// before querying the network.
+func (d *DHT) FindPeer(c context.Context, id casm.IDer) ([]net.Addr, error) {
+	if as := d.h.Addrs(id); len(as) > 0 {
+		return as, nil
+	}
+
+	// queries run concurrently
+	var (
+		lock  sync.Mutex
+		found []net.Addr
+	)
+
+	_, err := d.lookup(c, Key(id.ID()), func(c context.Context, a net.Addr, k Key) ([]net.Addr, bool, error) {
+		closer, _, err := d.findNode(c, a, k)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		for _, b := range closer {
+			if b.ID() == id.ID() {
+				found = append(found, b)
+			}
+		}
+		return closer, len(found) > 0, err
+	})
+
+	if len(found) > 0 {
+		return found, nil
+	}
+
+	if err == nil {
+		err = ErrNotFound
+	}
+
+	return nil, err
+}
+
+// Provide announces that the host provides the key, by publishing a provider
+// record to the k peers closest to it.  Records expire, so Provide should be
+// called periodically.
+func (d *DHT) Provide(c context.Context, key []byte) error {
+	k := KeyOf(key)
+	d.ps.Add(k, d.h.Addr(), 0) // local records are not limited
+
+	closest, err := d.lookup(c, k, d.findNode)
+	if err != nil {
+		return errors.Wrap(err, "lookup")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(encodeKey(k))
+	if err = net.SendAddr(&buf, d.h.Addr()); err != nil {
+		return err
+	}
+
+	var ok bool
+	for _, a := range closest {
+		if _, err = d.call(c, a, methodAddProvider, buf.Bytes()); err == nil {
+			ok = true
+		}
+	}
+
+	if !ok && err != nil {
+		return errors.Wrap(err, "add provider")
+	}
+
+	return nil
+}
+
+// FindProviders returns the addresses of peers that provide the key.
+func (d *DHT) FindProviders(c context.Context, key []byte) ([]net.Addr, error) {
+	k := KeyOf(key)
+
+	// queries run concurrently
+	var (
+		lock  sync.Mutex
+		seen  = make(map[net.PeerID]struct{})
+		found []net.Addr
+	)
+
+	// add providers, and report whether enough have been found
+	add := func(as []net.Addr) bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		for _, a := range as {
+			if _, dup := seen[a.ID()]; !dup {
+				seen[a.ID()] = struct{}{}
+				found = append(found, a)
+			}
+		}
+		return len(found) >= d.k
+	}
+
+	add(d.ps.Get(k))
+
+	_, err := d.lookup(c, k, func(c context.Context, a net.Addr, k Key) ([]net.Addr, bool, error) {
+		b, err := d.call(c, a, methodGetProviders, encodeKey(k))
+		if err != nil {
+			return nil, false, err
+		}
+
+		var res providersResponse
+		if err = res.UnmarshalBinary(b); err != nil {
+			return nil, false, err
+		}
+
+		return res.Closer, add(res.Providers), nil
+	})
+
+	if len(found) > 0 {
+		for _, a := range found {
+			d.learn(a)
+		}
+		return found, nil
+	}
+
+	if err == nil || err == ErrNoPeers {
+		err = ErrNotFound
+	}
+
+	return nil, err
+}
+
+// seen records activity from a peer in the routing table.  When a bucket is
+// full, the least-recently-seen peer is evicted if it is not connected.
+func (d *DHT) seen(id net.PeerID) {
+	d.rt.Update(id, func(old net.PeerID) bool {
+		for _, p := range d.h.Connected() {
+			if p == old {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// learn a remote peer's address.
+func (d *DHT) learn(a net.Addr) {
+	if a.ID() != d.h.ID() {
+		d.h.AddAddr(a, host.SourceDiscovery, d.addrTTL)
+	}
+}
+
+func (d *DHT) call(c context.Context, a net.Addr, method string, req []byte) ([]byte, error) {
+	c, cancel := context.WithTimeout(c, d.timeout)
+	defer cancel()
+
+	res, err := d.cl.Call(c, a, method, req)
+	if err == nil {
+		d.seen(a.ID())
+	}
+	return res, err
+}
+
+func (d *DHT) findNode(c context.Context, a net.Addr, k Key) ([]net.Addr, bool, error) {
+	b, err := d.call(c, a, methodFindNode, encodeKey(k))
+	if err != nil {
+		return nil, false, err
+	}
+
+	closer, err := readAddrs(bytes.NewReader(b))
+	return closer, false, err
+}
+
+// closest returns the addresses of up to k known peers closest to the key,
+// excluding the specified peer.
+func (d *DHT) closest(k Key, exclude net.PeerID) []net.Addr {
+	var as []net.Addr
+	for _, id := range d.rt.Closest(k, d.k+1) {
+		if id == exclude {
+			continue
+		}
+
+		if addrs := d.h.Addrs(id); len(addrs) > 0 {
+			as = append(as, addrs[0])
+		}
+
+		if len(as) == d.k {
+			break
+		}
+	}
+	return as
+}
+
+// handler adapts fn to an rpc.StreamHandler, and records the caller in the
+// routing table.
+func (d *DHT) handler(fn func(net.PeerID, []byte) ([]byte, error)) rpc.StreamHandler {
+	return func(s rpc.ServerStream) error {
+		id := s.RemoteAddr().ID()
+		d.seen(id)
+
+		return rpc.Unary(func(_ context.Context, req []byte) ([]byte, error) {
+			return fn(id, req)
+		})(s)
+	}
+}
+
+func (d *DHT) handleFindNode(remote net.PeerID, req []byte) ([]byte, error) {
+	k, err := decodeKey(bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = writeAddrs(&buf, d.closest(k, remote))
+	return buf.Bytes(), err
+}
+
+func (d *DHT) handleGetProviders(remote net.PeerID, req []byte) ([]byte, error) {
+	k, err := decodeKey(bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+
+	return providersResponse{
+		Providers: d.ps.Get(k),
+		Closer:    d.closest(k, remote),
+	}.MarshalBinary()
+}
+
+func (d *DHT) handleAddProvider(remote net.PeerID, req []byte) ([]byte, error) {
+	r := bytes.NewReader(req)
+
+	k, err := decodeKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := net.RecvAddr(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "read addr")
+	}
+
+	if a.ID() != remote {
+		return nil, rpc.Errorf(rpc.CodeUnknown, "provider %s does not match caller", a.ID())
+	}
+
+	if !d.ps.Add(k, a, d.maxProvided) {
+		return nil, rpc.Errorf(rpc.CodeResourceExhausted, "too many provider records")
+	}
+	d.learn(a)
+	return nil, nil
+}
@@ -0,0 +1,163 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvidersResponse(t *testing.T) {
+	res := providersResponse{
+		Providers: []net.Addr{net.NewAddr(net.New(), "", "inproc", "/p")},
+		Closer: []net.Addr{
+			net.NewAddr(net.New(), "", "inproc", "/c0"),
+			net.NewAddr(net.New(), "", "inproc", "/c1"),
+		},
+	}
+
+	b, err := res.MarshalBinary()
+	assert.NoError(t, err)
+
+	var got providersResponse
+	assert.NoError(t, got.UnmarshalBinary(b))
+	assert.Len(t, got.Providers, 1)
+	assert.Len(t, got.Closer, 2)
+	assert.Equal(t, res.Closer[1].ID(), got.Closer[1].ID())
+}
+
+// TestDHT arranges hosts in a chain, in which each host is only connected to
+// its neighbors, and checks that lookups traverse it.
+func TestDHT(t *testing.T) {
+	const n = 6
+
+	l := log.New(log.OptLevel(log.NullLevel))
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(l),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hs := make([]*host.Host, n)
+	as := make([]net.Addr, n)
+	ds := make([]*DHT, n)
+	for i := range hs {
+		hs[i] = host.New(opt...)
+		as[i] = net.NewAddr(net.New(), "", "inproc", fmt.Sprintf("/dht/%d", i))
+		assert.NoError(t, hs[i].Start(c, as[i]))
+
+		ds[i] = New(hs[i], OptLogger(l))
+		ds[i].Start(c)
+	}
+
+	for i := 1; i < n; i++ {
+		assert.NoError(t, hs[i].Connect(c, as[i-1]))
+	}
+
+	// connection events are delivered asynchronously
+	assert.Eventually(t, func() bool {
+		for i, d := range ds {
+			if want := map[bool]int{true: 1, false: 2}[i == 0 || i == n-1]; d.rt.Size() != want {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+
+	t.Run("NoPeers", func(t *testing.T) {
+		h := host.New(opt...)
+		assert.NoError(t, h.Start(c, net.NewAddr(net.New(), "", "inproc", "/dht/lonely")))
+
+		_, err := New(h, OptLogger(l)).FindPeer(c, as[0].ID())
+		assert.Equal(t, ErrNoPeers, err)
+	})
+
+	t.Run("FindPeer", func(t *testing.T) {
+		assert.Empty(t, hs[n-1].Addrs(as[0]))
+
+		found, err := ds[n-1].FindPeer(c, as[0].ID())
+		assert.NoError(t, err)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, as[0].String(), found[0].String())
+		}
+
+		assert.NoError(t, hs[n-1].Connect(c, as[0].ID()), "address not recorded")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := ds[0].FindPeer(c, net.New())
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("Providers", func(t *testing.T) {
+		_, err := ds[n-1].FindProviders(c, []byte("foo"))
+		assert.Equal(t, ErrNotFound, err)
+
+		assert.NoError(t, ds[2].Provide(c, []byte("foo")))
+
+		found, err := ds[n-1].FindProviders(c, []byte("foo"))
+		assert.NoError(t, err)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, as[2].ID(), found[0].ID())
+		}
+	})
+}
+
+// TestConcurrentQueries arranges hosts such that lookups query several peers
+// concurrently, each of which knows the target.
+func TestConcurrentQueries(t *testing.T) {
+	l := log.New(log.OptLevel(log.NullLevel))
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(l),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDHT := func(name string) (*host.Host, net.Addr, *DHT) {
+		h := host.New(opt...)
+		a := net.NewAddr(net.New(), "", "inproc", "/dht/concurrent/"+name)
+		assert.NoError(t, h.Start(c, a))
+
+		d := New(h, OptLogger(l))
+		d.Start(c)
+		return h, a, d
+	}
+
+	src, _, dsrc := newDHT("src")
+	dst, adst, ddst := newDHT("dst")
+	for i := 0; i < 3; i++ {
+		_, a, _ := newDHT(fmt.Sprintf("%d", i))
+		assert.NoError(t, src.Connect(c, a))
+		assert.NoError(t, dst.Connect(c, a))
+	}
+
+	assert.Eventually(t, func() bool {
+		return dsrc.rt.Size() == 3 && ddst.rt.Size() == 3
+	}, time.Second, time.Millisecond)
+
+	t.Run("FindPeer", func(t *testing.T) {
+		found, err := dsrc.FindPeer(c, adst.ID())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, found)
+	})
+
+	t.Run("FindProviders", func(t *testing.T) {
+		assert.NoError(t, ddst.Provide(c, []byte("bar")))
+
+		found, err := dsrc.FindProviders(c, []byte("bar"))
+		assert.NoError(t, err)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, adst.ID(), found[0].ID())
+		}
+	})
+}
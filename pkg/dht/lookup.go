@@ -0,0 +1,138 @@
+package dht
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+// queryFunc queries a remote peer during a lookup.  It returns peers closer to
+// the target, and true if the lookup should terminate early.
+type queryFunc func(context.Context, net.Addr, Key) (closer []net.Addr, done bool, err error)
+
+type candidate struct {
+	addr            net.Addr
+	queried, failed bool
+}
+
+// lookup performs an iterative Kademlia lookup for the target.  In each round,
+// up to alpha of the k closest unqueried candidates are queried concurrently,
+// and the peers they return are added to the candidate set.  The lookup ends
+// when the k closest candidates have all been queried, or when query reports
+// that it is done.  It returns the k closest peers that responded.
+func (d *DHT) lookup(c context.Context, target Key, query queryFunc) ([]net.Addr, error) {
+	cs := make(map[net.PeerID]*candidate)
+	for _, a := range d.closest(target, d.h.ID()) {
+		cs[a.ID()] = &candidate{addr: a}
+	}
+
+	if len(cs) == 0 {
+		return nil, ErrNoPeers
+	}
+
+	for {
+		if err := c.Err(); err != nil {
+			return nil, err
+		}
+
+		batch := nextBatch(cs, target, d.k, d.alpha)
+		if len(batch) == 0 {
+			break
+		}
+
+		var (
+			wg   sync.WaitGroup
+			lock sync.Mutex
+			done bool
+		)
+
+		for _, cand := range batch {
+			cand.queried = true
+
+			wg.Add(1)
+			go func(cand *candidate) {
+				defer wg.Done()
+
+				closer, stop, err := query(c, cand.addr, target)
+
+				lock.Lock()
+				defer lock.Unlock()
+
+				if err != nil {
+					cand.failed = true
+					return
+				}
+				done = done || stop
+
+				for _, a := range closer {
+					if a.ID() == d.h.ID() {
+						continue
+					}
+
+					d.learn(a)
+					if _, ok := cs[a.ID()]; !ok {
+						cs[a.ID()] = &candidate{addr: a}
+					}
+				}
+			}(cand)
+		}
+
+		wg.Wait()
+		if done {
+			break
+		}
+	}
+
+	var out []net.Addr
+	for _, cand := range sorted(cs, target) {
+		if cand.queried && !cand.failed {
+			out = append(out, cand.addr)
+		}
+		if len(out) == d.k {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// nextBatch returns up to alpha unqueried candidates from among the k closest
+// candidates that have not failed.
+func nextBatch(cs map[net.PeerID]*candidate, target Key, k, alpha int) []*candidate {
+	var batch []*candidate
+
+	n := 0
+	for _, cand := range sorted(cs, target) {
+		if cand.failed {
+			continue
+		}
+
+		if n++; n > k {
+			break
+		}
+
+		if !cand.queried {
+			batch = append(batch, cand)
+			if len(batch) == alpha {
+				break
+			}
+		}
+	}
+
+	return batch
+}
+
+func sorted(cs map[net.PeerID]*candidate, target Key) []*candidate {
+	out := make([]*candidate, 0, len(cs))
+	for _, cand := range cs {
+		out = append(out, cand)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return distance(Key(out[i].addr.ID()), target) < distance(Key(out[j].addr.ID()), target)
+	})
+
+	return out
+}
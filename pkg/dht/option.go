@@ -0,0 +1,99 @@
+package dht
+
+import (
+	"time"
+
+	log "github.com/lthibault/log/pkg"
+)
+
+// Option for DHT.
+type Option func(*DHT) (prev Option)
+
+func setDefaultOpts(opt []Option) []Option {
+	return append(
+		[]Option{
+			OptLogger(nil),
+			OptBucketSize(20),
+			OptConcurrency(3),
+			OptAddrTTL(time.Minute * 30),
+			OptProviderTTL(time.Hour * 24),
+			OptMaxProvided(1024),
+			OptTimeout(time.Second * 10),
+		},
+		opt...,
+	)
+}
+
+// OptLogger sets the logger.
+func OptLogger(l log.Logger) Option {
+	if l == nil {
+		l = log.New()
+	}
+
+	return func(d *DHT) (prev Option) {
+		prev = OptLogger(d.log)
+		d.log = l
+		return
+	}
+}
+
+// OptBucketSize sets k, the maximum number of peers per bucket.  It is also
+// the number of peers returned by lookups, and to which provider records are
+// published.
+func OptBucketSize(k int) Option {
+	return func(d *DHT) (prev Option) {
+		prev = OptBucketSize(d.k)
+		d.k = k
+		return
+	}
+}
+
+// OptConcurrency sets alpha, the number of concurrent queries issued during a
+// lookup.
+func OptConcurrency(alpha int) Option {
+	return func(d *DHT) (prev Option) {
+		prev = OptConcurrency(d.alpha)
+		d.alpha = alpha
+		return
+	}
+}
+
+// OptAddrTTL sets the time for which addresses learned through the DHT are
+// kept in the address book.
+func OptAddrTTL(ttl time.Duration) Option {
+	return func(d *DHT) (prev Option) {
+		prev = OptAddrTTL(d.addrTTL)
+		d.addrTTL = ttl
+		return
+	}
+}
+
+// OptProviderTTL sets the time for which provider records are stored.
+// Providers should call Provide again before it elapses.
+func OptProviderTTL(ttl time.Duration) Option {
+	return func(d *DHT) (prev Option) {
+		prev = OptProviderTTL(d.providerTTL)
+		d.providerTTL = ttl
+		return
+	}
+}
+
+// OptMaxProvided limits the number of keys for which each remote peer may
+// store provider records.  Records beyond the limit are refused until existing
+// ones expire.  Zero means no limit.
+func OptMaxProvided(n int) Option {
+	return func(d *DHT) (prev Option) {
+		prev = OptMaxProvided(d.maxProvided)
+		d.maxProvided = n
+		return
+	}
+}
+
+// OptTimeout bounds the duration of each query to a remote peer.
+func OptTimeout(timeout time.Duration) Option {
+	return func(d *DHT) (prev Option) {
+		prev = OptTimeout(d.timeout)
+		d.timeout = timeout
+		return
+	}
+}
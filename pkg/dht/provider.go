@@ -0,0 +1,98 @@
+package dht
+
+import (
+	"sync"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+// providerStore records which peers provide each key.  Records expire after a
+// fixed TTL, and must be refreshed by the provider.  The number of keys each
+// peer may provide is bounded, so that a single peer cannot exhaust memory.
+type providerStore struct {
+	lock sync.Mutex
+	ttl  time.Duration
+	m    map[Key]map[net.PeerID]provider
+	n    map[net.PeerID]int // number of keys provided by each peer
+}
+
+type provider struct {
+	addr net.Addr
+	exp  time.Time
+}
+
+func newProviderStore(ttl time.Duration) *providerStore {
+	return &providerStore{
+		ttl: ttl,
+		m:   make(map[Key]map[net.PeerID]provider),
+		n:   make(map[net.PeerID]int),
+	}
+}
+
+// Add a provider record, or refresh an existing one.  New records are refused
+// if the provider already has max keys; a max of zero means no limit.
+func (ps *providerStore) Add(k Key, a net.Addr, max int) bool {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	m, ok := ps.m[k]
+	if _, exists := m[a.ID()]; !exists {
+		if max > 0 && ps.n[a.ID()] >= max {
+			return false
+		}
+		ps.n[a.ID()]++
+	}
+
+	if !ok {
+		m = make(map[net.PeerID]provider)
+		ps.m[k] = m
+	}
+
+	m[a.ID()] = provider{addr: a, exp: time.Now().Add(ps.ttl)}
+	return true
+}
+
+// Get returns unexpired providers for the key, dropping expired ones.
+func (ps *providerStore) Get(k Key) []net.Addr {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	now := time.Now()
+	var as []net.Addr
+	for id, p := range ps.m[k] {
+		if now.After(p.exp) {
+			ps.dropUnsafe(k, id)
+			continue
+		}
+		as = append(as, p.addr)
+	}
+
+	return as
+}
+
+// GC drops every expired record.
+func (ps *providerStore) GC() {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	now := time.Now()
+	for k, m := range ps.m {
+		for id, p := range m {
+			if now.After(p.exp) {
+				ps.dropUnsafe(k, id)
+			}
+		}
+	}
+}
+
+func (ps *providerStore) dropUnsafe(k Key, id net.PeerID) {
+	delete(ps.m[k], id)
+	if len(ps.m[k]) == 0 {
+		delete(ps.m, k)
+	}
+
+	if ps.n[id]--; ps.n[id] <= 0 {
+		delete(ps.n, id)
+	}
+}
@@ -0,0 +1,36 @@
+package dht
+
+import (
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderStore(t *testing.T) {
+	a := net.NewAddr(net.New(), "", "inproc", "/provider")
+
+	t.Run("Limit", func(t *testing.T) {
+		ps := newProviderStore(time.Hour)
+
+		assert.True(t, ps.Add(1, a, 2))
+		assert.True(t, ps.Add(2, a, 2))
+		assert.False(t, ps.Add(3, a, 2), "limit exceeded")
+		assert.True(t, ps.Add(1, a, 2), "refresh refused")
+		assert.True(t, ps.Add(3, a, 0), "unlimited add refused")
+	})
+
+	t.Run("GC", func(t *testing.T) {
+		ps := newProviderStore(-time.Second)
+
+		assert.True(t, ps.Add(1, a, 1))
+		ps.GC()
+		assert.Empty(t, ps.m)
+		assert.Empty(t, ps.n)
+
+		assert.True(t, ps.Add(2, a, 1), "expired record still counted")
+		assert.Empty(t, ps.Get(2))
+		assert.Empty(t, ps.n)
+	})
+}
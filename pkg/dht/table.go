@@ -0,0 +1,133 @@
+package dht
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+	"sort"
+	"sync"
+
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+const idBits = 64
+
+// Key identifies a point in the DHT's keyspace, which is shared with PeerIDs.
+type Key uint64
+
+// KeyOf hashes an arbitrary key into the keyspace.
+func KeyOf(b []byte) Key {
+	sum := sha256.Sum256(b)
+	return Key(binary.BigEndian.Uint64(sum[:8]))
+}
+
+// distance between two points in the keyspace.
+func distance(a, b Key) uint64 { return uint64(a ^ b) }
+
+// bucketIndex returns the index of the bucket in which id belongs, relative to
+// self.  Bucket i holds peers whose distance has its highest set bit at
+// position i.  It returns -1 for self.
+func bucketIndex(self, id net.PeerID) int {
+	return bits.Len64(distance(Key(self), Key(id))) - 1
+}
+
+// sortByDistance sorts ids in place by increasing distance to target.
+func sortByDistance(ids []net.PeerID, target Key) {
+	sort.Slice(ids, func(i, j int) bool {
+		return distance(Key(ids[i]), target) < distance(Key(ids[j]), target)
+	})
+}
+
+// routingTable is a set of k-buckets.  Within each bucket, peers are ordered
+// from least to most recently seen.
+type routingTable struct {
+	lock    sync.RWMutex
+	self    net.PeerID
+	k       int
+	buckets [idBits][]net.PeerID
+}
+
+func newRoutingTable(self net.PeerID, k int) *routingTable {
+	return &routingTable{self: self, k: k}
+}
+
+// Update records that the peer was seen.  If the peer's bucket is full, the
+// least recently seen peer is evicted if evictable reports that it may be;
+// otherwise, the new peer is dropped.  It returns true if the peer is in the
+// table.
+func (rt *routingTable) Update(id net.PeerID, evictable func(net.PeerID) bool) bool {
+	i := bucketIndex(rt.self, id)
+	if i < 0 {
+		return false
+	}
+
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	b := rt.buckets[i]
+	for j, p := range b {
+		if p == id { // move to tail
+			copy(b[j:], b[j+1:])
+			b[len(b)-1] = id
+			return true
+		}
+	}
+
+	if len(b) < rt.k {
+		rt.buckets[i] = append(b, id)
+		return true
+	}
+
+	if evictable != nil && evictable(b[0]) {
+		copy(b, b[1:])
+		b[len(b)-1] = id
+		return true
+	}
+
+	return false
+}
+
+// Remove the peer from the table.
+func (rt *routingTable) Remove(id net.PeerID) {
+	i := bucketIndex(rt.self, id)
+	if i < 0 {
+		return
+	}
+
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	b := rt.buckets[i]
+	for j, p := range b {
+		if p == id {
+			rt.buckets[i] = append(b[:j], b[j+1:]...)
+			return
+		}
+	}
+}
+
+// Closest returns up to n peers, sorted by increasing distance to target.
+func (rt *routingTable) Closest(target Key, n int) []net.PeerID {
+	rt.lock.RLock()
+	var ids []net.PeerID
+	for _, b := range rt.buckets {
+		ids = append(ids, b...)
+	}
+	rt.lock.RUnlock()
+
+	sortByDistance(ids, target)
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
+
+// Size returns the number of peers in the table.
+func (rt *routingTable) Size() (n int) {
+	rt.lock.RLock()
+	for _, b := range rt.buckets {
+		n += len(b)
+	}
+	rt.lock.RUnlock()
+	return
+}
@@ -0,0 +1,52 @@
+package dht
+
+import (
+	"testing"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketIndex(t *testing.T) {
+	assert.Equal(t, -1, bucketIndex(5, 5))
+	assert.Equal(t, 0, bucketIndex(0, 1))
+	assert.Equal(t, 3, bucketIndex(0, 8))
+	assert.Equal(t, 3, bucketIndex(0, 15))
+	assert.Equal(t, 63, bucketIndex(0, 1<<63))
+}
+
+func TestRoutingTable(t *testing.T) {
+	t.Run("Update", func(t *testing.T) {
+		rt := newRoutingTable(0, 2)
+
+		assert.False(t, rt.Update(0, nil), "self was added")
+		assert.True(t, rt.Update(4, nil))
+		assert.True(t, rt.Update(5, nil))
+		assert.True(t, rt.Update(4, nil)) // moves 4 to the tail
+		assert.Equal(t, []net.PeerID{5, 4}, rt.buckets[2])
+
+		// bucket is full; 5 is least-recently seen
+		assert.False(t, rt.Update(6, func(net.PeerID) bool { return false }))
+		assert.True(t, rt.Update(6, func(id net.PeerID) bool { return id == 5 }))
+		assert.Equal(t, []net.PeerID{4, 6}, rt.buckets[2])
+		assert.Equal(t, 2, rt.Size())
+
+		rt.Remove(4)
+		assert.Equal(t, []net.PeerID{6}, rt.buckets[2])
+	})
+
+	t.Run("Closest", func(t *testing.T) {
+		rt := newRoutingTable(0, 20)
+		for _, id := range []net.PeerID{1, 2, 3, 8, 9, 16} {
+			rt.Update(id, nil)
+		}
+
+		assert.Equal(t, []net.PeerID{8, 9, 1}, rt.Closest(8, 3))
+		assert.Equal(t, []net.PeerID{16, 1, 2, 3, 8, 9}, rt.Closest(16, 10))
+	})
+}
+
+func TestKeyOf(t *testing.T) {
+	assert.Equal(t, KeyOf([]byte("foo")), KeyOf([]byte("foo")))
+	assert.NotEqual(t, KeyOf([]byte("foo")), KeyOf([]byte("bar")))
+}
@@ -0,0 +1,88 @@
+package dht
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+// RPC methods
+const (
+	methodFindNode      = "dht.FindNode"
+	methodGetProviders  = "dht.GetProviders"
+	methodAddProvider   = "dht.AddProvider"
+	maxAddrsPerResponse = 255
+)
+
+func encodeKey(k Key) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(k))
+	return b
+}
+
+func decodeKey(r io.Reader) (k Key, err error) {
+	err = binary.Read(r, binary.BigEndian, &k)
+	return k, errors.Wrap(err, "read key")
+}
+
+func writeAddrs(w io.Writer, as []net.Addr) error {
+	if len(as) > maxAddrsPerResponse {
+		as = as[:maxAddrsPerResponse]
+	}
+
+	if _, err := w.Write([]byte{uint8(len(as))}); err != nil {
+		return err
+	}
+
+	for _, a := range as {
+		if err := net.SendAddr(w, a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readAddrs(r io.Reader) ([]net.Addr, error) {
+	var n [1]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, errors.Wrap(err, "read count")
+	}
+
+	as := make([]net.Addr, n[0])
+	for i := range as {
+		var err error
+		if as[i], err = net.RecvAddr(r); err != nil {
+			return nil, errors.Wrap(err, "read addr")
+		}
+	}
+
+	return as, nil
+}
+
+// providersResponse is the reply to GetProviders.
+type providersResponse struct {
+	Providers, Closer []net.Addr
+}
+
+func (res providersResponse) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeAddrs(&buf, res.Providers); err != nil {
+		return nil, err
+	}
+	if err := writeAddrs(&buf, res.Closer); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (res *providersResponse) UnmarshalBinary(b []byte) (err error) {
+	r := bytes.NewReader(b)
+	if res.Providers, err = readAddrs(r); err == nil {
+		res.Closer, err = readAddrs(r)
+	}
+	return
+}
@@ -0,0 +1,146 @@
+// Package discover provides sources of candidate peers.
+//
+// Discovered addresses are typically fed into a Host's address book and
+// connected via Bootstrap, or offered to a graph.Neighborhood via Lease.
+package discover
+
+import (
+	"context"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+// Discoverer returns addresses of candidate peers.
+type Discoverer interface {
+	Discover(context.Context) ([]net.Addr, error)
+}
+
+// Static is a fixed list of peers.
+type Static []net.Addr
+
+// Discover returns a copy of the list.
+func (s Static) Discover(context.Context) ([]net.Addr, error) {
+	return append([]net.Addr(nil), s...), nil
+}
+
+// Multi combines the results of several Discoverers.  Failing Discoverers are
+// skipped; an error is returned only if all of them fail.
+type Multi []Discoverer
+
+// Discover from each Discoverer in turn.  Duplicate addresses are removed.
+func (m Multi) Discover(c context.Context) ([]net.Addr, error) {
+	var (
+		out  []net.Addr
+		err  error
+		ok   bool
+		seen = make(map[string]struct{})
+	)
+
+	for _, d := range m {
+		as, e := d.Discover(c)
+		if e != nil {
+			err = e
+			continue
+		}
+		ok = true
+
+		for _, a := range as {
+			key := net.FormatAddr(a)
+			if _, dup := seen[key]; !dup {
+				seen[key] = struct{}{}
+				out = append(out, a)
+			}
+		}
+	}
+
+	if !ok && err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Host is the subset of *host.Host used by Bootstrap.
+type Host interface {
+	casm.IDer
+	AddAddr(casm.Addresser, host.AddrSource, time.Duration)
+	Connect(context.Context, casm.IDer) error
+}
+
+// Bootstrap adds discovered addresses to the host's address book, and connects
+// to each peer.  It returns the number of peers to which the host is
+// connected, and an error only if discovery fails or no connection could be
+// established.
+func Bootstrap(c context.Context, h Host, d Discoverer, ttl time.Duration) (int, error) {
+	as, err := d.Discover(c)
+	if err != nil {
+		return 0, errors.Wrap(err, "discover")
+	}
+
+	peers := make(map[net.PeerID]struct{})
+	for _, a := range as {
+		if a.ID() != h.ID() {
+			h.AddAddr(a, host.SourceDiscovery, ttl)
+			peers[a.ID()] = struct{}{}
+		}
+	}
+
+	var n int
+	for id := range peers {
+		switch e := h.Connect(c, id); errors.Cause(e) {
+		case nil, host.ErrAlreadyConnected:
+			n++
+		default:
+			err = e
+		}
+	}
+
+	if n == 0 && err != nil {
+		return 0, errors.Wrap(err, "connect")
+	}
+
+	return n, nil
+}
+
+// Leaser is satisfied by graph.Neighborhood.
+type Leaser interface {
+	In(casm.IDer) bool
+	Lease(context.Context, casm.Addresser) error
+}
+
+// Lease edge slots to up to n discovered peers that are not already in the
+// neighborhood.  It returns the number of leases granted, and an error only if
+// discovery fails or every lease was refused.
+func Lease(c context.Context, l Leaser, d Discoverer, n int) (int, error) {
+	as, err := d.Discover(c)
+	if err != nil {
+		return 0, errors.Wrap(err, "discover")
+	}
+
+	var leased int
+	for _, a := range as {
+		if leased == n {
+			break
+		}
+
+		if l.In(a) {
+			continue
+		}
+
+		if e := l.Lease(c, a); e != nil {
+			err = e
+		} else {
+			leased++
+		}
+	}
+
+	if leased == 0 && err != nil {
+		return 0, errors.Wrap(err, "lease")
+	}
+
+	return leased, nil
+}
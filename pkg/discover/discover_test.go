@@ -0,0 +1,96 @@
+package discover
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+type failing struct{}
+
+func (failing) Discover(context.Context) ([]net.Addr, error) {
+	return nil, errors.New("fail")
+}
+
+func TestMulti(t *testing.T) {
+	a0 := net.NewAddr(net.New(), "", "inproc", "/a0")
+	a1 := net.NewAddr(net.New(), "", "inproc", "/a1")
+
+	as, err := Multi{Static{a0}, failing{}, Static{a0, a1}}.Discover(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []net.Addr{a0, a1}, as)
+
+	_, err = Multi{failing{}}.Discover(context.Background())
+	assert.Error(t, err)
+}
+
+func TestBootstrap(t *testing.T) {
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := host.New(opt...), host.New(opt...)
+	a0 := net.NewAddr(net.New(), "", "inproc", "/discover/h0")
+	a1 := net.NewAddr(net.New(), "", "inproc", "/discover/h1")
+	assert.NoError(t, h0.Start(c, a0))
+	assert.NoError(t, h1.Start(c, a1))
+
+	unreachable := net.NewAddr(net.New(), "", "inproc", "/discover/nope")
+
+	n, err := Bootstrap(c, h0, Static{a0, a1, unreachable}, host.TTLTemporary)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []net.PeerID{a1.ID()}, h0.Connected())
+
+	_, err = Bootstrap(c, h0, Static{unreachable}, host.TTLTemporary)
+	assert.Error(t, err)
+}
+
+type mockLeaser struct {
+	in     map[net.PeerID]bool
+	refuse map[net.PeerID]bool
+}
+
+func (m mockLeaser) In(id casm.IDer) bool { return m.in[id.ID()] }
+
+func (m mockLeaser) Lease(_ context.Context, a casm.Addresser) error {
+	if m.refuse[a.Addr().ID()] {
+		return errors.New("refused")
+	}
+	m.in[a.Addr().ID()] = true
+	return nil
+}
+
+func TestLease(t *testing.T) {
+	as := Static{
+		net.NewAddr(net.New(), "", "inproc", "/a0"),
+		net.NewAddr(net.New(), "", "inproc", "/a1"),
+		net.NewAddr(net.New(), "", "inproc", "/a2"),
+		net.NewAddr(net.New(), "", "inproc", "/a3"),
+	}
+
+	l := mockLeaser{
+		in:     map[net.PeerID]bool{as[0].ID(): true},
+		refuse: map[net.PeerID]bool{as[1].ID(): true},
+	}
+
+	n, err := Lease(context.Background(), l, as, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, l.In(as[2]))
+	assert.False(t, l.In(as[3]))
+
+	_, err = Lease(context.Background(), l, as[1:2], 1)
+	assert.Error(t, err)
+}
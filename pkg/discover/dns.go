@@ -0,0 +1,103 @@
+package discover
+
+import (
+	"context"
+	"fmt"
+	gonet "net"
+	"strings"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+)
+
+// Resolver performs DNS lookups.  It is satisfied by *net.Resolver.
+type Resolver interface {
+	LookupTXT(context.Context, string) ([]string, error)
+	LookupSRV(c context.Context, service, proto, name string) (string, []*gonet.SRV, error)
+}
+
+func resolver(r Resolver) Resolver {
+	if r == nil {
+		return gonet.DefaultResolver
+	}
+	return r
+}
+
+// txtPrefix marks TXT records that contain casm addresses.
+const txtPrefix = "casm="
+
+// TXT discovers peers from the TXT records of a domain.  Each record of the
+// form "casm=<peer id>@<proto>://<addr>" yields one address; other records are
+// ignored.  Malformed casm records are skipped, and an error is returned only
+// if none of them could be parsed.
+type TXT struct {
+	Name     string
+	Resolver Resolver   // defaults to net.DefaultResolver
+	Log      log.Logger // reports malformed records; optional
+}
+
+// Discover peers.
+func (t TXT) Discover(c context.Context) ([]net.Addr, error) {
+	records, err := resolver(t.Resolver).LookupTXT(c, t.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "lookup txt")
+	}
+
+	var as []net.Addr
+	for _, r := range records {
+		if !strings.HasPrefix(r, txtPrefix) {
+			continue
+		}
+
+		a, e := net.ParseAddr(strings.TrimPrefix(r, txtPrefix))
+		if e != nil {
+			err = errors.Wrapf(e, "record %q", r)
+			if t.Log != nil {
+				t.Log.WithError(err).WithField("name", t.Name).
+					Warn("skipping malformed txt record")
+			}
+			continue
+		}
+
+		as = append(as, a)
+	}
+
+	if len(as) == 0 && err != nil {
+		return nil, err
+	}
+
+	return as, nil
+}
+
+// SRV discovers peers from the SRV records for _service._proto.name.  Because
+// SRV records cannot carry a peer ID, the first label of each target must be
+// the peer ID, e.g.:  "0123456789abcdef.peers.example.com.".  The resulting
+// addresses use Proto as their transport.
+type SRV struct {
+	Service, Proto, Name string
+	Resolver             Resolver // defaults to net.DefaultResolver
+}
+
+// Discover peers.
+func (s SRV) Discover(c context.Context) ([]net.Addr, error) {
+	_, records, err := resolver(s.Resolver).LookupSRV(c, s.Service, s.Proto, s.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "lookup srv")
+	}
+
+	as := make([]net.Addr, 0, len(records))
+	for _, r := range records {
+		target := strings.TrimSuffix(r.Target, ".")
+
+		id, err := net.ParsePeerID(strings.SplitN(target, ".", 2)[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "target %q", r.Target)
+		}
+
+		hostport := gonet.JoinHostPort(target, fmt.Sprint(r.Port))
+		as = append(as, net.NewAddr(id, s.Proto, s.Proto, hostport))
+	}
+
+	return as, nil
+}
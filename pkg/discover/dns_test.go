@@ -0,0 +1,75 @@
+package discover
+
+import (
+	"context"
+	gonet "net"
+	"testing"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubResolver answers lookups from static tables.
+type stubResolver struct {
+	txt map[string][]string
+	srv map[string][]*gonet.SRV
+}
+
+func (r stubResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if rs, ok := r.txt[name]; ok {
+		return rs, nil
+	}
+	return nil, &gonet.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r stubResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*gonet.SRV, error) {
+	cname := "_" + service + "._" + proto + "." + name
+	if rs, ok := r.srv[cname]; ok {
+		return cname, rs, nil
+	}
+	return "", nil, &gonet.DNSError{Err: "no such host", Name: cname, IsNotFound: true}
+}
+
+func TestDNS(t *testing.T) {
+	id0, id1 := net.New(), net.New()
+	r := stubResolver{
+		txt: map[string][]string{
+			"peers.example.com": {
+				"v=spf1 -all",
+				"casm=garbage",
+				"casm=" + id0.String() + "@tcp://10.0.0.1:9020",
+			},
+			"bad.example.com": {"casm=garbage"},
+		},
+		srv: map[string][]*gonet.SRV{
+			"_casm._tcp.example.com": {
+				{Target: id1.String() + ".peers.example.com.", Port: 9020},
+			},
+		},
+	}
+
+	t.Run("TXT", func(t *testing.T) {
+		as, err := TXT{Name: "peers.example.com", Resolver: r}.Discover(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{id0.String() + "@tcp://10.0.0.1:9020"}, format(as))
+
+		_, err = TXT{Name: "missing.example.com", Resolver: r}.Discover(context.Background())
+		assert.Error(t, err)
+
+		_, err = TXT{Name: "bad.example.com", Resolver: r}.Discover(context.Background())
+		assert.Error(t, err, "no valid records")
+	})
+
+	t.Run("SRV", func(t *testing.T) {
+		as, err := SRV{
+			Service:  "casm",
+			Proto:    "tcp",
+			Name:     "example.com",
+			Resolver: r,
+		}.Discover(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t,
+			[]string{id1.String() + "@tcp://" + id1.String() + ".peers.example.com:9020"},
+			format(as))
+	})
+}
@@ -0,0 +1,127 @@
+package discover
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+// File reads peers from a hosts-style file containing one address per line,
+// formatted as <peer id>@<proto>://<addr>.  Blank lines and text following a
+// '#' are ignored.  The file is re-read whenever its modification time
+// changes.
+type File struct {
+	Path string
+
+	lock  sync.Mutex
+	mtime time.Time
+	as    []net.Addr
+}
+
+// NewFile returns a File discoverer for the path.
+func NewFile(path string) *File { return &File{Path: path} }
+
+// Discover returns the addresses in the file.
+func (f *File) Discover(context.Context) ([]net.Addr, error) {
+	as, _, err := f.load()
+	return as, err
+}
+
+// Watch the file, polling its modification time at the specified interval.
+// The current contents are sent immediately, and again each time they change.
+// The channel is closed when c expires.
+func (f *File) Watch(c context.Context, interval time.Duration) <-chan []net.Addr {
+	ch := make(chan []net.Addr, 1)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// Discover shares the file's cache, so track the modification time
+		// of the last contents sent separately.
+		var last time.Time
+		for {
+			if as, mtime, err := f.load(); err == nil && !mtime.Equal(last) {
+				last = mtime
+				select {
+				case ch <- as:
+				case <-c.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-c.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// load the file's addresses, re-reading it only if it was modified.  It also
+// returns the modification time of the contents.
+func (f *File) load() ([]net.Addr, time.Time, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	fi, err := os.Stat(f.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if fi.ModTime().Equal(f.mtime) {
+		return append([]net.Addr(nil), f.as...), f.mtime, nil
+	}
+
+	r, err := os.Open(f.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer r.Close()
+
+	as, err := ParsePeers(r)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, f.Path)
+	}
+
+	f.as, f.mtime = as, fi.ModTime()
+	return append([]net.Addr(nil), as...), f.mtime, nil
+}
+
+// ParsePeers reads addresses in the format used by File.
+func ParsePeers(r io.Reader) ([]net.Addr, error) {
+	var as []net.Addr
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+
+		a, err := net.ParseAddr(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+
+		as = append(as, a)
+	}
+
+	return as, scanner.Err()
+}
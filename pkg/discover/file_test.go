@@ -0,0 +1,87 @@
+package discover
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePeers(t *testing.T) {
+	id := net.New()
+
+	as, err := ParsePeers(strings.NewReader(`
+# bootstrap nodes
+` + id.String() + `@tcp://10.0.0.1:9020   # primary
+
+`))
+	assert.NoError(t, err)
+	assert.Len(t, as, 1)
+	assert.Equal(t, id, as[0].ID())
+	assert.Equal(t, "10.0.0.1:9020", as[0].String())
+
+	_, err = ParsePeers(strings.NewReader("garbage"))
+	assert.EqualError(t, err, `line 1: invalid address "garbage"`)
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "casm-discover")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "peers")
+	a0 := net.NewAddr(net.New(), "tcp", "tcp", "10.0.0.1:9020")
+	a1 := net.NewAddr(net.New(), "tcp", "tcp", "10.0.0.2:9020")
+
+	write := func(as ...net.Addr) {
+		var lines []string
+		for _, a := range as {
+			lines = append(lines, net.FormatAddr(a))
+		}
+		assert.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644))
+	}
+
+	write(a0)
+	f := NewFile(path)
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	as, err := f.Discover(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{net.FormatAddr(a0)}, format(as))
+
+	ch := f.Watch(c, time.Millisecond*50)
+	assert.Equal(t, []string{net.FormatAddr(a0)}, format(<-ch))
+
+	// ensure the modification time changes on coarse-grained filesystems
+	time.Sleep(time.Millisecond * 10)
+	write(a0, a1)
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+
+	// reading the change before the next poll must not hide it from Watch
+	as, err = f.Discover(c)
+	assert.NoError(t, err)
+	assert.Len(t, as, 2)
+
+	select {
+	case as = <-ch:
+		assert.Equal(t, []string{net.FormatAddr(a0), net.FormatAddr(a1)}, format(as))
+	case <-time.After(time.Second):
+		t.Error("change not detected")
+	}
+}
+
+func format(as []net.Addr) []string {
+	out := make([]string, len(as))
+	for i, a := range as {
+		out[i] = net.FormatAddr(a)
+	}
+	return out
+}
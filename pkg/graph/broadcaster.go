@@ -10,7 +10,9 @@ import (
 	"github.com/lthibault/portal"
 
 	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/metrics"
 	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
 )
 
 // compile-time type constraints
@@ -24,6 +26,13 @@ var fp = framePool(sync.Pool{New: func() interface{} { return new(edgeFrame) }})
 type Broadcaster interface {
 	Send([]byte) error
 	Recv() ([]byte, error)
+
+	// SendContext is like Send, but carries the span in c, if any, so that
+	// receivers can continue the trace.
+	SendContext(context.Context, []byte) error
+	// RecvContext is like Recv, but also returns a context carrying the
+	// sender's span, if any.
+	RecvContext() (context.Context, []byte, error)
 	Publish()
 	Subscribe()
 }
@@ -66,32 +75,68 @@ type broadcast struct {
 	sync.RWMutex
 	es edgeSet
 	f  messageFactory
+	d  *dedup
 	p  portal.Portal
+	m  metrics.Sink
 }
 
 func newBroadcaster(id casm.IDer) *broadcast {
 	return &broadcast{
+		m:  metrics.Discard,
 		f:  newMsgFactory(id.ID()),
+		d:  newDedup(),
 		es: make(map[casm.PeerID]*edgeFrame),
 		p:  portal.New(bus.New(), portal.OptAsync(bufSize)),
 	}
 }
 
-func (b *broadcast) Send(msg []byte) error { return b.sendMsg(b.f(msg)) }
+func (b *broadcast) Send(msg []byte) error {
+	return b.SendContext(context.Background(), msg)
+}
+
+func (b *broadcast) SendContext(c context.Context, msg []byte) (err error) {
+	m := b.f(msg)
+	m.span, _ = trace.Extract(c)
+
+	if err = b.sendMsg(m); err == nil {
+		b.m.IncrCounter(MetricMessages, 1, metrics.L("direction", "sent"))
+	}
+	return
+}
 
 func (b *broadcast) sendMsg(m *message) error {
 	panic("sendMsg NOT IMPLEMENTED")
 }
 
-func (b *broadcast) Recv() (msg []byte, err error) {
-	var m *message
-	defer m.Free()
+func (b *broadcast) Recv() ([]byte, error) {
+	_, msg, err := b.RecvContext()
+	return msg, err
+}
 
-	if m, err = b.recvMsg(); err == nil {
-		msg = m.Body()
+// RecvContext returns the next message, along with a context carrying the
+// sender's span.  The body is copied, since the message is returned to the
+// pool before RecvContext returns.
+func (b *broadcast) RecvContext() (context.Context, []byte, error) {
+	m, err := b.recvMsg()
+	if err != nil {
+		return nil, nil, err
 	}
+	defer m.Free()
 
-	return
+	b.m.IncrCounter(MetricMessages, 1, metrics.L("direction", "received"))
+	body := append([]byte(nil), m.Body()...)
+	return trace.Inject(context.Background(), m.Span()), body, nil
+}
+
+// accept returns false if the message was already received, in which case it
+// is dropped.  Messages received from edges MUST pass through accept before
+// being delivered or forwarded.
+func (b *broadcast) accept(m *message) bool {
+	if b.d.Seen(m.ID(), m.Sequence()) {
+		b.m.IncrCounter(MetricDuplicates, 1)
+		return false
+	}
+	return true
 }
 
 func (b *broadcast) recvMsg() (*message, error) {
@@ -111,6 +156,7 @@ func (b *broadcast) AddEdge(e Edge) {
 	defer b.Unlock()
 
 	b.es.Add(fp.Get(e))
+	b.m.SetGauge(MetricEdges, float64(len(b.es)))
 
 	//
 	//  TODO:  implement *edge.  Ensure its contexts properly terminate.
@@ -137,7 +183,7 @@ func (b *broadcast) AddEdge(e Edge) {
 	// 				panic("TODO:  log the error")
 	// 			}
 	// 		}
-	// 		panic("TODO:  receive messages from edges")
+	// 		panic("TODO:  receive messages from edges; drop those that fail b.accept")
 	// 	}
 	// }()
 }
@@ -148,8 +194,21 @@ func (b *broadcast) RemoveEdge(id casm.IDer) (e Edge, ok bool) {
 
 	var f *edgeFrame
 	if f, ok = b.es.Get(id); ok {
+		delete(b.es, id.ID())
+		b.m.SetGauge(MetricEdges, float64(len(b.es)))
 		e = f.Edge
 		f.Free()
 	}
 	return
 }
+
+func (b *broadcast) Peers() []net.PeerID {
+	b.RLock()
+	defer b.RUnlock()
+
+	ids := make([]net.PeerID, 0, len(b.es))
+	for id := range b.es {
+		ids = append(ids, id)
+	}
+	return ids
+}
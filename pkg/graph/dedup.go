@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"sync"
+
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+// dedupWindow is the number of sequence numbers below the highest one seen
+// from a sender for which delivery is tracked.  Older messages are treated as
+// duplicates.
+const dedupWindow = 64
+
+// dedup detects messages that were already received, e.g. over another edge.
+// Senders number their messages sequentially, so a sliding window of sequence
+// numbers is kept for each sender.
+type dedup struct {
+	lock sync.Mutex
+	m    map[net.PeerID]*seqWindow
+}
+
+// seqWindow records the sequence numbers in (max-dedupWindow, max] that were
+// received.  Bit i of seen is set if max-i was received.
+type seqWindow struct {
+	max  uint64
+	seen uint64
+}
+
+func newDedup() *dedup { return &dedup{m: make(map[net.PeerID]*seqWindow)} }
+
+// Seen returns true if the message was already received.  Otherwise, the
+// message is recorded as received.
+func (d *dedup) Seen(id net.PeerID, seq uint64) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	w, ok := d.m[id]
+	if !ok {
+		d.m[id] = &seqWindow{max: seq, seen: 1}
+		return false
+	}
+
+	if seq > w.max {
+		if shift := seq - w.max; shift < dedupWindow {
+			w.seen = w.seen<<shift | 1
+		} else {
+			w.seen = 1
+		}
+		w.max = seq
+		return false
+	}
+
+	diff := w.max - seq
+	if diff >= dedupWindow {
+		return true
+	}
+
+	bit := uint64(1) << diff
+	if w.seen&bit != 0 {
+		return true
+	}
+
+	w.seen |= bit
+	return false
+}
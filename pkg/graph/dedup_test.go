@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"testing"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedup(t *testing.T) {
+	d := newDedup()
+	id0, id1 := net.PeerID(1), net.PeerID(2)
+
+	t.Run("InOrder", func(t *testing.T) {
+		for seq := uint64(1); seq <= 3; seq++ {
+			assert.False(t, d.Seen(id0, seq))
+			assert.True(t, d.Seen(id0, seq))
+		}
+	})
+
+	t.Run("PerSender", func(t *testing.T) {
+		assert.False(t, d.Seen(id1, 1))
+	})
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		assert.False(t, d.Seen(id0, 10))
+		assert.False(t, d.Seen(id0, 5))
+		assert.True(t, d.Seen(id0, 5))
+		assert.True(t, d.Seen(id0, 3))
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		assert.False(t, d.Seen(id0, 10+dedupWindow))
+		assert.True(t, d.Seen(id0, 10), "message outside the window")
+		assert.False(t, d.Seen(id0, 11))
+	})
+}
@@ -8,7 +8,10 @@ import (
 
 	"github.com/lthibault/casm/api/graph"
 	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/msgio"
 	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
+	"github.com/pkg/errors"
 	capnp "zombiezen.com/go/capnproto2"
 )
 
@@ -20,6 +23,7 @@ type Messager interface {
 	Sequence() uint64
 	Header() []byte
 	Body() []byte
+	Span() trace.SpanContext
 	Ref()
 	Free()
 	WriteTo(io.Writer) (int64, error)
@@ -53,9 +57,10 @@ var msgPool = messagePool(sync.Pool{New: func() interface{} {
 }})
 
 type message struct {
-	cm  *capnp.Message
-	m   graph.Message
-	ctr uint32
+	cm   *capnp.Message
+	m    graph.Message
+	span trace.SpanContext
+	ctr  uint32
 }
 
 // ID of the sender
@@ -78,6 +83,10 @@ func (m message) Body() []byte {
 	return b
 }
 
+// Span under which the message was sent.  It is invalid if the sender was not
+// tracing.
+func (m message) Span() trace.SpanContext { return m.span }
+
 // Ref increases the reference count for the message
 func (m *message) Ref() { atomic.AddUint32(&m.ctr, 1) }
 
@@ -89,11 +98,66 @@ func (m *message) Free() {
 	}
 }
 
+// WriteTo writes the message to w as a single length-prefixed frame.  If w is a
+// msgio.Writer, its framing (and size limit) is used.
+//
+// The frame begins with the message's span, followed by the capnp message.
 func (m *message) WriteTo(w io.Writer) (int64, error) {
-	panic("WriteTo NOT IMPLEMENTED")
+	cb, err := m.cm.Marshal()
+	if err != nil {
+		return 0, errors.Wrap(err, "marshal")
+	}
+
+	span, _ := m.span.MarshalBinary()
+	b := append(span, cb...)
+
+	mw, ok := w.(msgio.Writer)
+	if !ok {
+		mw = msgio.NewWriter(w, 0)
+	}
+
+	if err = mw.WriteMsg(b); err != nil {
+		return 0, err
+	}
+
+	return int64(len(b)), nil
 }
+
+// ReadFrom reads a single length-prefixed frame from r into the message.  If r
+// is a msgio.Reader, its framing (and size limit) is used.
 func (m *message) ReadFrom(r io.Reader) (int64, error) {
-	panic("ReadFrom NOT IMPLEMENTED")
+	mr, ok := r.(msgio.Reader)
+	if !ok {
+		mr = msgio.NewReader(r, 0)
+	}
+
+	b, err := mr.ReadMsg()
+	if err != nil {
+		return 0, err
+	}
+
+	n := int64(len(b))
+	if len(b) < trace.Size {
+		mr.ReleaseMsg(b)
+		return 0, errors.New("missing span")
+	}
+
+	m.span.UnmarshalBinary(b[:trace.Size]) // length checked above
+
+	// capnp.Unmarshal retains b, so copy it out of the pooled buffer.
+	buf := make([]byte, len(b)-trace.Size)
+	copy(buf, b[trace.Size:])
+	mr.ReleaseMsg(b)
+
+	if m.cm, err = capnp.Unmarshal(buf); err != nil {
+		return 0, errors.Wrap(err, "unmarshal")
+	}
+
+	if m.m, err = graph.ReadRootMessage(m.cm); err != nil {
+		return 0, errors.Wrap(err, "read root")
+	}
+
+	return n, nil
 }
 
 type messageFactory func([]byte) *message
@@ -105,6 +169,7 @@ func newMsgFactory(pid net.PeerID) func([]byte) *message {
 		msg.m.SetId(uint64(pid))
 		msg.m.SetSeq(atomic.AddUint64(&seq, 1))
 		msg.m.SetBody(b)
+		msg.span = trace.SpanContext{}
 		return
 	}
 }
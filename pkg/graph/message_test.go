@@ -1,9 +1,12 @@
 package graph
 
 import (
+	"bytes"
+	"context"
 	"testing"
 
 	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,4 +57,38 @@ func TestMessage(t *testing.T) {
 		})
 	})
 
+	t.Run("WriteReadRoundTrip", func(t *testing.T) {
+		msg := newMsgFactory(id)([]byte("body"))
+		defer msg.Free()
+
+		var buf bytes.Buffer
+		_, err := msg.WriteTo(&buf)
+		assert.NoError(t, err)
+
+		var got message
+		_, err = got.ReadFrom(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, id, got.ID())
+		assert.Equal(t, uint64(1), got.Sequence())
+		assert.Equal(t, []byte("body"), got.Body())
+		assert.False(t, got.Span().IsValid())
+	})
+
+	t.Run("Span", func(t *testing.T) {
+		_, sc := trace.Start(context.Background())
+
+		msg := newMsgFactory(id)([]byte("body"))
+		defer msg.Free()
+		msg.span = sc
+
+		var buf bytes.Buffer
+		_, err := msg.WriteTo(&buf)
+		assert.NoError(t, err)
+
+		var got message
+		_, err = got.ReadFrom(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, sc, got.Span())
+		assert.Equal(t, []byte("body"), got.Body())
+	})
 }
@@ -0,0 +1,20 @@
+package graph
+
+import "github.com/lthibault/casm/pkg/metrics"
+
+// Metrics reported by the V.  See OptMetrics.
+const (
+	MetricEdges      = "casm_graph_edges"
+	MetricMessages   = "casm_graph_broadcast_messages_total"
+	MetricDuplicates = "casm_graph_broadcast_duplicates_total"
+)
+
+// DescribeMetrics registers help text for the graph's metrics.
+func DescribeMetrics(d metrics.Describer) {
+	d.Describe(MetricEdges, metrics.Gauge,
+		"Number of edges held by the vertex.")
+	d.Describe(MetricMessages, metrics.Counter,
+		"Broadcast messages sent and received, by direction.")
+	d.Describe(MetricDuplicates, metrics.Counter,
+		"Broadcast messages dropped because they were already received.")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	casm "github.com/lthibault/casm/pkg"
+	net "github.com/lthibault/casm/pkg/net"
 )
 
 // Neighborhood is a view of peers adjancent to a given Vertex.
@@ -11,4 +12,5 @@ type Neighborhood interface {
 	In(casm.IDer) bool
 	Lease(context.Context, casm.Addresser) error
 	Evict(casm.IDer)
+	Edges() []net.PeerID
 }
@@ -1,5 +1,7 @@
 package graph
 
+import "github.com/lthibault/casm/pkg/metrics"
+
 const (
 	defaultK uint8 = 5
 	defaultL uint8 = 1
@@ -25,6 +27,18 @@ func OptElasticity(l uint8) Option {
 	}
 }
 
+// OptMetrics reports the V's metrics to the Sink.
+func OptMetrics(m metrics.Sink) Option {
+	return func(v *vertex) (err error) {
+		if m == nil {
+			m = metrics.Discard
+		}
+
+		v.m = m
+		return
+	}
+}
+
 // OptDefault sets the default options for a V
 func OptDefault() Option {
 	return func(v *vertex) (err error) {
@@ -39,6 +53,7 @@ func OptDefault() Option {
 		apply(
 			OptCardinality(defaultK),
 			OptElasticity(defaultL),
+			OptMetrics(nil),
 		)
 
 		return
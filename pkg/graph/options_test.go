@@ -3,6 +3,7 @@ package graph
 import (
 	"testing"
 
+	"github.com/lthibault/casm/pkg/metrics"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,4 +25,12 @@ func TestOptions(t *testing.T) {
 			assert.Equal(t, defaultL, v.l)
 		})
 	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		t.Run("Default", func(t *testing.T) {
+			err := OptMetrics(nil)(v)
+			assert.NoError(t, err)
+			assert.Equal(t, metrics.Discard, v.m)
+		})
+	})
 }
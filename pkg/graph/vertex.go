@@ -7,6 +7,7 @@ import (
 
 	casm "github.com/lthibault/casm/pkg"
 	"github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/metrics"
 	net "github.com/lthibault/casm/pkg/net"
 )
 
@@ -30,6 +31,7 @@ type Vertex interface {
 type vertex struct {
 	h    host.Host
 	k, l uint8
+	m    metrics.Sink
 	b    *broadcast
 	en   *edgeNegotiator
 }
@@ -48,6 +50,7 @@ func (v *vertex) configure(opt []Option) (err error) {
 			break
 		}
 	}
+	v.b.m = v.m
 
 	v.h.Stream().Register(pathEdgeData, net.HandlerFunc(v.initEdgeData))
 	v.h.Stream().Register(pathEdgeCtrl, net.HandlerFunc(v.initEdgeCtrl))
@@ -99,6 +102,9 @@ func (v vertex) Evict(id casm.IDer) {
 	}
 }
 
+// Edges returns the IDs of peers to which the vertex has an edge
+func (v vertex) Edges() []net.PeerID { return v.b.Peers() }
+
 func (v vertex) initEdgeData(s net.Stream) {
 	c, cancel := context.WithTimeout(s.Context(), time.Second*10)
 	defer cancel()
@@ -0,0 +1,393 @@
+package host
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+)
+
+const (
+	// TTLPermanent addresses never expire.
+	TTLPermanent time.Duration = math.MaxInt64
+
+	// TTLConnected is applied to addresses that were observed on a live
+	// connection.
+	TTLConnected = time.Minute * 30
+
+	// TTLTemporary is applied to addresses that have not yet been confirmed by
+	// a successful connection.
+	TTLTemporary = time.Minute * 2
+
+	addrBookGCInterval = time.Minute
+)
+
+// AddrSource indicates how an address was learned.  Lower values take
+// precedence when several sources report the same address.
+type AddrSource uint8
+
+const (
+	// SourceManual addresses were explicitly provided by the user.
+	SourceManual AddrSource = iota
+	// SourceDialback addresses were announced by the remote peer during the
+	// connection upgrade.
+	SourceDialback
+	// SourceIdentify addresses were reported by the remote peer over an
+	// established connection.
+	SourceIdentify
+	// SourceDiscovery addresses were obtained from a third party.
+	SourceDiscovery
+)
+
+func (s AddrSource) String() string {
+	switch s {
+	case SourceManual:
+		return "manual"
+	case SourceDialback:
+		return "dialback"
+	case SourceIdentify:
+		return "identify"
+	case SourceDiscovery:
+		return "discovery"
+	default:
+		return "unknown"
+	}
+}
+
+type addrEntry struct {
+	net.Addr
+	src AddrSource
+	exp time.Time // zero value means the entry never expires
+}
+
+func (e addrEntry) expired(t time.Time) bool {
+	return !e.exp.IsZero() && t.After(e.exp)
+}
+
+func addrKey(a net.Addr) string {
+	return a.Network() + "/" + a.Proto() + "/" + a.String()
+}
+
+// peerRecord holds everything known about a remote peer.
+type peerRecord struct {
+	addrs  map[string]*addrEntry
+	meta   map[string]string
+	banned bool
+}
+
+func newPeerRecord() *peerRecord {
+	return &peerRecord{
+		addrs: make(map[string]*addrEntry),
+		meta:  make(map[string]string),
+	}
+}
+
+func (r peerRecord) empty() bool {
+	return len(r.addrs) == 0 && len(r.meta) == 0 && !r.banned
+}
+
+func (r peerRecord) export(id net.PeerID) PeerRecord {
+	rec := PeerRecord{ID: id, Banned: r.banned}
+	if len(r.meta) > 0 {
+		rec.Meta = make(map[string]string, len(r.meta))
+		for k, v := range r.meta {
+			rec.Meta[k] = v
+		}
+	}
+
+	for _, e := range r.addrs {
+		rec.Addrs = append(rec.Addrs, AddrRecord{
+			Network: e.Network(),
+			Proto:   e.Proto(),
+			Addr:    e.String(),
+			Source:  e.src,
+			Expires: e.exp,
+		})
+	}
+	return rec
+}
+
+// addrBook maps peers to the addresses at which they can be reached, along with
+// arbitrary metadata.  Unlike the peerStore, entries outlive connections.  If
+// a Datastore is provided, changes are written through to it.
+type addrBook struct {
+	sync.RWMutex
+	log log.Logger
+	ds  Datastore
+	m   map[net.PeerID]*peerRecord
+}
+
+func newAddrBook(l log.Logger, ds Datastore) *addrBook {
+	return (&addrBook{log: l, ds: ds}).Reset()
+}
+
+// persistUnsafe writes the current state of a peer's record to the Datastore.
+func (b *addrBook) persistUnsafe(id net.PeerID) {
+	if b.ds == nil {
+		return
+	}
+
+	var err error
+	if r, ok := b.m[id]; !ok || r.empty() {
+		err = b.ds.Delete(id)
+	} else {
+		err = b.ds.Put(r.export(id))
+	}
+
+	if err != nil {
+		b.log.WithError(err).WithField("peer", id).Warn("failed to persist")
+	}
+}
+
+// Load the contents of the Datastore into the book.  Addresses that expired
+// while the Host was down are kept for TTLTemporary, as low-priority
+// candidates, so that the Host can rejoin its previous neighbors.
+func (b *addrBook) Load() error {
+	if b.ds == nil {
+		return nil
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	now := time.Now()
+	return b.ds.Load(func(rec PeerRecord) {
+		r := b.recordUnsafe(rec.ID)
+		r.banned = rec.Banned
+		for k, v := range rec.Meta {
+			r.meta[k] = v
+		}
+
+		for _, ar := range rec.Addrs {
+			e := &addrEntry{
+				Addr: net.NewAddr(rec.ID, ar.Network, ar.Proto, ar.Addr),
+				src:  ar.Source,
+				exp:  ar.Expires,
+			}
+
+			if e.expired(now) {
+				e.exp = now.Add(TTLTemporary)
+			}
+
+			r.addrs[addrKey(e.Addr)] = e
+		}
+	})
+}
+
+func (b *addrBook) recordUnsafe(id net.PeerID) (r *peerRecord) {
+	var ok bool
+	if r, ok = b.m[id]; !ok {
+		r = newPeerRecord()
+		b.m[id] = r
+	}
+	return
+}
+
+// Add an address to the book, or refresh it if it is already present.  A TTL
+// of zero or less removes the address.
+func (b *addrBook) Add(a net.Addr, src AddrSource, ttl time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	r := b.recordUnsafe(a.ID())
+	k := addrKey(a)
+	defer b.persistUnsafe(a.ID())
+
+	if ttl <= 0 {
+		delete(r.addrs, k)
+		return
+	}
+
+	var exp time.Time
+	if ttl != TTLPermanent {
+		exp = time.Now().Add(ttl)
+	}
+
+	e, ok := r.addrs[k]
+	if !ok {
+		r.addrs[k] = &addrEntry{Addr: a, src: src, exp: exp}
+		return
+	}
+
+	if src < e.src {
+		e.src = src
+	}
+
+	if exp.IsZero() || (!e.exp.IsZero() && exp.After(e.exp)) {
+		e.exp = exp
+	}
+}
+
+// Confirm that an address is reachable, extending its TTL to at least
+// TTLConnected without altering its source.
+func (b *addrBook) Confirm(a net.Addr) {
+	b.Lock()
+	defer b.Unlock()
+
+	r := b.recordUnsafe(a.ID())
+	k := addrKey(a)
+	exp := time.Now().Add(TTLConnected)
+	defer b.persistUnsafe(a.ID())
+
+	e, ok := r.addrs[k]
+	switch {
+	case !ok:
+		r.addrs[k] = &addrEntry{Addr: a, src: SourceManual, exp: exp}
+	case !e.exp.IsZero() && exp.After(e.exp):
+		e.exp = exp
+	}
+}
+
+// Addrs returns the unexpired addresses of a peer, ordered by source
+// precedence, then by remaining TTL.
+func (b *addrBook) Addrs(id casm.IDer) []net.Addr {
+	b.Lock()
+	defer b.Unlock()
+
+	r, ok := b.m[id.ID()]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	n := len(r.addrs)
+	es := make([]*addrEntry, 0, n)
+	for k, e := range r.addrs {
+		if e.expired(now) {
+			delete(r.addrs, k)
+			continue
+		}
+		es = append(es, e)
+	}
+
+	if len(es) != n {
+		b.persistUnsafe(id.ID())
+	}
+
+	sort.Slice(es, func(i, j int) bool {
+		switch {
+		case es[i].src != es[j].src:
+			return es[i].src < es[j].src
+		case es[i].exp.IsZero():
+			return !es[j].exp.IsZero()
+		case es[j].exp.IsZero():
+			return false
+		default:
+			return es[i].exp.After(es[j].exp)
+		}
+	})
+
+	as := make([]net.Addr, len(es))
+	for i, e := range es {
+		as[i] = e.Addr
+	}
+
+	return as
+}
+
+// SetMeta associates a key-value pair with a peer.  An empty value deletes
+// the key.
+func (b *addrBook) SetMeta(id casm.IDer, key, val string) {
+	b.Lock()
+	if val == "" {
+		if r, ok := b.m[id.ID()]; ok {
+			delete(r.meta, key)
+		}
+	} else {
+		b.recordUnsafe(id.ID()).meta[key] = val
+	}
+	b.persistUnsafe(id.ID())
+	b.Unlock()
+}
+
+// Meta retrieves the value associated with key for the specified peer.
+func (b *addrBook) Meta(id casm.IDer, key string) (val string, found bool) {
+	b.RLock()
+	if r, ok := b.m[id.ID()]; ok {
+		val, found = r.meta[key]
+	}
+	b.RUnlock()
+	return
+}
+
+// SetBanned adds or removes a peer from the ban list.
+func (b *addrBook) SetBanned(id casm.IDer, banned bool) {
+	b.Lock()
+	if banned {
+		b.recordUnsafe(id.ID()).banned = true
+	} else if r, ok := b.m[id.ID()]; ok {
+		r.banned = false
+	}
+	b.persistUnsafe(id.ID())
+	b.Unlock()
+}
+
+// Banned returns true if the peer is on the ban list.
+func (b *addrBook) Banned(id casm.IDer) (banned bool) {
+	b.RLock()
+	if r, ok := b.m[id.ID()]; ok {
+		banned = r.banned
+	}
+	b.RUnlock()
+	return
+}
+
+// Peers returns the IDs of all peers for which a record exists.
+func (b *addrBook) Peers() []net.PeerID {
+	b.RLock()
+	defer b.RUnlock()
+
+	ids := make([]net.PeerID, 0, len(b.m))
+	for id := range b.m {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Records returns a snapshot of every peer record, sorted by ID.
+func (b *addrBook) Records() []PeerRecord {
+	b.RLock()
+	rs := make([]PeerRecord, 0, len(b.m))
+	for id, r := range b.m {
+		rs = append(rs, r.export(id))
+	}
+	b.RUnlock()
+
+	sort.Slice(rs, func(i, j int) bool { return rs[i].ID < rs[j].ID })
+	return rs
+}
+
+// GC removes expired addresses, as well as peers that no longer have any
+// addresses or metadata.  The Host calls it every addrBookGCInterval.
+func (b *addrBook) GC() {
+	b.Lock()
+	defer b.Unlock()
+
+	now := time.Now()
+	for id, r := range b.m {
+		n := len(r.addrs)
+		for k, e := range r.addrs {
+			if e.expired(now) {
+				delete(r.addrs, k)
+			}
+		}
+
+		if r.empty() {
+			delete(b.m, id)
+			b.persistUnsafe(id)
+		} else if len(r.addrs) != n {
+			b.persistUnsafe(id)
+		}
+	}
+}
+
+func (b *addrBook) Reset() *addrBook {
+	b.Lock()
+	b.m = make(map[net.PeerID]*peerRecord)
+	b.Unlock()
+	return b
+}
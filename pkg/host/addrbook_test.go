@@ -0,0 +1,158 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddrSource(t *testing.T) {
+	assert.Equal(t, "manual", SourceManual.String())
+	assert.Equal(t, "dialback", SourceDialback.String())
+	assert.Equal(t, "identify", SourceIdentify.String())
+	assert.Equal(t, "discovery", SourceDiscovery.String())
+	assert.Equal(t, "unknown", AddrSource(255).String())
+}
+
+func TestAddrBook(t *testing.T) {
+	id := net.New()
+	b := newAddrBook(log.New(log.OptLevel(log.NullLevel)), nil)
+
+	disc := net.NewAddr(id, "", "inproc", "/discovered")
+	man := net.NewAddr(id, "", "inproc", "/manual")
+
+	t.Run("Add", func(t *testing.T) {
+		b.Add(disc, SourceDiscovery, TTLTemporary)
+		b.Add(man, SourceManual, TTLPermanent)
+		assert.Len(t, b.m[id].addrs, 2)
+
+		t.Run("Refresh", func(t *testing.T) {
+			b.Add(disc, SourceDiscovery, TTLConnected)
+			assert.Len(t, b.m[id].addrs, 2)
+			assert.WithinDuration(t,
+				time.Now().Add(TTLConnected),
+				b.m[id].addrs[addrKey(disc)].exp,
+				time.Second)
+		})
+
+		t.Run("Promote", func(t *testing.T) {
+			b.Add(disc, SourceIdentify, TTLTemporary)
+			assert.Equal(t, SourceIdentify, b.m[id].addrs[addrKey(disc)].src)
+
+			b.Add(disc, SourceDiscovery, TTLTemporary)
+			assert.Equal(t, SourceIdentify, b.m[id].addrs[addrKey(disc)].src)
+		})
+	})
+
+	t.Run("Addrs", func(t *testing.T) {
+		t.Run("Ordered", func(t *testing.T) {
+			as := b.Addrs(id)
+			assert.Len(t, as, 2)
+			assert.Equal(t, man, as[0])
+			assert.Equal(t, disc, as[1])
+		})
+
+		t.Run("Unknown", func(t *testing.T) {
+			assert.Empty(t, b.Addrs(net.New()))
+		})
+
+		t.Run("Expired", func(t *testing.T) {
+			b.m[id].addrs[addrKey(disc)].exp = time.Now().Add(-time.Second)
+			assert.Equal(t, []net.Addr{man}, b.Addrs(id))
+			assert.NotContains(t, b.m[id].addrs, addrKey(disc))
+		})
+	})
+
+	t.Run("Confirm", func(t *testing.T) {
+		b.Confirm(disc)
+		assert.Equal(t, SourceManual, b.m[id].addrs[addrKey(disc)].src)
+
+		b.Confirm(man)
+		assert.True(t, b.m[id].addrs[addrKey(man)].exp.IsZero())
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		b.Add(disc, SourceDiscovery, 0)
+		assert.Equal(t, []net.Addr{man}, b.Addrs(id))
+	})
+
+	t.Run("Meta", func(t *testing.T) {
+		b.SetMeta(id, "role", "worker")
+
+		v, ok := b.Meta(id, "role")
+		assert.True(t, ok)
+		assert.Equal(t, "worker", v)
+
+		b.SetMeta(id, "role", "")
+		_, ok = b.Meta(id, "role")
+		assert.False(t, ok)
+	})
+
+	t.Run("Ban", func(t *testing.T) {
+		assert.False(t, b.Banned(id))
+		b.SetBanned(id, true)
+		assert.True(t, b.Banned(id))
+		b.SetBanned(id, false)
+		assert.False(t, b.Banned(id))
+	})
+
+	t.Run("GC", func(t *testing.T) {
+		b.m[id].addrs[addrKey(man)].exp = time.Now().Add(-time.Second)
+		b.GC()
+		assert.NotContains(t, b.m, id)
+	})
+}
+
+// memStore is a Datastore backed by a map.
+type memStore map[net.PeerID]PeerRecord
+
+func (s memStore) Load(fn func(PeerRecord)) error {
+	for _, rec := range s {
+		fn(rec)
+	}
+	return nil
+}
+
+func (s memStore) Put(rec PeerRecord) error {
+	s[rec.ID] = rec
+	return nil
+}
+
+func (s memStore) Delete(id net.PeerID) error {
+	delete(s, id)
+	return nil
+}
+
+func TestAddrBookExpiry(t *testing.T) {
+	id := net.New()
+	ds := make(memStore)
+	b := newAddrBook(log.New(log.OptLevel(log.NullLevel)), ds)
+
+	a0 := net.NewAddr(id, "", "inproc", "/expiry/0")
+	a1 := net.NewAddr(id, "", "inproc", "/expiry/1")
+	b.Add(a0, SourceManual, TTLTemporary)
+	b.Add(a1, SourceManual, TTLTemporary)
+
+	t.Run("Addrs", func(t *testing.T) {
+		b.m[id].addrs[addrKey(a0)].exp = time.Now().Add(-time.Second)
+		assert.Len(t, b.Addrs(id), 1)
+		assert.Len(t, ds[id].Addrs, 1, "deletion was not persisted")
+	})
+
+	t.Run("GC", func(t *testing.T) {
+		b.Add(a0, SourceManual, TTLTemporary)
+		b.SetMeta(id, "role", "worker")
+		b.m[id].addrs[addrKey(a0)].exp = time.Now().Add(-time.Second)
+
+		b.GC()
+		assert.Len(t, ds[id].Addrs, 1, "deletion was not persisted")
+
+		b.SetMeta(id, "role", "")
+		b.m[id].addrs[addrKey(a1)].exp = time.Now().Add(-time.Second)
+		b.GC()
+		assert.NotContains(t, ds, id)
+	})
+}
@@ -0,0 +1,207 @@
+package host
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+// compactThreshold is the minimum number of superseded log entries before a
+// FileStore rewrites its log.
+const compactThreshold = 64
+
+// AddrRecord is the persistent representation of an address book entry.
+type AddrRecord struct {
+	Network string     `json:"net"`
+	Proto   string     `json:"proto"`
+	Addr    string     `json:"addr"`
+	Source  AddrSource `json:"src"`
+	Expires time.Time  `json:"exp"` // zero if the address never expires
+}
+
+// PeerRecord is the persistent representation of everything a Host knows about
+// a remote peer.
+type PeerRecord struct {
+	ID     net.PeerID        `json:"id"`
+	Addrs  []AddrRecord      `json:"addrs,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+	Banned bool              `json:"banned,omitempty"`
+}
+
+// Datastore persists peer records across Host restarts.
+type Datastore interface {
+	// Load calls fn for each stored record.
+	Load(fn func(PeerRecord)) error
+	// Put creates or replaces the record for rec.ID.
+	Put(rec PeerRecord) error
+	// Delete the record for the specified peer.
+	Delete(net.PeerID) error
+}
+
+// logEntry is a single line in a FileStore's log.  Records with Deleted set
+// are tombstones.
+type logEntry struct {
+	PeerRecord
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// FileStore is a Datastore backed by an append-only log of JSON records.  The
+// log is compacted when superseded entries accumulate.
+type FileStore struct {
+	lock sync.Mutex
+	path string
+	f    *os.File
+	m    map[net.PeerID]PeerRecord
+	n    int // number of entries in the log
+}
+
+// OpenFileStore at the specified path, creating the file if it does not exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, m: make(map[net.PeerID]PeerRecord)}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open")
+	}
+	defer f.Close()
+
+	if err = s.replay(f); err != nil {
+		return nil, errors.Wrap(err, "replay")
+	}
+
+	if err = s.compact(); err != nil {
+		return nil, errors.Wrap(err, "compact")
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) replay(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		// Skip lines that fail to parse, such as a torn write at the tail of
+		// the log after a crash, rather than discarding the records that
+		// follow them.  The compaction that follows replay removes them.
+		var e logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+
+		if e.Deleted {
+			delete(s.m, e.ID)
+		} else {
+			s.m[e.ID] = e.PeerRecord
+		}
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the log so that it contains exactly one entry per record.
+func (s *FileStore) compact() (err error) {
+	tmp := s.path + ".tmp"
+
+	var f *os.File
+	if f, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+		return
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, rec := range s.m {
+		if err = enc.Encode(logEntry{PeerRecord: rec}); err != nil {
+			f.Close()
+			return
+		}
+	}
+
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+
+	if err = os.Rename(tmp, s.path); err != nil {
+		return
+	}
+
+	if s.f != nil {
+		s.f.Close()
+	}
+
+	s.n = len(s.m)
+	s.f, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	return
+}
+
+func (s *FileStore) appendUnsafe(e logEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	if _, err = s.f.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "write")
+	}
+	s.n++
+
+	if s.n-len(s.m) > compactThreshold && s.n > 2*len(s.m) {
+		return errors.Wrap(s.compact(), "compact")
+	}
+
+	return nil
+}
+
+// Load satisfies Datastore.
+func (s *FileStore) Load(fn func(PeerRecord)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, rec := range s.m {
+		fn(rec)
+	}
+
+	return nil
+}
+
+// Put satisfies Datastore.
+func (s *FileStore) Put(rec PeerRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.m[rec.ID] = rec
+	return s.appendUnsafe(logEntry{PeerRecord: rec})
+}
+
+// Delete satisfies Datastore.
+func (s *FileStore) Delete(id net.PeerID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.m[id]; !ok {
+		return nil
+	}
+
+	delete(s.m, id)
+	return s.appendUnsafe(logEntry{PeerRecord: PeerRecord{ID: id}, Deleted: true})
+}
+
+// Close the underlying file.
+func (s *FileStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.f.Close()
+}
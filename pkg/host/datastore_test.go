@@ -0,0 +1,154 @@
+package host
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "casm-filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "peers.db")
+	id0, id1 := net.New(), net.New()
+
+	load := func(s *FileStore) map[net.PeerID]PeerRecord {
+		m := make(map[net.PeerID]PeerRecord)
+		assert.NoError(t, s.Load(func(rec PeerRecord) { m[rec.ID] = rec }))
+		return m
+	}
+
+	t.Run("PutDelete", func(t *testing.T) {
+		s, err := OpenFileStore(path)
+		assert.NoError(t, err)
+		defer s.Close()
+
+		assert.NoError(t, s.Put(PeerRecord{ID: id0, Meta: map[string]string{"k": "v"}}))
+		assert.NoError(t, s.Put(PeerRecord{ID: id1, Banned: true}))
+		assert.NoError(t, s.Delete(id1))
+		assert.NoError(t, s.Delete(net.New())) // no-op
+		assert.Len(t, load(s), 1)
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		s, err := OpenFileStore(path)
+		assert.NoError(t, err)
+		defer s.Close()
+
+		m := load(s)
+		assert.Len(t, m, 1)
+		assert.Equal(t, "v", m[id0].Meta["k"])
+		assert.Equal(t, 1, s.n, "log was not compacted on open")
+	})
+
+	t.Run("TornWrite", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+		assert.NoError(t, err)
+		f.WriteString(`{"id":12`)
+		f.Close()
+
+		s, err := OpenFileStore(path)
+		assert.NoError(t, err)
+		defer s.Close()
+		assert.Len(t, load(s), 1)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+		assert.NoError(t, err)
+		f.WriteString("garbage\n")
+		f.WriteString(`{"id":` + strconv.FormatUint(uint64(id1), 10) + "}\n")
+		f.Close()
+
+		s, err := OpenFileStore(path)
+		assert.NoError(t, err)
+		defer s.Close()
+		assert.Len(t, load(s), 2, "records after a corrupt line were lost")
+		assert.NoError(t, s.Delete(id1))
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		s, err := OpenFileStore(path)
+		assert.NoError(t, err)
+		defer s.Close()
+
+		for i := 0; i < compactThreshold*2; i++ {
+			assert.NoError(t, s.Put(PeerRecord{ID: id0}))
+		}
+		assert.True(t, s.n <= compactThreshold+1)
+	})
+}
+
+func TestAddrBookPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "casm-addrbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "peers.db")
+	l := log.New(log.OptLevel(log.NullLevel))
+	a := net.NewAddr(net.New(), "", "inproc", "/persist")
+
+	s, err := OpenFileStore(path)
+	assert.NoError(t, err)
+
+	b := newAddrBook(l, s)
+	b.Add(a, SourceDialback, TTLConnected)
+	b.SetMeta(a, "role", "worker")
+	b.SetBanned(a, true)
+	assert.NoError(t, s.Close())
+
+	s, err = OpenFileStore(path)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	b = newAddrBook(l, s)
+	assert.NoError(t, b.Load())
+
+	as := b.Addrs(a)
+	assert.Len(t, as, 1)
+	assertAddrEqual(t, a, as[0])
+	assert.Equal(t, SourceDialback, b.m[a.ID()].addrs[addrKey(a)].src)
+
+	v, _ := b.Meta(a, "role")
+	assert.Equal(t, "worker", v)
+	assert.True(t, b.Banned(a))
+
+	t.Run("Expired", func(t *testing.T) {
+		stale := net.NewAddr(net.New(), "", "inproc", "/persist/stale")
+		assert.NoError(t, s.Put(PeerRecord{ID: stale.ID(), Addrs: []AddrRecord{{
+			Proto:   stale.Proto(),
+			Addr:    stale.String(),
+			Source:  SourceDialback,
+			Expires: time.Now().Add(-time.Hour),
+		}}}))
+
+		b := newAddrBook(l, s)
+		assert.NoError(t, b.Load())
+
+		// addresses that expired while the host was down remain candidates
+		if as := b.Addrs(stale); assert.Len(t, as, 1) {
+			assertAddrEqual(t, stale, as[0])
+		}
+		assert.True(t, b.m[stale.ID()].addrs[addrKey(stale)].exp.After(time.Now()))
+	})
+}
+
+func assertAddrEqual(t *testing.T, expected, actual net.Addr) {
+	assert.Equal(t, expected.ID(), actual.ID())
+	assert.Equal(t, expected.Network(), actual.Network())
+	assert.Equal(t, expected.Proto(), actual.Proto())
+	assert.Equal(t, expected.String(), actual.String())
+}
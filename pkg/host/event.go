@@ -0,0 +1,89 @@
+package host
+
+import (
+	"sync"
+
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+const eventBufSize = 32
+
+// EventType identifies the kind of Event.
+type EventType uint8
+
+const (
+	// EvtConnected is emitted when a connection to a peer is established.
+	EvtConnected EventType = iota
+	// EvtDisconnected is emitted when a connection to a peer is lost.
+	EvtDisconnected
+	// EvtReconnecting is emitted after each failed attempt to reconnect to a
+	// pinned peer.
+	EvtReconnecting
+	// EvtReconnected is emitted when a connection to a pinned peer is
+	// re-established.
+	EvtReconnected
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EvtConnected:
+		return "connected"
+	case EvtDisconnected:
+		return "disconnected"
+	case EvtReconnecting:
+		return "reconnecting"
+	case EvtReconnected:
+		return "reconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change in the state of the Host's connections.
+type Event struct {
+	Type    EventType
+	Peer    net.PeerID
+	Attempt int   // number of reconnection attempts, if applicable
+	Err     error // cause of the event, if any
+}
+
+// eventBus delivers events to subscribers.  Delivery is best-effort; events
+// are dropped for subscribers whose buffer is full.
+type eventBus struct {
+	lock sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[chan Event]struct{})}
+}
+
+func (b *eventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufSize)
+
+	b.lock.Lock()
+	b.subs[ch] = struct{}{}
+	b.lock.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.lock.Lock()
+			delete(b.subs, ch)
+			b.lock.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBus) Publish(e Event) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
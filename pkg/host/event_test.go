@@ -0,0 +1,40 @@
+package host
+
+import (
+	"testing"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventType(t *testing.T) {
+	assert.Equal(t, "connected", EvtConnected.String())
+	assert.Equal(t, "disconnected", EvtDisconnected.String())
+	assert.Equal(t, "reconnecting", EvtReconnecting.String())
+	assert.Equal(t, "reconnected", EvtReconnected.String())
+	assert.Equal(t, "unknown", EventType(255).String())
+}
+
+func TestEventBus(t *testing.T) {
+	b := newEventBus()
+	ch, cancel := b.Subscribe()
+	id := net.New()
+
+	t.Run("Publish", func(t *testing.T) {
+		b.Publish(Event{Type: EvtConnected, Peer: id})
+		assert.Equal(t, Event{Type: EvtConnected, Peer: id}, <-ch)
+	})
+
+	t.Run("DropWhenFull", func(t *testing.T) {
+		for i := 0; i < eventBufSize*2; i++ {
+			b.Publish(Event{Type: EvtConnected, Peer: id})
+		}
+		assert.Len(t, ch, eventBufSize)
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		cancel()
+		cancel() // idempotent
+		assert.Empty(t, b.subs)
+	})
+}
@@ -0,0 +1,221 @@
+package host
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lthibault/casm/pkg/trace"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxHeaderSize is the maximum size of an encoded Header, in bytes.
+	MaxHeaderSize = 8 << 10
+
+	// maxHeaderKey is the maximum length of a header key, in bytes.
+	maxHeaderKey = 255
+
+	// HeaderTrace carries the caller's trace.SpanContext.  It is set by
+	// Host.Open, and consumed by the remote host.
+	HeaderTrace = "casm-trace"
+)
+
+// ErrHeaderTooLarge is returned when an encoded Header exceeds MaxHeaderSize.
+var ErrHeaderTooLarge = errors.New("header too large")
+
+// Header is a set of key-value pairs sent along with a stream's path, e.g. to
+// carry authentication tokens or content types.  Keys are case-insensitive.
+// Header is sent by the stream's opener, and is exposed by Stream.Header on
+// both ends of the stream.
+type Header map[string][]string
+
+// Get the first value associated with the key, or the empty string.
+func (h Header) Get(key string) string {
+	if vs := h[strings.ToLower(key)]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// Values associated with the key.
+func (h Header) Values(key string) []string { return h[strings.ToLower(key)] }
+
+// Set the key to a single value, replacing any existing values.
+func (h Header) Set(key, value string) { h[strings.ToLower(key)] = []string{value} }
+
+// Add a value to the key.
+func (h Header) Add(key, value string) {
+	key = strings.ToLower(key)
+	h[key] = append(h[key], value)
+}
+
+// Del deletes the values associated with the key.
+func (h Header) Del(key string) { delete(h, strings.ToLower(key)) }
+
+// Clone returns a copy of h.  Cloning a nil Header returns nil.
+func (h Header) Clone() Header {
+	if h == nil {
+		return nil
+	}
+
+	h2 := make(Header, len(h))
+	for k, vs := range h {
+		h2[k] = append([]string(nil), vs...)
+	}
+	return h2
+}
+
+// size of the encoded header.
+func (h Header) size() (n int) {
+	n = 2
+	for k, vs := range h {
+		n += len(vs) * (1 + len(k) + 2)
+		for _, v := range vs {
+			n += len(v)
+		}
+	}
+	return
+}
+
+// canonical returns a copy of h whose keys are lowercased.  The values of keys
+// that differ only by case are merged.
+func (h Header) canonical() Header {
+	if h == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // merge values in a deterministic order
+
+	h2 := make(Header, len(h))
+	for _, k := range keys {
+		lk := strings.ToLower(k)
+		h2[lk] = append(h2[lk], h[k]...)
+	}
+	return h2
+}
+
+// SendTo a specified writer.  The header is encoded as a uint16 field count,
+// followed by each field's key (uint8 length-prefixed) and value (uint16
+// length-prefixed), in big-endian format.  Keys are lowercased.
+func (h Header) SendTo(w io.Writer) error {
+	if h = h.canonical(); h.size() > MaxHeaderSize {
+		return ErrHeaderTooLarge
+	}
+
+	var n int
+	keys := make([]string, 0, len(h))
+	for k, vs := range h {
+		if k == "" || len(k) > maxHeaderKey {
+			return errors.Errorf("invalid header key '%s'", k)
+		}
+		keys = append(keys, k)
+		n += len(vs)
+	}
+	sort.Strings(keys)
+
+	b := make([]byte, 2, h.size())
+	binary.BigEndian.PutUint16(b, uint16(n))
+	for _, k := range keys {
+		for _, v := range h[k] {
+			b = append(b, uint8(len(k)))
+			b = append(b, k...)
+			b = append(b, uint8(len(v)>>8), uint8(len(v)))
+			b = append(b, v...)
+		}
+	}
+
+	_, err := w.Write(b)
+	return err
+}
+
+// RecvFrom a specified reader.  It returns ErrHeaderTooLarge if the encoded
+// header exceeds MaxHeaderSize.
+func (h *Header) RecvFrom(r io.Reader) error {
+	lr := &io.LimitedReader{R: r, N: MaxHeaderSize}
+	read := func(b []byte) error {
+		if _, err := io.ReadFull(lr, b); err != nil {
+			if lr.N == 0 {
+				return ErrHeaderTooLarge
+			}
+			return errors.Wrap(err, "read header")
+		}
+		return nil
+	}
+
+	var buf [2]byte
+	if err := read(buf[:]); err != nil {
+		return err
+	}
+
+	// The field count is set by the remote host, so it is only a size hint.
+	// Each field takes at least four bytes.
+	n := binary.BigEndian.Uint16(buf[:])
+	hint := int(n)
+	if hint > MaxHeaderSize/4 {
+		hint = MaxHeaderSize / 4
+	}
+
+	*h = make(Header, hint)
+	for i := uint16(0); i < n; i++ {
+		if err := read(buf[:1]); err != nil {
+			return err
+		}
+
+		k := make([]byte, buf[0])
+		if err := read(k); err != nil {
+			return err
+		}
+
+		if err := read(buf[:]); err != nil {
+			return err
+		}
+
+		v := make([]byte, binary.BigEndian.Uint16(buf[:]))
+		if err := read(v); err != nil {
+			return err
+		}
+
+		h.Add(string(k), string(v))
+	}
+
+	return nil
+}
+
+// span returns the SpanContext in HeaderTrace, if any.
+func (h Header) span() (sc trace.SpanContext) {
+	sc.UnmarshalBinary([]byte(h.Get(HeaderTrace))) // invalid if absent or malformed
+	return
+}
+
+type headerKey struct{}
+
+// WithHeader returns a copy of c that carries the header.  Streams opened
+// with the returned context send the header along with their path.
+func WithHeader(c context.Context, h Header) context.Context {
+	return context.WithValue(c, headerKey{}, h)
+}
+
+// requestHeader returns a canonical copy of the header carried by c, to which
+// the caller's span is added.
+func requestHeader(c context.Context) Header {
+	h, _ := c.Value(headerKey{}).(Header)
+	h = h.canonical()
+
+	if sc, ok := trace.Extract(c); ok {
+		if h == nil {
+			h = make(Header, 1)
+		}
+
+		b, _ := sc.MarshalBinary()
+		h.Set(HeaderTrace, string(b))
+	}
+
+	return h
+}
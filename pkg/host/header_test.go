@@ -0,0 +1,100 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/lthibault/casm/pkg/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		h := Header{}
+		h.Set("Content-Type", "text/plain")
+		assert.Equal(t, "text/plain", h.Get("content-type"))
+
+		h.Add("CONTENT-TYPE", "text/html")
+		assert.Equal(t, []string{"text/plain", "text/html"}, h.Values("Content-Type"))
+
+		h.Del("content-TYPE")
+		assert.Empty(t, h)
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		h := Header{}
+		h.Set("foo", "bar")
+		h.Add("baz", "")
+		h.Add("baz", "qux")
+
+		var buf bytes.Buffer
+		assert.NoError(t, h.SendTo(&buf))
+
+		var got Header
+		assert.NoError(t, got.RecvFrom(&buf))
+		assert.Equal(t, h, got)
+		assert.Zero(t, buf.Len(), "header not fully consumed")
+	})
+
+	t.Run("Canonical", func(t *testing.T) {
+		h := Header{"Foo": {"a"}, "foo": {"b"}, "BAR": {"c"}}
+
+		var buf bytes.Buffer
+		assert.NoError(t, h.SendTo(&buf))
+
+		var got Header
+		assert.NoError(t, got.RecvFrom(&buf))
+		assert.Equal(t, Header{"foo": {"a", "b"}, "bar": {"c"}}, got)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, Header(nil).SendTo(&buf))
+		assert.Equal(t, []byte{0, 0}, buf.Bytes())
+
+		var got Header
+		assert.NoError(t, got.RecvFrom(&buf))
+		assert.Empty(t, got)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		assert.Error(t, Header{"": {"v"}}.SendTo(&bytes.Buffer{}))
+		assert.Error(t, Header{strings.Repeat("k", 256): {"v"}}.SendTo(&bytes.Buffer{}))
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		h := Header{"k": {strings.Repeat("v", MaxHeaderSize)}}
+		assert.Equal(t, ErrHeaderTooLarge, h.SendTo(&bytes.Buffer{}))
+
+		// a malicious peer could ignore the limit when sending
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, uint16(1))
+		buf.Write([]byte{1, 'k'})
+		binary.Write(&buf, binary.BigEndian, uint16(MaxHeaderSize))
+		buf.WriteString(strings.Repeat("v", MaxHeaderSize))
+
+		var got Header
+		assert.Equal(t, ErrHeaderTooLarge, got.RecvFrom(&buf))
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		var got Header
+		assert.Error(t, got.RecvFrom(bytes.NewReader([]byte{0, 1, 3, 'k'})))
+
+		// the field count is not trusted when allocating
+		assert.Error(t, got.RecvFrom(bytes.NewReader([]byte{0xff, 0xff})))
+	})
+
+	t.Run("Trace", func(t *testing.T) {
+		assert.Nil(t, requestHeader(context.Background()))
+
+		c, sc := trace.Start(context.Background())
+		h := requestHeader(WithHeader(c, Header{"Foo": {"bar"}}))
+		assert.Equal(t, "bar", h.Get("foo"))
+		assert.Contains(t, h, "foo")
+		assert.Equal(t, sc, h.span())
+	})
+}
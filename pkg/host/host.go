@@ -4,10 +4,13 @@ package host
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/SentimensRG/ctx"
 	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/metrics"
 	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
 	log "github.com/lthibault/log/pkg"
 	"github.com/pkg/errors"
 )
@@ -16,21 +19,41 @@ var (
 	// ErrAlreadyConnected indicates that a connection attempt failed because
 	// a connection to the remote Host already exists.
 	ErrAlreadyConnected = errors.New("already connected")
+
+	// ErrNoAddrs indicates that a connection attempt failed because no
+	// address is known for the remote Host.
+	ErrNoAddrs = errors.New("no known addresses")
+
+	// ErrBanned indicates that a connection attempt failed because the remote
+	// Host is on the ban list.
+	ErrBanned = errors.New("peer is banned")
 )
 
 // Host is a logical machine in a compute cluster.  It acts both as a server and
 // a client.
 type Host struct {
 	l log.Logger
+	c context.Context // bounds the lifetime of connections
+
+	a  net.Addr
+	t  *net.Transport
+	ds Datastore
+	bo backoff
+	ms int // maximum number of concurrent inbound streams
+	m  metrics.Sink
 
-	a net.Addr
-	t *net.Transport
+	streams *streamTable
+	errs    *errorRing
 
-	*streamMux
+	*Mux
 	peers *peerStore
+	book  *addrBook
+	bus   *eventBus
+	pins  *pinSet
 }
 
-// New Host.  Pass options to override defaults.
+// New Host.  Pass options to override defaults.  The Recover middleware is
+// installed by default, so that a panicking handler does not crash the Host.
 func New(opt ...Option) *Host {
 	h := new(Host)
 
@@ -38,8 +61,16 @@ func New(opt ...Option) *Host {
 		fn(h)
 	}
 
-	h.streamMux = newStreamMux(h.l.WithLocus("mux"))
-	h.peers = newPeerStore()
+	h.Mux = newStreamMux(h.l.WithLocus("mux"), h.ms)
+	h.Mux.m = h.m
+	h.Use(Recover())
+	h.Register(PathPing, HandlerFunc(handlePing))
+	h.peers = newPeerStore(func(id net.PeerID) { h.pins.Dropped(id) })
+	h.book = newAddrBook(h.l.WithLocus("addrbook"), h.ds)
+	h.bus = newEventBus()
+	h.pins = newPinSet(h, h.peers.Contains, h.bus, h.bo)
+	h.streams = newStreamTable()
+	h.errs = newErrorRing(maxHandshakeErrors)
 	return h
 }
 
@@ -53,9 +84,18 @@ func (h Host) log() log.Logger {
 // Addr where the host can be reached
 func (h Host) Addr() net.Addr { return h.a }
 
-// Start the Host
+// ID of the host
+func (h Host) ID() net.PeerID { return h.a.ID() }
+
+// Start the Host.  If a Datastore was provided, its contents are loaded into
+// the address book and the Host attempts to reconnect to the peers therein.
 func (h *Host) Start(c context.Context, a net.Addr) error {
 	h.a = a // assign listen address
+	h.c = c
+
+	if err := h.book.Load(); err != nil {
+		return errors.Wrap(err, "load datastore")
+	}
 
 	c = log.Set(c, h.log().WithLocus("listener"))
 
@@ -66,12 +106,46 @@ func (h *Host) Start(c context.Context, a net.Addr) error {
 	ctx.Defer(c, h.halter(l))
 
 	go h.startAccepting(c, l)
+	go h.collectGarbage(c)
+	h.pins.Start(c)
+
+	if h.ds != nil {
+		go h.rejoin(c)
+	}
 
 	h.log().Info("started host")
 	return nil
 
 }
 
+// rejoin attempts to connect to each peer in the address book.
+func (h Host) rejoin(c context.Context) {
+	for _, id := range h.book.Peers() {
+		if id == h.a.ID() || h.book.Banned(id) {
+			continue
+		}
+
+		if err := h.Connect(c, id); err != nil {
+			h.log().WithError(err).WithField("remote_peer", id).Debug("rejoin failed")
+		}
+	}
+}
+
+// collectGarbage periodically removes expired entries from the address book.
+func (h Host) collectGarbage(c context.Context) {
+	ticker := time.NewTicker(addrBookGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.book.GC()
+		case <-c.Done():
+			return
+		}
+	}
+}
+
 func (h Host) halter(c io.Closer) func() {
 	return func() {
 		if err := c.Close(); err != nil {
@@ -100,10 +174,20 @@ func (h Host) startAccepting(c context.Context, l *net.Listener) {
 			return
 		}
 
+		if h.book.Banned(conn.RemoteAddr()) {
+			h.handshakeFailed(conn.RemoteAddr().ID(), dirInbound, "banned", ErrBanned)
+			h.log().WithError(ErrBanned).Debug("closed connection")
+			conn.Close()
+			continue
+		}
+
 		if !h.peers.StoreOrClose(conn) {
+			h.handshakeFailed(conn.RemoteAddr().ID(), dirInbound, "duplicate", ErrAlreadyConnected)
 			h.log().WithError(ErrAlreadyConnected).Debug("closed connection")
-			return
+			continue
 		}
+		h.book.Add(conn.RemoteAddr(), SourceDialback, TTLTemporary)
+		h.book.Confirm(conn.RemoteAddr())
 
 		go h.handle(c, h.bindConnLogger(conn))
 	}
@@ -118,7 +202,12 @@ func (h Host) bindConnLogger(conn *net.Conn) *net.Conn {
 
 func (h Host) handle(c context.Context, conn *net.Conn) {
 	log.Get(conn.Context()).Debug("connected")
+	h.bus.Publish(Event{Type: EvtConnected, Peer: conn.RemoteAddr().ID()})
+	h.m.AddGauge(MetricConnections, 1)
+	defer h.m.AddGauge(MetricConnections, -1)
+	defer h.bus.Publish(Event{Type: EvtDisconnected, Peer: conn.RemoteAddr().ID()})
 	defer h.Disconnect(conn.RemoteAddr())
+	defer h.streams.DropPeer(conn.RemoteAddr().ID())
 
 	var err error
 	var s *net.Stream
@@ -133,7 +222,8 @@ func (h Host) handle(c context.Context, conn *net.Conn) {
 			return
 		}
 
-		go handleStream(h, h.bindStreamLogger(s))
+		h.m.AddGauge(MetricAcceptQueue, 1)
+		go h.handleStream(h.bindStreamLogger(s))
 	}
 }
 
@@ -147,20 +237,122 @@ func (h Host) bindStreamLogger(s *net.Stream) *net.Stream {
 	)
 }
 
-func handleStream(h Handler, s *net.Stream) {
+func (h Host) handleStream(s *net.Stream) {
 	log.Get(s.Context()).Debug("stream accepted")
 
-	var p streamPath
-	if err := p.RecvFrom(s); err != nil {
+	// the stream leaves the accept queue once it is negotiated
+	queued := true
+	dequeue := func() {
+		if queued {
+			queued = false
+			h.m.AddGauge(MetricAcceptQueue, -1)
+		}
+	}
+	defer dequeue()
+
+	m := h.Mux
+	id := s.RemoteAddr().ID()
+
+	var offer pathOffer
+	if err := offer.RecvFrom(s); err != nil {
 		log.Get(s.Context()).WithError(err).Debug("failed to read path")
+		h.handshakeFailed(id, dirInbound, "malformed", err)
+		s.Close()
+		return
+	}
+
+	var hdr Header
+	if err := hdr.RecvFrom(s); err != nil {
+		log.Get(s.Context()).WithError(err).Debug("failed to read header")
+		h.handshakeFailed(id, dirInbound, "malformed", err)
+		s.Close()
+		return
+	}
+
+	if sc := hdr.span(); sc.IsValid() {
+		s = s.WithContext(log.Set(
+			trace.Inject(s.Context(), sc),
+			log.Get(s.Context()).WithField("trace_id", sc.TraceID),
+		))
+	}
+
+	rt, i, code := m.admit(offer, s.RemoteAddr())
+	if err := (offerAck{Code: code, Index: uint8(i)}).SendTo(s); err != nil {
+		log.Get(s.Context()).WithError(err).Debug("failed to send ack")
+		h.handshakeFailed(id, dirInbound, "io", err)
+		if code == AckOK {
+			m.release()
+		}
+		s.Close()
+		return
 	}
 
-	h.Serve(stream{path: p.String(), Stream: s})
+	if code != AckOK {
+		log.Get(s.Context()).WithField("offer", offer).Debugf("stream %s", code)
+		h.handshakeFailed(id, dirInbound, code.String(), nil)
+		s.Close()
+		return
+	}
+	defer m.release()
+
+	done := h.streams.Add(StreamInfo{
+		ID:      s.StreamID(),
+		Peer:    id,
+		Path:    rt.path,
+		Inbound: true,
+		Opened:  time.Now(),
+	})
+	defer done()
+
+	dequeue()
+	h.m.IncrCounter(MetricStreams, 1,
+		metrics.L("path", rt.pattern), metrics.L("direction", dirInbound))
+	rt.h.Serve(stream{
+		path:   rt.path,
+		params: rt.params,
+		hdr:    hdr,
+		m:      h.m,
+		done:   done,
+		f:      newFramer(),
+		Stream: s,
+	})
 }
 
-// Open a stream. The peer must already be connected.
-func (h Host) Open(a casm.Addresser, path string) (Stream, error) {
-	conn, ok := h.peers.Retrieve(a.Addr())
+// Open a stream, connecting to the remote host if necessary.  The context
+// governs connection establishment and stream negotiation; once Open returns,
+// it has no effect on the stream.  If the remote host does not accept the
+// stream, an OpenError is returned.
+//
+// The header carried by the context, if any, is sent along with the path (see
+// WithHeader).
+func (h Host) Open(c context.Context, a casm.Addresser, path string) (Stream, error) {
+	return h.OpenAny(c, a, path)
+}
+
+// OpenAny behaves like Open, but offers several paths in order of preference.
+// This is typically used to negotiate a protocol version, e.g.:
+// OpenAny(c, a, "/echo/2.0.0", "/echo/1.1.0").  The remote host selects the
+// first path it can serve, which is reported by Stream.Path().
+func (h Host) OpenAny(c context.Context, a casm.Addresser, paths ...string) (Stream, error) {
+	if len(paths) == 0 || len(paths) > maxOffers {
+		return nil, errors.Errorf("must offer between 1 and %d paths", maxOffers)
+	}
+
+	offer := make(pathOffer, len(paths))
+	for i, p := range paths {
+		offer[i] = streamPath(p)
+	}
+
+	if err := h.Connect(c, a.Addr()); err != nil && errors.Cause(err) != ErrAlreadyConnected {
+		return nil, errors.Wrap(err, "connect")
+	}
+
+	return h.openStream(c, a.Addr(), offer)
+}
+
+// openStream negotiates a stream over an existing connection to id.
+func (h Host) openStream(c context.Context, id casm.IDer, offer pathOffer) (Stream, error) {
+	conn, ok := h.peers.Retrieve(id)
 	if !ok {
 		return nil, errors.New("peer not found")
 	}
@@ -170,16 +362,64 @@ func (h Host) Open(a casm.Addresser, path string) (Stream, error) {
 		return nil, errors.Wrap(err, "open stream")
 	}
 
-	if err = streamPath(path).SendTo(s); err != nil {
-		return nil, errors.Wrap(err, "write path")
+	hdr := requestHeader(c)
+
+	var path streamPath
+	if err = withContext(c, s, func() (err error) {
+		path, err = negotiatePath(s, offer, hdr)
+		return
+	}); err != nil {
+		h.handshakeFailed(id.ID(), dirOutbound, handshakeReason(err), err)
+		s.Close()
+		return nil, err
+	}
+
+	// Inbound streams are labelled by route; outbound streams by the path
+	// that was negotiated.
+	h.m.IncrCounter(MetricStreams, 1,
+		metrics.L("path", path.String()), metrics.L("direction", dirOutbound))
+	return h.bindStream(s, path.String(), hdr), nil
+}
+
+// negotiatePath sends the offer, along with the header, and reads the remote
+// host's response.
+func negotiatePath(rw io.ReadWriter, offer pathOffer, hdr Header) (streamPath, error) {
+	if err := offer.SendTo(rw); err != nil {
+		return "", errors.Wrap(err, "write path")
+	}
+
+	if err := hdr.SendTo(rw); err != nil {
+		return "", errors.Wrap(err, "write header")
+	}
+
+	var ack offerAck
+	if err := ack.RecvFrom(rw); err != nil {
+		return "", errors.Wrap(err, "read ack")
 	}
 
-	return h.bindStream(s, path), nil
+	if ack.Code != AckOK {
+		return "", OpenError{Path: offer[0].String(), Code: ack.Code}
+	}
+
+	if int(ack.Index) >= len(offer) {
+		return "", errors.Errorf("remote selected invalid path index %d", ack.Index)
+	}
+
+	return offer[ack.Index], nil
 }
 
-func (h Host) bindStream(s *net.Stream, path string) stream {
+func (h Host) bindStream(s *net.Stream, path string, hdr Header) stream {
 	return stream{
 		path: path,
+		hdr:  hdr,
+		m:    h.m,
+		f:    newFramer(),
+		done: h.streams.Add(StreamInfo{
+			ID:     s.StreamID(),
+			Peer:   s.RemoteAddr().ID(),
+			Path:   path,
+			Opened: time.Now(),
+		}),
 		Stream: s.WithContext(log.Set(
 			s.Context(),
 			h.log().WithFields(log.F{"stream": s.StreamID(), "path": path}),
@@ -191,39 +431,131 @@ func (h Host) bindStream(s *net.Stream, path string) stream {
 	Implement Network
 */
 
-// Connect to a remote host.
-func (h Host) Connect(c context.Context, a casm.Addresser) error {
+// Connect to a remote host.  If id is also a casm.Addresser, its address is
+// added to the address book before dialing.  Otherwise, the host's known
+// addresses are tried in order until one succeeds.  The context governs the
+// dial; the resulting connection lasts until it is closed by either party, or
+// until the Host shuts down.
+func (h Host) Connect(c context.Context, id casm.IDer) error {
 	switch {
 	case h.a == nil:
 		return errors.New("host not started")
-	case h.a.ID() == a.Addr().ID():
+	case h.a.ID() == id.ID():
 		return errors.New("cannot connect to self")
-	case h.peers.Contains(a.Addr()):
+	case h.book.Banned(id):
+		return ErrBanned
+	case h.peers.Contains(id):
 		return ErrAlreadyConnected
 	default:
-		conn, err := h.dialAndStore(c, a.Addr())
+		if a, ok := id.(casm.Addresser); ok {
+			h.book.Add(a.Addr(), SourceManual, TTLTemporary)
+		}
+
+		conn, err := h.dialAny(c, id)
 		if err != nil {
 			return err
 		}
 
-		go h.handle(c, conn)
+		go h.handle(h.c, conn)
 
 		return nil
 	}
 }
 
+func (h Host) dialAny(c context.Context, id casm.IDer) (conn *net.Conn, err error) {
+	as := h.book.Addrs(id)
+	if len(as) == 0 {
+		return nil, ErrNoAddrs
+	}
+
+	for _, a := range as {
+		if conn, err = h.dialAndStore(c, a); err == nil {
+			break
+		}
+
+		select {
+		case <-c.Done():
+			return nil, c.Err()
+		default:
+			if errors.Cause(err) == ErrAlreadyConnected {
+				return
+			}
+
+			h.log().WithError(err).WithField("remote_peer", a).Debug("dial failed")
+		}
+	}
+
+	return
+}
+
 func (h Host) dialAndStore(c context.Context, a net.Addr) (*net.Conn, error) {
 	conn, err := h.t.NewDialer(h.a).Dial(c, a.Addr())
 	if err != nil {
+		h.handshakeFailed(a.ID(), dirOutbound, "dial", err)
 		return nil, errors.Wrap(err, "dial")
 	}
 
 	if !h.peers.StoreOrClose(conn) {
+		h.handshakeFailed(a.ID(), dirOutbound, "duplicate", ErrAlreadyConnected)
 		return nil, errors.Wrap(ErrAlreadyConnected, "dial")
 	}
+	h.book.Confirm(a)
 
 	return h.bindConnLogger(conn), nil
 }
 
+// AddAddr records an address at which a remote host can be reached.  The
+// address expires after ttl; use TTLPermanent to keep it indefinitely.
+func (h Host) AddAddr(a casm.Addresser, src AddrSource, ttl time.Duration) {
+	h.book.Add(a.Addr(), src, ttl)
+}
+
+// Addrs returns the known, unexpired addresses of a remote host.
+func (h Host) Addrs(id casm.IDer) []net.Addr { return h.book.Addrs(id) }
+
+// SetMeta associates a key-value pair with a remote host.  An empty value
+// deletes the key.
+func (h Host) SetMeta(id casm.IDer, key, val string) { h.book.SetMeta(id, key, val) }
+
+// Meta returns the value associated with key for a remote host.
+func (h Host) Meta(id casm.IDer, key string) (string, bool) { return h.book.Meta(id, key) }
+
+// Peers returns the IDs of all peers in the address book.
+func (h Host) Peers() []net.PeerID { return h.book.Peers() }
+
+// Connected returns the IDs of all currently-connected peers.
+func (h Host) Connected() []net.PeerID { return h.peers.IDs() }
+
 // Disconnect from a remote host.
 func (h Host) Disconnect(id casm.IDer) { h.peers.DropAndClose(id) }
+
+// Ban a remote host, closing any existing connection.  Connections to and from
+// banned hosts are refused.
+func (h Host) Ban(id casm.IDer) {
+	h.book.SetBanned(id, true)
+	h.Disconnect(id)
+}
+
+// Unban a remote host.
+func (h Host) Unban(id casm.IDer) { h.book.SetBanned(id, false) }
+
+// Banned returns true if the remote host is on the ban list.
+func (h Host) Banned(id casm.IDer) bool { return h.book.Banned(id) }
+
+// Pin a remote host, keeping it connected.  If the connection drops, the Host
+// redials with jittered exponential backoff until it succeeds, the host is
+// unpinned, or the Host shuts down.
+func (h Host) Pin(a casm.Addresser) {
+	h.book.Add(a.Addr(), SourceManual, TTLPermanent)
+	h.pins.Pin(a.Addr(), h.peers.Contains(a.Addr()))
+}
+
+// Unpin a remote host.  Any existing connection is left open.
+func (h Host) Unpin(id casm.IDer) { h.pins.Unpin(id) }
+
+// Pinned returns true if the remote host is pinned.
+func (h Host) Pinned(id casm.IDer) bool { return h.pins.Pinned(id) }
+
+// Subscribe to connection events.  Events are dropped if the channel's buffer
+// is full.  Call the returned function to unsubscribe.
+func (h Host) Subscribe() (<-chan Event, func()) { return h.bus.Subscribe() }
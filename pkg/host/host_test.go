@@ -2,11 +2,17 @@ package host
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
+	"time"
 
 	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
 	log "github.com/lthibault/log/pkg"
 	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,3 +44,251 @@ func TestHost(t *testing.T) {
 	// })
 
 }
+
+func TestConnect(t *testing.T) {
+	transpt := net.NewTransport(inproc.New())
+	opt := []Option{
+		OptTransport(transpt),
+		OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := New(opt...), New(opt...)
+	a0 := net.NewAddr(net.New(), "", "inproc", "/connect/h0")
+	a1 := net.NewAddr(net.New(), "", "inproc", "/connect/h1")
+	assert.NoError(t, h0.Start(c, a0))
+	assert.NoError(t, h1.Start(c, a1))
+
+	t.Run("NoAddrs", func(t *testing.T) {
+		assert.Equal(t, ErrNoAddrs, h0.Connect(c, a1.ID()))
+	})
+
+	t.Run("ByPeerID", func(t *testing.T) {
+		h0.AddAddr(net.NewAddr(a1.ID(), "", "inproc", "/connect/stale"),
+			SourceDiscovery, TTLTemporary)
+		h0.AddAddr(a1, SourceDiscovery, TTLTemporary)
+
+		assert.NoError(t, h0.Connect(c, a1.ID()))
+		assert.True(t, h0.peers.Contains(a1))
+		assert.Equal(t, ErrAlreadyConnected, h0.Connect(c, a1.ID()))
+
+		// the inbound connection confirms h0's dialback address
+		assert.Eventually(t, func() bool {
+			h1.book.RLock()
+			defer h1.book.RUnlock()
+
+			r, ok := h1.book.m[a0.ID()]
+			if !ok {
+				return false
+			}
+			for _, e := range r.addrs {
+				if e.src == SourceDialback && e.exp.After(time.Now().Add(TTLTemporary)) {
+					return true
+				}
+			}
+			return false
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("Peers", func(t *testing.T) {
+		assert.Equal(t, []net.PeerID{a1.ID()}, h0.Peers())
+		assert.Equal(t, []net.PeerID{a1.ID()}, h0.Connected())
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		h2 := New(opt...)
+		a2 := net.NewAddr(net.New(), "", "inproc", "/connect/"+net.New().String())
+		assert.NoError(t, h2.Start(c, a2))
+		assert.NoError(t, h0.Connect(c, a2))
+		assert.Eventually(t, func() bool {
+			return h2.peers.Contains(a0)
+		}, time.Second, time.Millisecond)
+
+		// a second connection from h0's peer ID is rejected by h2 ...
+		dup := New(opt...)
+		assert.NoError(t, dup.Start(c, net.NewAddr(a0.ID(), "", "inproc", "/connect/"+net.New().String())))
+		dup.Connect(c, a2)
+
+		// ... which must not prevent h2 from accepting other peers
+		dc, cancel := context.WithTimeout(c, time.Second)
+		defer cancel()
+		assert.NoError(t, h1.Connect(dc, a2))
+		assert.Eventually(t, func() bool {
+			return h2.peers.Contains(a1)
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestOpen(t *testing.T) {
+	transpt := net.NewTransport(inproc.New())
+	opt := []Option{
+		OptTransport(transpt),
+		OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := New(opt...), New(opt...)
+	a0 := net.NewAddr(net.New(), "", "inproc", "/open/h0")
+	a1 := net.NewAddr(net.New(), "", "inproc", "/open/h1")
+	assert.NoError(t, h0.Start(c, a0))
+	assert.NoError(t, h1.Start(c, a1))
+
+	h1.Register("/echo", HandlerFunc(func(s Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	}))
+
+	t.Run("DialOnDemand", func(t *testing.T) {
+		s, err := h0.Open(c, a1, "/echo")
+		assert.NoError(t, err)
+		if err != nil {
+			return
+		}
+		defer s.Close()
+
+		assert.Equal(t, "/echo", s.Path())
+
+		_, err = s.Write([]byte("hello"))
+		assert.NoError(t, err)
+
+		b := make([]byte, 5)
+		_, err = io.ReadFull(s, b)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(b))
+	})
+
+	t.Run("NoHandler", func(t *testing.T) {
+		_, err := h0.Open(c, a1, "/missing")
+		assert.Equal(t, OpenError{Path: "/missing", Code: AckNoHandler}, err)
+	})
+
+	t.Run("Version", func(t *testing.T) {
+		assert.NoError(t, h1.RegisterVersion("/proto", "^1.0.0", HandlerFunc(func(s Stream) {
+			s.Write([]byte(s.Version().String()))
+			s.Close()
+		})))
+
+		s, err := h0.OpenAny(c, a1, "/proto/2.0.0", "/proto/1.4.0", "/proto/1.0.0")
+		assert.NoError(t, err)
+		if err != nil {
+			return
+		}
+		defer s.Close()
+
+		assert.Equal(t, "/proto/1.4.0", s.Path())
+		assert.Equal(t, Version{1, 4, 0}, s.Version())
+
+		b := make([]byte, 5)
+		_, err = io.ReadFull(s, b)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.4.0", string(b))
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		hdrs := make(chan Header, 1)
+		h1.Register("/header", HandlerFunc(func(s Stream) {
+			defer s.Close()
+			hdrs <- s.Header()
+		}))
+
+		hdr := Header{}
+		hdr.Set("Content-Type", "application/json")
+		hdr.Add("token", "a")
+		hdr.Add("token", "b")
+		hdr["X-Literal"] = []string{"c"} // not lowercased by Set
+
+		s, err := h0.Open(WithHeader(c, hdr), a1, "/header")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+
+		assert.Equal(t, "c", s.Header().Get("x-literal"))
+
+		got := <-hdrs
+		assert.Equal(t, s.Header(), got, "both ends should see the same header")
+		assert.Equal(t, "application/json", got.Get("content-type"))
+		assert.Equal(t, []string{"a", "b"}, got.Values("Token"))
+
+		hdr.Set("big", strings.Repeat("x", MaxHeaderSize))
+		_, err = h0.Open(WithHeader(c, hdr), a1, "/header")
+		assert.Equal(t, ErrHeaderTooLarge, errors.Cause(err))
+	})
+
+	t.Run("CloseWrite", func(t *testing.T) {
+		s, err := h0.Open(c, a1, "/echo")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+
+		_, err = s.Write([]byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, s.CloseWrite())
+
+		b, err := ioutil.ReadAll(s)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(b))
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		errs := make(chan error, 1)
+		h1.Register("/reset", HandlerFunc(func(s Stream) {
+			defer s.Close()
+			_, err := ioutil.ReadAll(s)
+			errs <- err
+		}))
+
+		s, err := h0.Open(c, a1, "/reset")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = s.Write([]byte("partial request"))
+		assert.NoError(t, err)
+		assert.NoError(t, s.Reset(3))
+
+		assert.Equal(t, StreamResetError{Code: 3, Remote: true}, <-errs)
+
+		_, err = s.Write([]byte("more"))
+		assert.Equal(t, StreamResetError{Code: 3}, err)
+	})
+
+	t.Run("Trace", func(t *testing.T) {
+		spans := make(chan trace.SpanContext, 1)
+		h1.Register("/trace", HandlerFunc(func(s Stream) {
+			defer s.Close()
+			sc, _ := trace.Extract(s.Context())
+			spans <- sc
+		}))
+
+		cx, sc := trace.Start(c)
+		s, err := h0.Open(cx, a1, "/trace")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+
+		assert.Equal(t, sc, <-spans)
+
+		s, err = h0.Open(c, a1, "/trace")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+
+		assert.False(t, (<-spans).IsValid(), "stream without span should not be traced")
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		cx, cancel := context.WithCancel(c)
+		cancel()
+
+		_, err := h0.Open(cx, a1, "/echo")
+		assert.Equal(t, context.Canceled, err)
+	})
+}
@@ -0,0 +1,166 @@
+package host
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lthibault/casm/pkg/metrics"
+	net "github.com/lthibault/casm/pkg/net"
+)
+
+// maxHandshakeErrors is the number of handshake errors retained by the Host.
+const maxHandshakeErrors = 64
+
+// ConnInfo describes an open connection to a remote host.
+type ConnInfo struct {
+	Peer   net.PeerID
+	Addr   net.Addr
+	Opened time.Time
+	Pinned bool
+}
+
+// StreamInfo describes an open stream.
+type StreamInfo struct {
+	ID      uint32
+	Peer    net.PeerID
+	Path    string
+	Inbound bool
+	Opened  time.Time
+}
+
+// HandshakeError records a connection or stream that failed negotiation.
+type HandshakeError struct {
+	Time      time.Time
+	Peer      net.PeerID // zero if the remote host is unknown
+	Direction string     // "inbound" or "outbound"
+	Reason    string
+	Err       string
+}
+
+// streamTable tracks open streams.
+type streamTable struct {
+	lock sync.Mutex
+	seq  uint64
+	m    map[uint64]StreamInfo
+}
+
+func newStreamTable() *streamTable {
+	return &streamTable{m: make(map[uint64]StreamInfo)}
+}
+
+// Add a stream to the table.  The returned function removes it, and is safe to
+// call more than once.
+func (t *streamTable) Add(info StreamInfo) (remove func()) {
+	t.lock.Lock()
+	t.seq++
+	key := t.seq
+	t.m[key] = info
+	t.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.lock.Lock()
+			delete(t.m, key)
+			t.lock.Unlock()
+		})
+	}
+}
+
+// DropPeer removes all streams to the specified peer.
+func (t *streamTable) DropPeer(id net.PeerID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for key, info := range t.m {
+		if info.Peer == id {
+			delete(t.m, key)
+		}
+	}
+}
+
+// List open streams, oldest first.
+func (t *streamTable) List() []StreamInfo {
+	t.lock.Lock()
+	ss := make([]StreamInfo, 0, len(t.m))
+	for _, info := range t.m {
+		ss = append(ss, info)
+	}
+	t.lock.Unlock()
+
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Opened.Before(ss[j].Opened) })
+	return ss
+}
+
+// errorRing retains the most recent handshake errors.
+type errorRing struct {
+	lock sync.Mutex
+	buf  []HandshakeError
+	next int
+}
+
+func newErrorRing(n int) *errorRing {
+	return &errorRing{buf: make([]HandshakeError, 0, n)}
+}
+
+func (r *errorRing) Add(e HandshakeError) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.buf) < cap(r.buf) {
+		r.buf = append(r.buf, e)
+		return
+	}
+
+	r.buf[r.next] = e
+	r.next = (r.next + 1) % len(r.buf)
+}
+
+// List errors, oldest first.
+func (r *errorRing) List() []HandshakeError {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append(append([]HandshakeError(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+// Conns returns a snapshot of open connections, oldest first.
+func (h Host) Conns() []ConnInfo {
+	var cs []ConnInfo
+	h.peers.Each(func(conn cxn, opened time.Time) {
+		id := conn.RemoteAddr().ID()
+		cs = append(cs, ConnInfo{
+			Peer:   id,
+			Addr:   conn.RemoteAddr(),
+			Opened: opened,
+			Pinned: h.pins.Pinned(id),
+		})
+	})
+
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Opened.Before(cs[j].Opened) })
+	return cs
+}
+
+// Streams returns a snapshot of open streams, oldest first.  A stream is
+// listed until it is closed or reset, or until it has been read to EOF.
+func (h Host) Streams() []StreamInfo { return h.streams.List() }
+
+// PeerRecords returns a snapshot of the address book.
+func (h Host) PeerRecords() []PeerRecord { return h.book.Records() }
+
+// HandshakeErrors returns the most recent handshake errors, oldest first.
+func (h Host) HandshakeErrors() []HandshakeError { return h.errs.List() }
+
+// handshakeFailed records a failed negotiation.  The peer may be zero if the
+// remote host is unknown.
+func (h Host) handshakeFailed(peer net.PeerID, dir, reason string, err error) {
+	h.m.IncrCounter(MetricHandshakeFailures, 1,
+		metrics.L("reason", reason), metrics.L("direction", dir))
+
+	e := HandshakeError{Time: time.Now(), Peer: peer, Direction: dir, Reason: reason}
+	if err != nil {
+		e.Err = err.Error()
+	}
+	h.errs.Add(e)
+}
@@ -0,0 +1,146 @@
+package host
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorRing(t *testing.T) {
+	r := newErrorRing(3)
+	for i := 0; i < 5; i++ {
+		r.Add(HandshakeError{Peer: net.PeerID(i)})
+	}
+
+	var ids []net.PeerID
+	for _, e := range r.List() {
+		ids = append(ids, e.Peer)
+	}
+	assert.Equal(t, []net.PeerID{2, 3, 4}, ids)
+}
+
+func TestIntrospect(t *testing.T) {
+	transpt := net.NewTransport(inproc.New())
+	opt := []Option{
+		OptTransport(transpt),
+		OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := New(opt...), New(opt...)
+	assert.NoError(t, h0.Start(c, net.NewAddr(net.New(), "", "inproc", "/introspect/h0")))
+	assert.NoError(t, h1.Start(c, net.NewAddr(net.New(), "", "inproc", "/introspect/h1")))
+
+	release := make(chan struct{})
+	h1.Register("/block", HandlerFunc(func(s Stream) {
+		defer s.Close()
+		<-release
+	}))
+
+	t.Run("Paths", func(t *testing.T) {
+		assert.NoError(t, h1.RegisterVersion("/proto", "^1.0.0", HandlerFunc(func(Stream) {})))
+		sub := NewMux(h1.l)
+		sub.Register("/data", HandlerFunc(func(Stream) {}))
+		h1.Mount("/edge", sub)
+
+		assert.Equal(t, []string{
+			"/block",
+			PathPing,
+			"/edge/data",
+			"/proto/^1.0.0",
+		}, h1.Paths())
+	})
+
+	t.Run("Streams", func(t *testing.T) {
+		s, err := h0.Open(c, h1.Addr(), "/block")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		out := h0.Streams()
+		if assert.Len(t, out, 1) {
+			assert.Equal(t, "/block", out[0].Path)
+			assert.Equal(t, h1.ID(), out[0].Peer)
+			assert.False(t, out[0].Inbound)
+		}
+
+		assert.Eventually(t, func() bool { return len(h1.Streams()) == 1 },
+			time.Second, time.Millisecond)
+		assert.True(t, h1.Streams()[0].Inbound)
+
+		s.Close()
+		assert.Empty(t, h0.Streams())
+
+		close(release)
+		assert.Eventually(t, func() bool { return len(h1.Streams()) == 0 },
+			time.Second, time.Millisecond)
+	})
+
+	t.Run("StreamsNotClosed", func(t *testing.T) {
+		h1.Register("/eof", HandlerFunc(func(s Stream) {
+			s.Write([]byte("x"))
+			s.Close()
+		}))
+		h1.Register("/reset", HandlerFunc(func(s Stream) { s.Reset(7) }))
+
+		// entries are removed once the stream has been read to EOF ...
+		s, err := h0.Open(c, h1.Addr(), "/eof")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		b, err := ioutil.ReadAll(s)
+		assert.NoError(t, err)
+		assert.Equal(t, "x", string(b))
+		assert.Empty(t, h0.Streams())
+
+		// ... or reset, even if the caller does not close the stream
+		s, err = h0.Open(c, h1.Addr(), "/reset")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = ioutil.ReadAll(s)
+		assert.Equal(t, StreamResetError{Code: 7, Remote: true}, err)
+		assert.Empty(t, h0.Streams())
+	})
+
+	t.Run("Conns", func(t *testing.T) {
+		cs := h0.Conns()
+		if assert.Len(t, cs, 1) {
+			assert.Equal(t, h1.ID(), cs[0].Peer)
+			assert.False(t, cs[0].Opened.IsZero())
+		}
+	})
+
+	t.Run("PeerRecords", func(t *testing.T) {
+		rs := h0.PeerRecords()
+		if assert.Len(t, rs, 1) {
+			assert.Equal(t, h1.ID(), rs[0].ID)
+		}
+	})
+
+	t.Run("HandshakeErrors", func(t *testing.T) {
+		_, err := h0.Open(c, h1.Addr(), "/missing")
+		assert.Error(t, err)
+
+		es := h0.HandshakeErrors()
+		if assert.Len(t, es, 1) {
+			assert.Equal(t, h1.ID(), es[0].Peer)
+			assert.Equal(t, dirOutbound, es[0].Direction)
+			assert.Equal(t, AckNoHandler.String(), es[0].Reason)
+		}
+
+		assert.Eventually(t, func() bool { return len(h1.HandshakeErrors()) == 1 },
+			time.Second, time.Millisecond)
+		assert.Equal(t, dirInbound, h1.HandshakeErrors()[0].Direction)
+	})
+}
@@ -0,0 +1,138 @@
+package host
+
+import (
+	"context"
+	gonet "net"
+	"sync"
+
+	casm "github.com/lthibault/casm/pkg"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+// ErrListenerClosed is returned by Accept after the listener is closed.  It
+// wraps net.ErrClosed, so that servers recognize it as a normal shutdown.
+var ErrListenerClosed = errors.Wrap(gonet.ErrClosed, "listener closed")
+
+// Listen for incoming streams on the path.  Streams are returned by the
+// listener's Accept method as standard net.Conns, so the listener can be
+// passed to http.Server.Serve, grpc.Server.Serve, etc.  Closing the listener
+// unregisters the path, but does not close streams that were already
+// accepted.  Any handler previously registered on the path is replaced.
+func (h Host) Listen(path string) gonet.Listener {
+	l := &listener{
+		unregister: func() { h.Unregister(path) },
+		addr:       listenAddr{path: path},
+		ch:         make(chan *streamConn),
+		done:       make(chan struct{}),
+	}
+	h.Register(path, l)
+
+	return l
+}
+
+// Dial opens a stream to the path on the remote peer, connecting to it if
+// necessary.  The peer's addresses are taken from the address book.
+func (h Host) Dial(c context.Context, id casm.IDer, path string) (gonet.Conn, error) {
+	if err := h.Connect(c, id); err != nil && errors.Cause(err) != ErrAlreadyConnected {
+		return nil, errors.Wrap(err, "connect")
+	}
+
+	s, err := h.openStream(c, id, pathOffer{streamPath(path)})
+	if err != nil {
+		return nil, err
+	}
+
+	return newStreamConn(s), nil
+}
+
+// Dialer returns a function that dials the path on the peer identified by
+// addr, which is formatted as by net.PeerID.String.  Any port suffix is
+// ignored, so the function can be used with grpc.WithContextDialer and, via a
+// closure, http.Transport.DialContext.
+func (h Host) Dialer(path string) func(context.Context, string) (gonet.Conn, error) {
+	return func(c context.Context, addr string) (gonet.Conn, error) {
+		if host, _, err := gonet.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+
+		id, err := net.ParsePeerID(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return h.Dial(c, id, path)
+	}
+}
+
+type listener struct {
+	unregister func()
+	addr       listenAddr
+	ch         chan *streamConn
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Serve hands the stream to Accept, and blocks until it is closed so that the
+// stream continues to count against the mux's limits.
+func (l *listener) Serve(s Stream) {
+	conn := newStreamConn(s)
+
+	select {
+	case l.ch <- conn:
+	case <-l.done:
+		s.Close()
+		return
+	}
+
+	select {
+	case <-conn.closed:
+	case <-s.Context().Done():
+	}
+}
+
+func (l *listener) Accept() (gonet.Conn, error) {
+	select {
+	case conn := <-l.ch:
+		return conn, nil
+	case <-l.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+		l.unregister()
+	})
+	return nil
+}
+
+func (l *listener) Addr() gonet.Addr { return l.addr }
+
+// listenAddr is the address of a listener.
+type listenAddr struct{ path string }
+
+func (listenAddr) Network() string  { return "casm" }
+func (a listenAddr) String() string { return a.path }
+
+// streamConn adapts a Stream to the net.Conn interface.
+type streamConn struct {
+	Stream
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newStreamConn(s Stream) *streamConn {
+	return &streamConn{Stream: s, closed: make(chan struct{})}
+}
+
+func (c *streamConn) LocalAddr() gonet.Addr  { return c.Stream.LocalAddr() }
+func (c *streamConn) RemoteAddr() gonet.Addr { return c.Stream.RemoteAddr() }
+
+func (c *streamConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Stream.Close()
+}
@@ -0,0 +1,72 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	gonet "net"
+	"net/http"
+	"testing"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListener(t *testing.T) {
+	opt := []Option{
+		OptTransport(net.NewTransport(inproc.New())),
+		OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := New(opt...), New(opt...)
+	a0 := net.NewAddr(net.New(), "", "inproc", "/listen/h0")
+	a1 := net.NewAddr(net.New(), "", "inproc", "/listen/h1")
+	assert.NoError(t, h0.Start(c, a0))
+	assert.NoError(t, h1.Start(c, a1))
+	h0.AddAddr(a1, SourceManual, TTLPermanent)
+
+	l := h1.Listen("/http")
+	assert.Equal(t, "/http", l.Addr().String())
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s", r.URL.Path)
+	})}
+	go srv.Serve(l)
+
+	t.Run("HTTP", func(t *testing.T) {
+		dial := h0.Dialer("/http")
+		cl := &http.Client{Transport: &http.Transport{
+			DialContext: func(c context.Context, _, addr string) (gonet.Conn, error) {
+				return dial(c, addr)
+			},
+		}}
+
+		res, err := cl.Get(fmt.Sprintf("http://%s/world", a1.ID()))
+		assert.NoError(t, err)
+		if err != nil {
+			return
+		}
+		defer res.Body.Close()
+
+		b, err := ioutil.ReadAll(res.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello /world", string(b))
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		assert.NoError(t, l.Close())
+
+		_, err := l.Accept()
+		assert.Equal(t, ErrListenerClosed, err)
+		assert.True(t, errors.Is(err, gonet.ErrClosed))
+
+		_, err = h0.Dial(c, a1.ID(), "/http")
+		assert.Equal(t, OpenError{Path: "/http", Code: AckNoHandler}, err)
+	})
+}
@@ -0,0 +1,57 @@
+package host
+
+import (
+	"context"
+
+	"github.com/lthibault/casm/pkg/metrics"
+	"github.com/pkg/errors"
+)
+
+// Metrics reported by the Host.  See OptMetrics.
+const (
+	MetricConnections       = "casm_host_connections"
+	MetricStreams           = "casm_host_streams_total"
+	MetricActiveStreams     = "casm_host_inbound_streams_active"
+	MetricAcceptQueue       = "casm_host_accept_queue_depth"
+	MetricHandshakeFailures = "casm_host_handshake_failures_total"
+	MetricBytes             = "casm_host_bytes_total"
+)
+
+// Values of the "direction" label.
+const (
+	dirInbound  = "inbound"
+	dirOutbound = "outbound"
+)
+
+// DescribeMetrics registers help text for the Host's metrics.
+func DescribeMetrics(d metrics.Describer) {
+	d.Describe(MetricConnections, metrics.Gauge,
+		"Number of open connections to remote hosts.")
+	d.Describe(MetricStreams, metrics.Counter,
+		"Streams successfully negotiated, by path and direction.")
+	d.Describe(MetricActiveStreams, metrics.Gauge,
+		"Inbound streams currently being served.")
+	d.Describe(MetricAcceptQueue, metrics.Gauge,
+		"Inbound streams accepted from the transport and awaiting negotiation.")
+	d.Describe(MetricHandshakeFailures, metrics.Counter,
+		"Connections and streams rejected during negotiation, by reason and direction.")
+	d.Describe(MetricBytes, metrics.Counter,
+		"Bytes read from and written to streams, by direction.")
+}
+
+// handshakeReason classifies an error returned by stream negotiation.
+func handshakeReason(err error) string {
+	switch e := errors.Cause(err).(type) {
+	case OpenError:
+		return e.Code.String()
+	default:
+		switch e {
+		case context.Canceled:
+			return "canceled"
+		case context.DeadlineExceeded:
+			return "timeout"
+		}
+	}
+
+	return "io"
+}
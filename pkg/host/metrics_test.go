@@ -0,0 +1,106 @@
+package host
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/metrics"
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	transpt := net.NewTransport(inproc.New())
+	l := log.New(log.OptLevel(log.NullLevel))
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0 := New(OptTransport(transpt), OptLogger(l))
+	h1 := New(OptTransport(transpt), OptLogger(l), OptMetrics(r))
+	assert.NoError(t, h0.Start(c, net.NewAddr(net.New(), "", "inproc", "/metrics/h0")))
+	assert.NoError(t, h1.Start(c, net.NewAddr(net.New(), "", "inproc", "/metrics/h1")))
+
+	done := make(chan struct{})
+	h1.Register("/echo", HandlerFunc(func(s Stream) {
+		defer close(done)
+		defer s.Close()
+		io.Copy(s, s)
+	}))
+
+	t.Run("Stream", func(t *testing.T) {
+		s, err := h0.Open(c, h1.Addr(), "/echo")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = s.Write([]byte("hello"))
+		assert.NoError(t, err)
+		_, err = io.ReadFull(s, make([]byte, 5))
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1.0, r.Value(MetricConnections))
+		assert.Equal(t, 1.0, r.Value(MetricActiveStreams))
+		assert.Equal(t, 1.0, r.Value(MetricStreams,
+			metrics.L("path", "/echo"), metrics.L("direction", dirInbound)))
+
+		s.Close()
+		<-done
+
+		assert.Eventually(t, func() bool {
+			return r.Value(MetricActiveStreams) == 0
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, 5.0, r.Value(MetricBytes, metrics.L("direction", dirInbound)))
+		assert.Equal(t, 5.0, r.Value(MetricBytes, metrics.L("direction", dirOutbound)))
+	})
+
+	t.Run("Route", func(t *testing.T) {
+		h1.Register("/kv/:bucket", HandlerFunc(func(s Stream) { s.Close() }))
+
+		sub := NewMux(l)
+		sub.RegisterVersion("/echo", "^1.0.0", HandlerFunc(func(s Stream) { s.Close() }))
+		h1.Mount("/sub", sub)
+
+		for _, path := range []string{"/kv/a", "/kv/b", "/sub/echo/1.0.0", "/sub/echo/1.2.0"} {
+			s, err := h0.Open(c, h1.Addr(), path)
+			if assert.NoError(t, err) {
+				s.Close()
+			}
+		}
+
+		// series are labelled by route, not by the path offered by the peer
+		assert.Eventually(t, func() bool {
+			return r.Value(MetricStreams,
+				metrics.L("path", "/kv/:bucket"), metrics.L("direction", dirInbound)) == 2 &&
+				r.Value(MetricStreams,
+					metrics.L("path", "/sub/echo/^1.0.0"), metrics.L("direction", dirInbound)) == 2
+		}, time.Second, time.Millisecond)
+		assert.Zero(t, r.Value(MetricStreams,
+			metrics.L("path", "/kv/a"), metrics.L("direction", dirInbound)))
+		assert.Zero(t, r.Value(MetricAcceptQueue))
+	})
+
+	t.Run("Outbound", func(t *testing.T) {
+		h0.Register("/sink", HandlerFunc(func(s Stream) { s.Close() }))
+
+		s, err := h1.Open(c, h0.Addr(), "/sink")
+		if assert.NoError(t, err) {
+			s.Close()
+		}
+
+		assert.Equal(t, 1.0, r.Value(MetricStreams,
+			metrics.L("path", "/sink"), metrics.L("direction", dirOutbound)))
+	})
+
+	t.Run("HandshakeFailure", func(t *testing.T) {
+		_, err := h1.Open(c, h0.Addr(), "/missing")
+		assert.Error(t, err)
+		assert.Equal(t, 1.0, r.Value(MetricHandshakeFailures,
+			metrics.L("reason", AckNoHandler.String()), metrics.L("direction", dirOutbound)))
+	})
+}
@@ -0,0 +1,137 @@
+package host
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+)
+
+// Middleware wraps a Handler to provide cross-cutting functionality such as
+// logging or panic recovery.
+type Middleware func(Handler) Handler
+
+// Chain wraps h in the specified middleware, such that mw[0] is outermost.
+// The resulting Handler satisfies Acceptor if h or any of the handlers
+// returned by mw does, so that streams can be refused before they are
+// acknowledged.
+func Chain(h Handler, mw ...Middleware) Handler {
+	c := chain{Handler: h}
+	if a, ok := h.(Acceptor); ok {
+		c.as = append(c.as, a)
+	}
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		c.Handler = mw[i](c.Handler)
+		if a, ok := c.Handler.(Acceptor); ok {
+			c.as = append(c.as, a)
+		}
+	}
+
+	return c
+}
+
+type chain struct {
+	Handler
+	as []Acceptor // innermost first
+}
+
+// Accept satisfies Acceptor.  Outer acceptors are consulted first.
+func (c chain) Accept(path string, remote net.Addr) AckCode {
+	for i := len(c.as) - 1; i >= 0; i-- {
+		if code := c.as[i].Accept(path, remote); code != AckOK {
+			return code
+		}
+	}
+	return AckOK
+}
+
+// Recover from panics in the handler.  The panic is logged and the stream is
+// closed.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(s Stream) {
+			defer func() {
+				if v := recover(); v != nil {
+					log.Get(s.Context()).WithFields(log.F{
+						"path":  s.Path(),
+						"panic": fmt.Sprint(v),
+						"stack": string(debug.Stack()),
+					}).Error("handler panicked")
+					s.Close()
+				}
+			}()
+
+			next.Serve(s)
+		})
+	}
+}
+
+// AccessLog logs each stream once the handler returns.
+func AccessLog() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(s Stream) {
+			t0 := time.Now()
+			next.Serve(s)
+
+			log.Get(s.Context()).WithFields(log.F{
+				"path":        s.Path(),
+				"remote_peer": s.RemoteAddr(),
+				"duration":    time.Since(t0),
+			}).Info("served stream")
+		})
+	}
+}
+
+// Timeout sets a deadline of d on each stream before it is served.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(s Stream) {
+			if err := s.SetDeadline(time.Now().Add(d)); err != nil {
+				log.Get(s.Context()).WithError(err).Debug("failed to set deadline")
+			}
+
+			next.Serve(s)
+		})
+	}
+}
+
+// Limit the number of streams served concurrently to n.  Additional streams
+// are refused with AckOverloaded.  The count is shared by every handler the
+// middleware wraps, so Register(p, Chain(h, Limit(8))) limits a single path,
+// whereas Use(Limit(8)) limits all streams served by the Mux.
+func Limit(n int) Middleware {
+	count := new(int32)
+	return func(next Handler) Handler {
+		return &limiter{Handler: next, max: int32(n), n: count}
+	}
+}
+
+type limiter struct {
+	Handler
+	max int32
+	n   *int32
+}
+
+// Accept satisfies Acceptor.  It provides early rejection, but does not
+// reserve a slot; Serve enforces the limit.
+func (l *limiter) Accept(string, net.Addr) AckCode {
+	if atomic.LoadInt32(l.n) >= l.max {
+		return AckOverloaded
+	}
+	return AckOK
+}
+
+func (l *limiter) Serve(s Stream) {
+	defer atomic.AddInt32(l.n, -1)
+	if atomic.AddInt32(l.n, 1) > l.max {
+		log.Get(s.Context()).WithField("path", s.Path()).Debug("stream limit exceeded")
+		s.Close()
+		return
+	}
+
+	l.Handler.Serve(s)
+}
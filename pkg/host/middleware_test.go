@@ -0,0 +1,129 @@
+package host
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStream struct {
+	Stream // nil; panics if an unexpected method is called
+	path   string
+	closed bool
+	dl     time.Time
+}
+
+func (s *mockStream) Path() string           { return s.path }
+func (*mockStream) Context() context.Context { return context.Background() }
+func (*mockStream) RemoteAddr() net.Addr     { return nil }
+func (s *mockStream) SetDeadline(t time.Time) error {
+	s.dl = t
+	return nil
+}
+func (s *mockStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func tag(trace *[]string, name string) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(s Stream) {
+			*trace = append(*trace, name)
+			next.Serve(s)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Run("Order", func(t *testing.T) {
+		var trace []string
+		h := Chain(HandlerFunc(func(Stream) { trace = append(trace, "h") }),
+			tag(&trace, "a"),
+			tag(&trace, "b"))
+
+		h.Serve(nil)
+		assert.Equal(t, []string{"a", "b", "h"}, trace)
+	})
+
+	t.Run("Acceptor", func(t *testing.T) {
+		h := Chain(new(refuser), AccessLog())
+		a, ok := h.(Acceptor)
+		assert.True(t, ok)
+		assert.Equal(t, AckRefused, a.Accept("/", nil))
+
+		h = Chain(new(testHandler), Limit(0))
+		assert.Equal(t, AckOverloaded, h.(Acceptor).Accept("/", nil))
+
+		h = Chain(new(testHandler), Limit(1))
+		assert.Equal(t, AckOK, h.(Acceptor).Accept("/", nil))
+	})
+}
+
+func TestRecover(t *testing.T) {
+	s := &mockStream{path: "/panic"}
+	assert.NotPanics(t, func() {
+		Recover()(HandlerFunc(func(Stream) { panic("boom") })).Serve(s)
+	})
+	assert.True(t, s.closed)
+}
+
+func TestTimeout(t *testing.T) {
+	s := new(mockStream)
+	Timeout(time.Minute)(new(testHandler)).Serve(s)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), s.dl, time.Second)
+}
+
+func TestLimit(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := Limit(1)(HandlerFunc(func(Stream) {
+		close(started)
+		<-release
+	}))
+
+	go h.Serve(new(mockStream))
+	<-started
+
+	assert.Equal(t, AckOverloaded, h.(Acceptor).Accept("/", nil))
+
+	s := new(mockStream)
+	h.Serve(s)
+	assert.True(t, s.closed, "stream exceeding the limit was not closed")
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return h.(Acceptor).Accept("/", nil) == AckOK
+	}, time.Second, time.Millisecond)
+
+	t.Run("Shared", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		mw := Limit(1)
+		go mw(HandlerFunc(func(Stream) { <-release })).Serve(new(mockStream))
+
+		h := mw(new(testHandler))
+		assert.Eventually(t, func() bool {
+			return h.(Acceptor).Accept("/", nil) == AckOverloaded
+		}, time.Second, time.Millisecond, "limit not shared between handlers")
+	})
+}
+
+func TestMuxUse(t *testing.T) {
+	var trace []string
+	l := log.New(log.OptLevel(log.NullLevel))
+
+	m, sub := newStreamMux(l, 0), NewMux(l)
+	m.Use(tag(&trace, "root"))
+	sub.Use(tag(&trace, "sub"))
+
+	sub.Register("/data", HandlerFunc(func(Stream) { trace = append(trace, "h") }))
+	m.Mount("/edge", sub)
+
+	m.Serve(&mockStream{path: "/edge/data"})
+	assert.Equal(t, []string{"root", "sub", "h"}, trace)
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	radix "github.com/armon/go-radix"
+	"github.com/lthibault/casm/pkg/metrics"
 	net "github.com/lthibault/casm/pkg/net"
 	log "github.com/lthibault/log/pkg"
 	"github.com/pkg/errors"
@@ -18,11 +22,27 @@ import (
 // a remote host
 type Stream interface {
 	Path() string
+	// Version of the protocol negotiated for the stream, i.e.: the semantic
+	// version in the last segment of Path().  The zero value indicates that
+	// the path is unversioned.
+	Version() Version
+	// Param returns the value of a path parameter, e.g.: "bucket" in
+	// "/kv/:bucket".  It returns the empty string if no such parameter exists.
+	Param(name string) string
+	// Header sent by the stream's opener along with the path.  It must not be
+	// modified.
+	Header() Header
 	Context() context.Context
 	StreamID() uint32
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
 	Close() error
+	// CloseWrite signals that no more data will be written.  The remote
+	// host's Read returns io.EOF once it has consumed the data already sent.
+	CloseWrite() error
+	// Reset aborts the stream and closes it.  The remote host's Read returns
+	// a StreamResetError carrying the application error code.
+	Reset(code uint32) error
 	Read([]byte) (int, error)
 	Write([]byte) (int, error)
 	SetDeadline(time.Time) error
@@ -78,44 +98,587 @@ func (p *streamPath) RecvFrom(r io.Reader) (err error) {
 	return
 }
 
-type streamMux struct {
-	lock sync.RWMutex
-	log  log.Logger
-	r    *radix.Tree
+// AckCode is sent by the listener in response to a streamPath, indicating
+// whether the stream was accepted.
+type AckCode uint8
+
+const (
+	// AckOK indicates that the stream was accepted.
+	AckOK AckCode = iota
+	// AckNoHandler indicates that no handler is registered for the path.
+	AckNoHandler
+	// AckRefused indicates that the handler declined the stream.
+	AckRefused
+	// AckOverloaded indicates that the remote host has too many open streams.
+	AckOverloaded
+)
+
+func (c AckCode) String() string {
+	switch c {
+	case AckOK:
+		return "ok"
+	case AckNoHandler:
+		return "no handler"
+	case AckRefused:
+		return "refused"
+	case AckOverloaded:
+		return "overloaded"
+	default:
+		return fmt.Sprintf("unknown ack code %d", uint8(c))
+	}
+}
+
+// SendTo a specified writer.
+func (c AckCode) SendTo(w io.Writer) error {
+	return binary.Write(w, binary.BigEndian, uint8(c))
+}
+
+// RecvFrom a specified reader.
+func (c *AckCode) RecvFrom(r io.Reader) error {
+	return binary.Read(r, binary.BigEndian, (*uint8)(c))
+}
+
+// OpenError is returned when the remote host does not accept a stream.
+type OpenError struct {
+	Path string
+	Code AckCode
+}
+
+func (e OpenError) Error() string { return fmt.Sprintf("open %s: %s", e.Path, e.Code) }
+
+// Acceptor is an optional interface that a Handler can implement in order to
+// refuse streams before they are acknowledged.  Accept returns AckOK if the
+// stream should be served, or the code with which it should be refused.
+type Acceptor interface {
+	Accept(path string, remote net.Addr) AckCode
+}
+
+type deadliner interface {
+	SetDeadline(time.Time) error
+}
+
+// withContext calls fn, aborting any blocking IO on d when c expires.
+func withContext(c context.Context, d deadliner, fn func() error) error {
+	if t, ok := c.Deadline(); ok {
+		if err := d.SetDeadline(t); err != nil {
+			return errors.Wrap(err, "set deadline")
+		}
+	}
+	defer d.SetDeadline(time.Time{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	done := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		select {
+		case <-c.Done():
+			d.SetDeadline(time.Unix(1, 0)) // unblock pending IO
+		case <-done:
+		}
+	}()
+
+	err := fn()
+	close(done)
+	wg.Wait()
+
+	if c.Err() != nil {
+		return c.Err()
+	}
+
+	return err
+}
+
+// maxOffers is the maximum number of paths a client can offer when opening a
+// stream.
+const maxOffers = 255
+
+// pathOffer is the list of paths proposed by a client, in order of preference.
+type pathOffer []streamPath
+
+// SendTo a specified writer.
+func (o pathOffer) SendTo(w io.Writer) error {
+	if len(o) == 0 || len(o) > maxOffers {
+		return errors.Errorf("must offer between 1 and %d paths", maxOffers)
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint8(len(o))); err != nil {
+		return errors.Wrap(err, "write count")
+	}
+
+	for _, p := range o {
+		if err := p.SendTo(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecvFrom a specified reader.
+func (o *pathOffer) RecvFrom(r io.Reader) error {
+	var n uint8
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return errors.Wrap(err, "read count")
+	}
+
+	*o = make(pathOffer, n)
+	for i := range *o {
+		if err := (*o)[i].RecvFrom(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// offerAck is the listener's response to a pathOffer.  Index identifies the
+// selected path, and is meaningful only if Code is AckOK.
+type offerAck struct {
+	Code  AckCode
+	Index uint8
+}
+
+// SendTo a specified writer.
+func (a offerAck) SendTo(w io.Writer) error {
+	return binary.Write(w, binary.BigEndian, a)
 }
 
-func newStreamMux(l log.Logger) *streamMux {
-	return &streamMux{log: l, r: radix.New()}
+// RecvFrom a specified reader.
+func (a *offerAck) RecvFrom(r io.Reader) error {
+	return binary.Read(r, binary.BigEndian, a)
 }
 
-func (m *streamMux) Register(path string, h Handler) {
+type versionedHandler struct {
+	r   Range
+	rng string
+	Handler
+}
+
+// paramRoute matches paths with the same number of segments as its pattern,
+// where pattern segments of the form ":name" match any value.
+type paramRoute struct {
+	pattern string
+	segs    []string
+	Handler
+}
+
+func newParamRoute(pattern string, h Handler) paramRoute {
+	return paramRoute{pattern: pattern, segs: strings.Split(pattern, "/"), Handler: h}
+}
+
+func isParamPattern(path string) bool { return strings.Contains(path, "/:") }
+
+func (r paramRoute) match(segs []string) (params map[string]string, ok bool) {
+	if len(segs) != len(r.segs) {
+		return nil, false
+	}
+
+	for i, seg := range r.segs {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg[1:]] = segs[i]
+		case seg != segs[i]:
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// Mux routes incoming streams to Handlers according to their path.  Paths are
+// matched in the following order:
+//
+//  1. exact matches, e.g.: "/kv/get";
+//  2. versioned paths registered with RegisterVersion, e.g.: "/echo/1.2.0";
+//  3. parametrized paths, e.g.: "/kv/:bucket", in order of registration;
+//  4. prefix paths ending in "/*", e.g.: "/edge/*", longest prefix first.
+//
+// A Mux may itself be mounted under a prefix of another Mux.
+type Mux struct {
+	lock   sync.RWMutex
+	log    log.Logger
+	r      *radix.Tree                   // exact routes
+	vs     map[string][]versionedHandler // keyed by base path
+	ps     []paramRoute
+	prefix *radix.Tree   // keyed by prefix, including the trailing slash
+	mw     []Middleware  // applied to every handler
+	slots  chan struct{} // nil if the number of inbound streams is unbounded
+	m      metrics.Sink
+}
+
+// NewMux returns an empty Mux, suitable for mounting under a Host's prefix.
+func NewMux(l log.Logger) *Mux { return newStreamMux(l, 0) }
+
+func newStreamMux(l log.Logger, maxStreams int) *Mux {
+	m := &Mux{
+		log:    l,
+		m:      metrics.Discard,
+		r:      radix.New(),
+		vs:     make(map[string][]versionedHandler),
+		prefix: radix.New(),
+	}
+	if maxStreams > 0 {
+		m.slots = make(chan struct{}, maxStreams)
+	}
+	return m
+}
+
+// Register a handler for the specified path.  Paths ending in "/*" match any
+// path with the same prefix, and path segments of the form ":name" match any
+// value, which the handler can retrieve with Stream.Param(name).  Registering
+// a handler to an already-registered path replaces the existing handler.
+func (m *Mux) Register(path string, h Handler) {
 	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	switch {
+	case strings.HasSuffix(path, "/*"):
+		m.prefix.Insert(strings.TrimSuffix(path, "*"), h)
+	case isParamPattern(path):
+		m.unregisterParamUnsafe(path)
+		m.ps = append(m.ps, newParamRoute(path, h))
+	default:
+		m.r.Insert(path, h)
+	}
+
 	m.log.WithField("path", path).Debug("registered handler")
-	m.r.Insert(path, h)
+}
+
+// Use appends middleware to the chain that is applied to every handler
+// served by the Mux, including those of mounted sub-Muxes.  Middleware
+// registered first is outermost.
+func (m *Mux) Use(mw ...Middleware) {
+	m.lock.Lock()
+	m.mw = append(m.mw, mw...)
 	m.lock.Unlock()
 }
 
-func (m *streamMux) Unregister(path string) {
+// wrap a handler in the Mux's middleware.
+func (m *Mux) wrap(h Handler) Handler {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if len(m.mw) == 0 {
+		return h
+	}
+
+	return Chain(h, m.mw...)
+}
+
+// Mount a Mux under the specified prefix.  Paths are matched against the
+// sub-Mux after the prefix has been stripped, but Stream.Path() continues to
+// report the full path.
+func (m *Mux) Mount(prefix string, sub *Mux) {
+	m.Register(strings.TrimSuffix(prefix, "/")+"/*", sub)
+}
+
+// RegisterVersion registers a handler for every path of the form
+// "<base>/<version>" such that version is within the specified range, e.g.:
+// RegisterVersion("/echo", "^1.0.0", h).  Exact matches registered with
+// Register take precedence.
+func (m *Mux) RegisterVersion(base, rng string, h Handler) error {
+	r, err := ParseRange(rng)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	m.log.WithFields(log.F{"path": base, "range": rng}).Debug("registered handler")
+	m.vs[base] = append(m.vs[base], versionedHandler{r: r, rng: rng, Handler: h})
+	m.lock.Unlock()
+
+	return nil
+}
+
+// Unregister the handler for the specified path, as well as any versioned
+// handlers registered under it.
+func (m *Mux) Unregister(path string) {
 	m.lock.Lock()
-	if _, ok := m.r.Delete(path); ok {
+	defer m.lock.Unlock()
+
+	var ok bool
+	switch {
+	case strings.HasSuffix(path, "/*"):
+		_, ok = m.prefix.Delete(strings.TrimSuffix(path, "*"))
+	case isParamPattern(path):
+		ok = m.unregisterParamUnsafe(path)
+	default:
+		_, ok = m.r.Delete(path)
+	}
+
+	if _, found := m.vs[path]; found {
+		delete(m.vs, path)
+		ok = true
+	}
+
+	if ok {
 		m.log.WithField("path", path).Debug("unregistered")
 	}
-	m.lock.Unlock()
 }
 
-func (m *streamMux) Serve(s Stream) {
+func (m *Mux) unregisterParamUnsafe(pattern string) bool {
+	for i, r := range m.ps {
+		if r.pattern == pattern {
+			m.ps = append(m.ps[:i], m.ps[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Paths returns the registered paths, sorted.  Versioned handlers are reported
+// as "<base>/<range>", e.g.: "/echo/^1.0.0", and the paths of mounted sub-Muxes
+// are reported under their prefix.
+func (m *Mux) Paths() []string {
 	m.lock.RLock()
-	v, ok := m.r.Get(s.Path())
-	m.lock.RUnlock()
+	defer m.lock.RUnlock()
 
-	if ok {
-		v.(Handler).Serve(s)
+	var ps []string
+	m.r.Walk(func(p string, _ interface{}) bool {
+		ps = append(ps, p)
+		return false
+	})
+
+	for base, vhs := range m.vs {
+		for _, vh := range vhs {
+			ps = append(ps, base+"/"+vh.rng)
+		}
+	}
+
+	for _, r := range m.ps {
+		ps = append(ps, r.pattern)
+	}
+
+	m.prefix.Walk(func(p string, v interface{}) bool {
+		if sub, ok := v.(*Mux); ok {
+			for _, sp := range sub.Paths() {
+				ps = append(ps, strings.TrimSuffix(p, "/")+sp)
+			}
+		} else {
+			ps = append(ps, p+"*")
+		}
+		return false
+	})
+
+	sort.Strings(ps)
+	return ps
+}
+
+// Lookup the handler for the specified path, along with any path parameters.
+func (m *Mux) Lookup(path string) (h Handler, params map[string]string, ok bool) {
+	h, params, _, ok = m.lookup(path)
+	return
+}
+
+// lookup behaves like Lookup, but also returns the pattern under which the
+// handler was registered, formatted as by Paths.
+func (m *Mux) lookup(path string) (h Handler, params map[string]string, pattern string, ok bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var v interface{}
+	if v, ok = m.r.Get(path); ok {
+		return v.(Handler), nil, path, true
 	}
+
+	if base, ver, isVersioned := splitVersion(path); isVersioned {
+		for _, vh := range m.vs[base] {
+			if vh.r.Contains(ver) {
+				return vh.Handler, nil, base + "/" + vh.rng, true
+			}
+		}
+	}
+
+	segs := strings.Split(path, "/")
+	for _, r := range m.ps {
+		if params, ok = r.match(segs); ok {
+			return r.Handler, params, r.pattern, true
+		}
+	}
+
+	return m.lookupPrefixUnsafe(path)
+}
+
+func (m *Mux) lookupPrefixUnsafe(path string) (Handler, map[string]string, string, bool) {
+	var prefixes []string
+	m.prefix.WalkPath(path, func(p string, _ interface{}) bool {
+		prefixes = append(prefixes, p)
+		return false
+	})
+
+	// longest prefix first
+	for i := len(prefixes) - 1; i >= 0; i-- {
+		v, _ := m.prefix.Get(prefixes[i])
+
+		sub, ok := v.(*Mux)
+		if !ok {
+			return v.(Handler), nil, prefixes[i] + "*", true
+		}
+
+		if h, params, pattern, ok := sub.lookup(path[len(prefixes[i])-1:]); ok {
+			return sub.wrap(h), params, strings.TrimSuffix(prefixes[i], "/") + pattern, true
+		}
+	}
+
+	return nil, nil, "", false
+}
+
+// admit an incoming stream, selecting the first offered path for which a
+// handler is registered.  The route's handler is wrapped in the Mux's
+// middleware, so that middleware implementing Acceptor can refuse the stream.
+// If the returned code is AckOK, the caller MUST call release once the stream
+// has been served.
+func (m *Mux) admit(offer pathOffer, remote net.Addr) (route, int, AckCode) {
+	var rt route
+	var i int
+	var ok bool
+	for i = range offer {
+		rt.path = offer[i].String()
+		if rt.h, rt.params, rt.pattern, ok = m.lookup(rt.path); ok {
+			break
+		}
+	}
+
+	if !ok {
+		return route{}, 0, AckNoHandler
+	}
+
+	rt.h = m.wrap(rt.h)
+	if a, ok := rt.h.(Acceptor); ok {
+		if code := a.Accept(rt.path, remote); code != AckOK {
+			return route{}, i, code
+		}
+	}
+
+	if m.slots != nil {
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			return route{}, i, AckOverloaded
+		}
+	}
+
+	m.m.AddGauge(MetricActiveStreams, 1)
+	return rt, i, AckOK
+}
+
+// release a slot acquired by admit.
+func (m *Mux) release() {
+	m.m.AddGauge(MetricActiveStreams, -1)
+	if m.slots != nil {
+		<-m.slots
+	}
+}
+
+// Serve satisfies Handler.  Mounted muxes are resolved by their parent, so
+// Serve is only called when the Mux is used as a standalone Handler.
+func (m *Mux) Serve(s Stream) {
+	h, params, ok := m.Lookup(s.Path())
+	if !ok {
+		m.log.WithField("path", s.Path()).Debug("no handler")
+		s.Close()
+		return
+	}
+
+	if params != nil {
+		s = paramStream{Stream: s, params: params}
+	}
+
+	m.wrap(h).Serve(s)
+}
+
+// route is the result of resolving a path.
+type route struct {
+	path    string
+	pattern string // under which h was registered; see Mux.Paths
+	params  map[string]string
+	h       Handler
 }
 
 type stream struct {
-	path string
+	path   string
+	params map[string]string
+	hdr    Header
+	m      metrics.Sink // nil if the stream is not instrumented
+	done   func()       // removes the stream from the Host's stream table
+	f      *framer
 	*net.Stream
 }
 
+func (s stream) Close() error {
+	if s.done != nil {
+		s.done()
+	}
+	return s.Stream.Close()
+}
+
 func (s stream) Path() string { return s.path }
+
+func (s stream) Header() Header { return s.hdr }
+
+func (s stream) Read(b []byte) (n int, err error) {
+	if n, err = s.f.Read(s.Stream, b); n > 0 && s.m != nil {
+		s.m.IncrCounter(MetricBytes, float64(n), metrics.L("direction", dirInbound))
+	}
+	s.maybeDone(err)
+	return
+}
+
+func (s stream) Write(b []byte) (n int, err error) {
+	if n, err = s.f.Write(s.Stream, b); n > 0 && s.m != nil {
+		s.m.IncrCounter(MetricBytes, float64(n), metrics.L("direction", dirOutbound))
+	}
+	s.maybeDone(err)
+	return
+}
+
+// maybeDone removes the stream from the Host's stream table once it has been
+// read to EOF or reset, so that callers that never call Close do not leak the
+// entry.
+func (s stream) maybeDone(err error) {
+	if s.done == nil {
+		return
+	}
+
+	if _, reset := err.(StreamResetError); reset || err == io.EOF {
+		s.done()
+	}
+}
+
+func (s stream) CloseWrite() error { return s.f.CloseWrite(s.Stream.CloseWrite) }
+
+func (s stream) SetDeadline(t time.Time) error {
+	s.f.SetWriteDeadline(t)
+	return s.Stream.SetDeadline(t)
+}
+
+func (s stream) SetWriteDeadline(t time.Time) error {
+	s.f.SetWriteDeadline(t)
+	return s.Stream.SetWriteDeadline(t)
+}
+
+func (s stream) Reset(code uint32) error {
+	err := s.f.Reset(s.Stream, code)
+	if cerr := s.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s stream) Version() (v Version) {
+	_, v, _ = splitVersion(s.path)
+	return
+}
+
+func (s stream) Param(name string) string { return s.params[name] }
+
+type paramStream struct {
+	Stream
+	params map[string]string
+}
+
+func (s paramStream) Param(name string) string { return s.params[name] }
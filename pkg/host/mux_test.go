@@ -2,12 +2,15 @@ package host
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"sync"
 	"testing"
+	"time"
 
+	net "github.com/lthibault/casm/pkg/net"
 	log "github.com/lthibault/log/pkg"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -72,6 +75,159 @@ func TestPath(t *testing.T) {
 	})
 }
 
+func TestAckCode(t *testing.T) {
+	b := new(bytes.Buffer)
+
+	for _, expected := range []AckCode{AckOK, AckNoHandler, AckRefused, AckOverloaded} {
+		assert.NoError(t, expected.SendTo(b))
+
+		var code AckCode
+		assert.NoError(t, code.RecvFrom(b))
+		assert.Equal(t, expected, code)
+	}
+
+	assert.Equal(t, "open /foo: overloaded",
+		OpenError{Path: "/foo", Code: AckOverloaded}.Error())
+	assert.Equal(t, "unknown ack code 255", AckCode(255).String())
+}
+
+type refuser struct{ testHandler }
+
+func (refuser) Accept(string, net.Addr) AckCode { return AckRefused }
+
+func TestAdmit(t *testing.T) {
+	m := newStreamMux(log.New(log.OptLevel(log.NullLevel)), 1)
+	m.Register("/ok", new(testHandler))
+	m.Register("/refuse", new(refuser))
+
+	_, _, code := m.admit(pathOffer{"/missing"}, nil)
+	assert.Equal(t, AckNoHandler, code)
+
+	_, _, code = m.admit(pathOffer{"/refuse"}, nil)
+	assert.Equal(t, AckRefused, code)
+
+	rt, i, code := m.admit(pathOffer{"/missing", "/ok"}, nil)
+	assert.Equal(t, AckOK, code)
+	assert.Equal(t, 1, i)
+	assert.Equal(t, "/ok", rt.path)
+	assert.NotNil(t, rt.h)
+
+	_, _, code = m.admit(pathOffer{"/ok"}, nil)
+	assert.Equal(t, AckOverloaded, code)
+
+	m.release()
+	_, _, code = m.admit(pathOffer{"/ok"}, nil)
+	assert.Equal(t, AckOK, code)
+
+	t.Run("Middleware", func(t *testing.T) {
+		m := newStreamMux(log.New(log.OptLevel(log.NullLevel)), 0)
+		m.Register("/ok", new(testHandler))
+		m.Use(Limit(0))
+
+		_, _, code := m.admit(pathOffer{"/ok"}, nil)
+		assert.Equal(t, AckOverloaded, code, "host-wide limit not applied at negotiation")
+	})
+}
+
+func TestPathOffer(t *testing.T) {
+	b := new(bytes.Buffer)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		defer b.Reset()
+
+		offer := pathOffer{"/echo/2.0.0", "/echo/1.0.0"}
+		assert.NoError(t, offer.SendTo(b))
+
+		var res pathOffer
+		assert.NoError(t, res.RecvFrom(b))
+		assert.Equal(t, offer, res)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Error(t, pathOffer{}.SendTo(b))
+	})
+
+	t.Run("Ack", func(t *testing.T) {
+		defer b.Reset()
+
+		ack := offerAck{Code: AckOK, Index: 3}
+		assert.NoError(t, ack.SendTo(b))
+
+		var res offerAck
+		assert.NoError(t, res.RecvFrom(b))
+		assert.Equal(t, ack, res)
+	})
+}
+
+func TestVersionedMux(t *testing.T) {
+	m := newStreamMux(log.New(log.OptLevel(log.NullLevel)), 0)
+	v1, v2, exact := new(testHandler), new(testHandler), new(testHandler)
+
+	assert.NoError(t, m.RegisterVersion("/echo", "^1.0.0", v1))
+	assert.NoError(t, m.RegisterVersion("/echo", ">=2.0.0 <3.0.0", v2))
+	assert.Error(t, m.RegisterVersion("/echo", "!1.0.0", v2))
+	m.Register("/echo/1.5.0", exact)
+
+	for path, expected := range map[string]Handler{
+		"/echo/1.0.0": v1,
+		"/echo/1.9.3": v1,
+		"/echo/2.1.0": v2,
+		"/echo/1.5.0": exact,
+	} {
+		h, _, ok := m.Lookup(path)
+		assert.True(t, ok, path)
+		assert.True(t, h == expected, path)
+	}
+
+	_, _, ok := m.Lookup("/echo/3.0.0")
+	assert.False(t, ok)
+
+	m.Unregister("/echo")
+	_, _, ok = m.Lookup("/echo/1.0.0")
+	assert.False(t, ok)
+}
+
+type mockDeadliner struct {
+	sync.Mutex
+	t time.Time
+}
+
+func (d *mockDeadliner) SetDeadline(t time.Time) error {
+	d.Lock()
+	d.t = t
+	d.Unlock()
+	return nil
+}
+
+func TestWithContext(t *testing.T) {
+	t.Run("Deadline", func(t *testing.T) {
+		d := new(mockDeadliner)
+		t0 := time.Now().Add(time.Minute)
+
+		c, cancel := context.WithDeadline(context.Background(), t0)
+		defer cancel()
+
+		assert.NoError(t, withContext(c, d, func() error {
+			d.Lock()
+			defer d.Unlock()
+			assert.Equal(t, t0, d.t)
+			return nil
+		}))
+		assert.True(t, d.t.IsZero(), "deadline not cleared")
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		d := new(mockDeadliner)
+		c, cancel := context.WithCancel(context.Background())
+
+		err := withContext(c, d, func() error {
+			cancel()
+			return errors.New("i/o timeout")
+		})
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
 func TestHandlerFunc(t *testing.T) {
 	var ok bool
 	HandlerFunc(func(Stream) { ok = true }).Serve(nil)
@@ -80,7 +236,7 @@ func TestHandlerFunc(t *testing.T) {
 
 func TestMux(t *testing.T) {
 	var wg sync.WaitGroup
-	m := newStreamMux(log.New(log.OptLevel(log.NullLevel))) // disable logging
+	m := newStreamMux(log.New(log.OptLevel(log.NullLevel)), 0) // disable logging
 
 	t.Run("Register", func(t *testing.T) {
 		wg.Add(100)
@@ -115,6 +271,14 @@ func TestMux(t *testing.T) {
 		})
 	})
 
+	t.Run("Lookup", func(t *testing.T) {
+		_, _, ok := m.Lookup("/test/0")
+		assert.True(t, ok)
+
+		_, _, ok = m.Lookup("/missing")
+		assert.False(t, ok)
+	})
+
 	t.Run("Replace", func(t *testing.T) {
 		// N.B.: this test enforces a detail of the mux spec; Registering a Handler
 		// 		 to an already-registered path _replaces_ the existing Handler.
@@ -140,3 +304,73 @@ func TestMux(t *testing.T) {
 		assert.Zero(t, m.r.Len())
 	})
 }
+
+func TestRouting(t *testing.T) {
+	l := log.New(log.OptLevel(log.NullLevel))
+	m := newStreamMux(l, 0)
+
+	exact, param, prefix, deep := new(testHandler), new(testHandler),
+		new(testHandler), new(testHandler)
+
+	m.Register("/kv/stats", exact)
+	m.Register("/kv/:bucket", param)
+	m.Register("/kv/:bucket/:key", param)
+	m.Register("/files/*", prefix)
+	m.Register("/files/deep/*", deep)
+
+	sub := NewMux(l)
+	data, ctrl := new(testHandler), new(testHandler)
+	sub.Register("/data", data)
+	sub.Register("/ctrl/:id", ctrl)
+	m.Mount("/edge", sub)
+
+	for _, tc := range []struct {
+		path   string
+		h      Handler
+		params map[string]string
+	}{
+		{path: "/kv/stats", h: exact},
+		{path: "/kv/users", h: param, params: map[string]string{"bucket": "users"}},
+		{path: "/kv/users/alice", h: param,
+			params: map[string]string{"bucket": "users", "key": "alice"}},
+		{path: "/files/a/b/c", h: prefix},
+		{path: "/files/deep/a", h: deep},
+		{path: "/edge/data", h: data},
+		{path: "/edge/ctrl/7", h: ctrl, params: map[string]string{"id": "7"}},
+	} {
+		h, params, ok := m.Lookup(tc.path)
+		assert.True(t, ok, tc.path)
+		assert.True(t, h == tc.h, tc.path)
+		assert.Equal(t, tc.params, params, tc.path)
+	}
+
+	for _, path := range []string{"/kv", "/edge/missing", "/files"} {
+		_, _, ok := m.Lookup(path)
+		assert.False(t, ok, path)
+	}
+
+	t.Run("Unregister", func(t *testing.T) {
+		m.Unregister("/kv/:bucket")
+		m.Unregister("/files/deep/*")
+		m.Unregister("/edge/*")
+
+		h, _, ok := m.Lookup("/kv/users")
+		assert.False(t, ok)
+
+		h, _, _ = m.Lookup("/files/deep/a")
+		assert.True(t, h == prefix)
+
+		_, _, ok = m.Lookup("/edge/data")
+		assert.False(t, ok)
+	})
+
+	t.Run("Serve", func(t *testing.T) {
+		var got string
+		m.Register("/user/:name", HandlerFunc(func(s Stream) {
+			got = s.Param("name")
+		}))
+
+		m.Serve(stream{path: "/user/bob"})
+		assert.Equal(t, "bob", got)
+	})
+}
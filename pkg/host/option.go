@@ -1,6 +1,9 @@
 package host
 
 import (
+	"time"
+
+	"github.com/lthibault/casm/pkg/metrics"
 	net "github.com/lthibault/casm/pkg/net"
 	log "github.com/lthibault/log/pkg"
 	tcp "github.com/lthibault/pipewerks/pkg/transport/tcp"
@@ -27,6 +30,8 @@ func setDefaultOpts(opt []Option) []Option {
 		[]Option{
 			OptTransport(net.NewTransport(tcp.New())),
 			OptLogger(nil),
+			OptMetrics(nil),
+			OptReconnectBackoff(defaultBackoffMin, defaultBackoffMax),
 		},
 		opt...,
 	)
@@ -40,3 +45,50 @@ func OptTransport(t *net.Transport) Option {
 		return
 	}
 }
+
+// OptDatastore persists the address book, peer metadata and ban list.  The
+// Host does not take ownership of the Datastore; callers are responsible for
+// closing it.
+func OptDatastore(ds Datastore) Option {
+	return func(h *Host) (prev Option) {
+		prev = OptDatastore(h.ds)
+		h.ds = ds
+		return
+	}
+}
+
+// OptReconnectBackoff sets the minimum and maximum delay between attempts to
+// reconnect to a pinned peer.  A zero bound is replaced by its default, which
+// is adjusted if necessary so that min does not exceed max.
+func OptReconnectBackoff(min, max time.Duration) Option {
+	return func(h *Host) (prev Option) {
+		prev = OptReconnectBackoff(h.bo.min, h.bo.max)
+		h.bo = newBackoff(min, max)
+		return
+	}
+}
+
+// OptMaxInboundStreams limits the number of streams that remote hosts may have
+// open concurrently.  Additional streams are refused with AckOverloaded.  A
+// value of zero or less removes the limit.
+func OptMaxInboundStreams(n int) Option {
+	return func(h *Host) (prev Option) {
+		prev = OptMaxInboundStreams(h.ms)
+		h.ms = n
+		return
+	}
+}
+
+// OptMetrics reports the Host's metrics to the Sink.  Pass a *metrics.Registry
+// to export them over HTTP.
+func OptMetrics(m metrics.Sink) Option {
+	if m == nil {
+		m = metrics.Discard
+	}
+
+	return func(h *Host) (prev Option) {
+		prev = OptMetrics(h.m)
+		h.m = m
+		return
+	}
+}
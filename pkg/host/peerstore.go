@@ -3,6 +3,7 @@ package host
 import (
 	"context"
 	"sync"
+	"time"
 
 	casm "github.com/lthibault/casm/pkg"
 	net "github.com/lthibault/casm/pkg/net"
@@ -43,10 +44,18 @@ func (t cxnTable) Del(id net.PeerID) (conn cxn, found bool) {
 
 type peerStore struct {
 	sync.RWMutex
-	t cxnTable
+	t      cxnTable
+	since  map[net.PeerID]time.Time
+	onDrop func(net.PeerID) // called without the lock held; may be nil
 }
 
-func newPeerStore() *peerStore { return new(peerStore).Reset() }
+// newPeerStore calls onDrop each time a connection is dropped.  Unlike
+// EvtDisconnected, which is dropped by slow subscribers, onDrop is never
+// skipped.
+func newPeerStore(onDrop func(net.PeerID)) *peerStore {
+	p := &peerStore{onDrop: onDrop}
+	return p.Reset()
+}
 
 func (p *peerStore) Retrieve(id casm.IDer) (conn cxn, found bool) {
 	p.RLock()
@@ -61,6 +70,8 @@ func (p *peerStore) StoreOrClose(conn cxn) (stored bool) {
 	p.Lock()
 	if stored = p.t.Add(conn); !stored {
 		conn.Close()
+	} else {
+		p.since[conn.RemoteAddr().ID()] = time.Now()
 	}
 	p.Unlock()
 
@@ -69,10 +80,16 @@ func (p *peerStore) StoreOrClose(conn cxn) (stored bool) {
 
 func (p *peerStore) DropAndClose(id casm.IDer) {
 	p.Lock()
-	if conn, ok := p.t.Del(id.ID()); ok {
+	conn, ok := p.t.Del(id.ID())
+	if ok {
+		delete(p.since, id.ID())
 		conn.Close()
 	}
 	p.Unlock()
+
+	if ok && p.onDrop != nil {
+		p.onDrop(id.ID())
+	}
 }
 
 func (p *peerStore) Contains(id casm.IDer) (found bool) {
@@ -82,9 +99,31 @@ func (p *peerStore) Contains(id casm.IDer) (found bool) {
 	return
 }
 
+func (p *peerStore) IDs() []net.PeerID {
+	p.RLock()
+	defer p.RUnlock()
+
+	ids := make([]net.PeerID, 0, len(p.t))
+	for id := range p.t {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Each calls fn for every connection, along with the time it was stored.
+func (p *peerStore) Each(fn func(cxn, time.Time)) {
+	p.RLock()
+	defer p.RUnlock()
+
+	for id, conn := range p.t {
+		fn(conn, p.since[id])
+	}
+}
+
 func (p *peerStore) Reset() *peerStore {
 	p.Lock()
 	p.t = make(map[net.PeerID]cxn)
+	p.since = make(map[net.PeerID]time.Time)
 	p.Unlock()
 	return p
 }
@@ -46,7 +46,8 @@ func TestCxnTable(t *testing.T) {
 }
 
 func TestPeerStore(t *testing.T) {
-	p := newPeerStore()
+	var dropped []net.PeerID
+	p := newPeerStore(func(id net.PeerID) { dropped = append(dropped, id) })
 	assert.NotNil(t, p.t)
 
 	conn := &mockConn{remote: net.NewAddr(net.New(), "", "", "")}
@@ -84,6 +85,10 @@ func TestPeerStore(t *testing.T) {
 	t.Run("DropAndClose", func(t *testing.T) {
 		p.DropAndClose(conn.RemoteAddr())
 		assert.NotContains(t, p.t, conn.RemoteAddr().ID())
+		assert.Equal(t, []net.PeerID{conn.RemoteAddr().ID()}, dropped)
+
+		p.DropAndClose(conn.RemoteAddr())
+		assert.Len(t, dropped, 1, "onDrop called for a peer that was not connected")
 	})
 
 	t.Run("Reset", func(t *testing.T) {
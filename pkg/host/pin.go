@@ -0,0 +1,193 @@
+package host
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBackoffMin = time.Millisecond * 100
+	defaultBackoffMax = time.Minute
+)
+
+// backoff computes jittered, exponentially-increasing delays, capped at max.
+type backoff struct {
+	min, max time.Duration
+}
+
+func newBackoff(min, max time.Duration) backoff {
+	switch {
+	case min == 0 && max == 0:
+		min, max = defaultBackoffMin, defaultBackoffMax
+	case min == 0:
+		if min = defaultBackoffMin; min > max {
+			min = max
+		}
+	case max == 0:
+		if max = defaultBackoffMax; max < min {
+			max = min
+		}
+	}
+
+	return backoff{min: min, max: max}
+}
+
+func (b backoff) Duration(attempt int) time.Duration {
+	d := b.min
+	for i := 0; i < attempt && d < b.max; i++ {
+		d *= 2
+	}
+
+	if d > b.max {
+		d = b.max
+	}
+
+	// full jitter over the upper half of the interval
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+type connector interface {
+	Connect(context.Context, casm.IDer) error
+	Banned(casm.IDer) bool
+}
+
+// pinSet keeps a set of peers connected, redialing them when their connection
+// drops.
+type pinSet struct {
+	lock      sync.Mutex
+	c         context.Context
+	h         connector
+	connected func(casm.IDer) bool
+	bus       *eventBus
+	b         backoff
+	m         map[net.PeerID]*pin
+}
+
+type pin struct {
+	cancel  func()
+	running bool
+}
+
+func newPinSet(h connector, connected func(casm.IDer) bool, bus *eventBus, b backoff) *pinSet {
+	return &pinSet{
+		h:         h,
+		connected: connected,
+		bus:       bus,
+		b:         b,
+		m:         make(map[net.PeerID]*pin),
+	}
+}
+
+// Start dialing any pinned peers.  Reconnection loops terminate when c expires.
+func (ps *pinSet) Start(c context.Context) {
+	ps.lock.Lock()
+	ps.c = c
+	for id := range ps.m {
+		ps.reconnectUnsafe(id)
+	}
+	ps.lock.Unlock()
+}
+
+func (ps *pinSet) Pin(id casm.IDer, connected bool) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.m[id.ID()]; ok {
+		return
+	}
+
+	ps.m[id.ID()] = &pin{cancel: func() {}}
+	if !connected {
+		ps.reconnectUnsafe(id.ID())
+	}
+}
+
+func (ps *pinSet) Unpin(id casm.IDer) {
+	ps.lock.Lock()
+	if p, ok := ps.m[id.ID()]; ok {
+		p.cancel()
+		delete(ps.m, id.ID())
+	}
+	ps.lock.Unlock()
+}
+
+func (ps *pinSet) Pinned(id casm.IDer) (ok bool) {
+	ps.lock.Lock()
+	_, ok = ps.m[id.ID()]
+	ps.lock.Unlock()
+	return
+}
+
+// Dropped redials the peer if it is pinned.  It is called by the peer store
+// each time a connection is dropped.
+func (ps *pinSet) Dropped(id net.PeerID) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.m[id]; ok {
+		ps.reconnectUnsafe(id)
+	}
+}
+
+func (ps *pinSet) reconnectUnsafe(id net.PeerID) {
+	p := ps.m[id]
+	if p.running || ps.c == nil {
+		return
+	}
+
+	var c context.Context
+	c, p.cancel = context.WithCancel(ps.c)
+	p.running = true
+
+	go func() {
+		ps.done(id, p, ps.reconnect(c, id))
+	}()
+}
+
+// done marks the reconnection loop as finished.  If the connection dropped
+// while the loop was returning, Dropped saw the loop as running and did nothing,
+// so the loop is restarted.
+func (ps *pinSet) done(id net.PeerID, p *pin, reconnected bool) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	p.running = false
+	if reconnected && ps.m[id] == p && !ps.connected(id) {
+		ps.reconnectUnsafe(id)
+	}
+}
+
+// reconnect dials the peer until it succeeds, the peer is banned, or c expires.
+// It returns true if the peer was reconnected.
+func (ps *pinSet) reconnect(c context.Context, id net.PeerID) bool {
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-c.Done():
+			return false
+		case <-time.After(ps.b.Duration(attempt)):
+		}
+
+		if ps.h.Banned(id) {
+			return false
+		}
+
+		err := ps.h.Connect(c, id)
+		if err == nil || errors.Cause(err) == ErrAlreadyConnected {
+			ps.bus.Publish(Event{Type: EvtReconnected, Peer: id, Attempt: attempt + 1})
+			return true
+		}
+
+		ps.bus.Publish(Event{
+			Type:    EvtReconnecting,
+			Peer:    id,
+			Attempt: attempt + 1,
+			Err:     err,
+		})
+	}
+}
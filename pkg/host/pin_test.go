@@ -0,0 +1,149 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockConnector struct {
+	sync.Mutex
+	fail      int // number of attempts that fail before success
+	calls     int
+	connected bool
+	onConnect func() // called after a successful attempt; may be nil
+}
+
+func (m *mockConnector) Banned(casm.IDer) bool { return false }
+
+func (m *mockConnector) Connect(context.Context, casm.IDer) error {
+	m.Lock()
+	if m.calls++; m.calls <= m.fail {
+		m.Unlock()
+		return errors.New("connection refused")
+	}
+	m.connected = true
+	onConnect := m.onConnect
+	m.Unlock()
+
+	if onConnect != nil {
+		onConnect()
+	}
+	return nil
+}
+
+func (m *mockConnector) Connected(casm.IDer) bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.connected
+}
+
+// drop the connection, as the peer store would.
+func (m *mockConnector) drop(ps *pinSet, id net.PeerID) {
+	m.Lock()
+	m.connected = false
+	m.Unlock()
+
+	ps.Dropped(id)
+}
+
+func TestBackoff(t *testing.T) {
+	b := backoff{min: time.Millisecond * 10, max: time.Second}
+
+	for attempt := 0; attempt < 20; attempt++ {
+		d := b.Duration(attempt)
+		assert.True(t, d >= b.min/2, "delay %s below minimum", d)
+		assert.True(t, d <= b.max, "delay %s exceeds cap", d)
+	}
+
+	assert.True(t, b.Duration(20) >= b.max/2)
+}
+
+func TestNewBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		name                 string
+		min, max, wmin, wmax time.Duration
+	}{
+		{"Defaults", 0, 0, defaultBackoffMin, defaultBackoffMax},
+		{"Explicit", time.Second, time.Hour, time.Second, time.Hour},
+		{"MaxOnly", 0, time.Second, defaultBackoffMin, time.Second},
+		{"SmallMaxOnly", 0, time.Millisecond, time.Millisecond, time.Millisecond},
+		{"MinOnly", time.Second, 0, time.Second, defaultBackoffMax},
+		{"LargeMinOnly", time.Hour, 0, time.Hour, time.Hour},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(tt.min, tt.max)
+			assert.Equal(t, backoff{min: tt.wmin, max: tt.wmax}, b)
+			assert.NotZero(t, b.Duration(0), "reconnect loop must not spin")
+		})
+	}
+}
+
+func TestPinSet(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := newEventBus()
+	events, unsub := bus.Subscribe()
+	defer unsub()
+
+	h := &mockConnector{fail: 2}
+	ps := newPinSet(h, h.Connected, bus, backoff{min: time.Millisecond, max: time.Millisecond * 4})
+	ps.Start(c)
+
+	id := net.New()
+
+	expect := func(t *testing.T, types ...EventType) {
+		for _, expected := range types {
+			select {
+			case e := <-events:
+				assert.Equal(t, expected, e.Type)
+				assert.Equal(t, id, e.Peer)
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for %s", expected)
+			}
+		}
+	}
+
+	t.Run("Reconnect", func(t *testing.T) {
+		ps.Pin(id, false)
+		assert.True(t, ps.Pinned(id))
+		expect(t, EvtReconnecting, EvtReconnecting, EvtReconnected)
+	})
+
+	t.Run("OnDisconnect", func(t *testing.T) {
+		h.drop(ps, id)
+		expect(t, EvtReconnected)
+	})
+
+	t.Run("DropWhileReconnecting", func(t *testing.T) {
+		// the connection drops before the reconnection loop has returned
+		var once sync.Once
+		h.Lock()
+		h.onConnect = func() { once.Do(func() { h.drop(ps, id) }) }
+		h.Unlock()
+
+		h.drop(ps, id)
+		expect(t, EvtReconnected, EvtReconnected)
+		assert.True(t, h.Connected(id))
+	})
+
+	t.Run("Unpin", func(t *testing.T) {
+		ps.Unpin(id)
+		assert.False(t, ps.Pinned(id))
+
+		h.drop(ps, id)
+
+		select {
+		case e := <-events:
+			t.Fatalf("unexpected event %s", e.Type)
+		case <-time.After(time.Millisecond * 20):
+		}
+	})
+}
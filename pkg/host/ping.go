@@ -0,0 +1,121 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/pkg/errors"
+)
+
+// PathPing is the reserved path on which every Host answers pings.
+const PathPing = "/casm/ping/1.0.0"
+
+const pingSize = 32 // 8-byte timestamp followed by random padding
+
+// pingInterval is the delay between successive pings to the same peer.
+var pingInterval = time.Second
+
+// PingResult is a single round-trip measurement.
+type PingResult struct {
+	RTT time.Duration
+	Err error
+}
+
+// ErrPingMismatch is returned when a peer does not echo the ping payload.
+var ErrPingMismatch = errors.New("ping payload mismatch")
+
+// handlePing echoes each payload back to the sender until the stream closes.
+func handlePing(s Stream) {
+	defer s.Close()
+
+	b := make([]byte, pingSize)
+	for {
+		if _, err := io.ReadFull(s, b); err != nil {
+			return
+		}
+
+		if _, err := s.Write(b); err != nil {
+			return
+		}
+	}
+}
+
+// Ping the peer, connecting to it if necessary.  A sample is delivered on the
+// returned channel every second until c expires or a ping fails; the channel
+// is then closed.
+func (h Host) Ping(c context.Context, id casm.IDer) (<-chan PingResult, error) {
+	if err := h.Connect(c, id); err != nil && errors.Cause(err) != ErrAlreadyConnected {
+		return nil, errors.Wrap(err, "connect")
+	}
+
+	s, err := h.openStream(c, id, pathOffer{PathPing})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan PingResult)
+	go func() {
+		defer close(ch)
+		defer s.Close()
+
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			var res PingResult
+			res.Err = withContext(c, s, func() (err error) {
+				res.RTT, err = ping(s)
+				return
+			})
+
+			select {
+			case ch <- res:
+			case <-c.Done():
+				return
+			}
+
+			if res.Err != nil {
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-c.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func ping(rw io.ReadWriter) (time.Duration, error) {
+	b := make([]byte, pingSize*2)
+	out, in := b[:pingSize], b[pingSize:]
+
+	if _, err := rand.Read(out[8:]); err != nil {
+		return 0, errors.Wrap(err, "generate payload")
+	}
+
+	t0 := time.Now()
+	binary.BigEndian.PutUint64(out, uint64(t0.UnixNano()))
+
+	if _, err := rw.Write(out); err != nil {
+		return 0, errors.Wrap(err, "write")
+	}
+
+	if _, err := io.ReadFull(rw, in); err != nil {
+		return 0, errors.Wrap(err, "read")
+	}
+
+	if !bytes.Equal(out, in) {
+		return 0, ErrPingMismatch
+	}
+
+	return time.Since(t0), nil
+}
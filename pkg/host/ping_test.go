@@ -0,0 +1,58 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	t.Run("Mismatch", func(t *testing.T) {
+		var rw bytes.Buffer
+		rw.Write(make([]byte, pingSize)) // stale payload
+		_, err := ping(&rw)
+		assert.Equal(t, ErrPingMismatch, err)
+	})
+
+	opt := []Option{
+		OptTransport(net.NewTransport(inproc.New())),
+		OptLogger(log.New(log.OptLevel(log.NullLevel))),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := New(opt...), New(opt...)
+	a1 := net.NewAddr(net.New(), "", "inproc", "/ping/h1")
+	assert.NoError(t, h0.Start(c, net.NewAddr(net.New(), "", "inproc", "/ping/h0")))
+	assert.NoError(t, h1.Start(c, a1))
+
+	defer func(d time.Duration) { pingInterval = d }(pingInterval)
+	pingInterval = time.Millisecond
+
+	cx, stop := context.WithCancel(c)
+	ch, err := h0.Ping(cx, a1)
+	assert.NoError(t, err)
+	if err != nil {
+		stop()
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		res := <-ch
+		assert.NoError(t, res.Err)
+		assert.True(t, res.RTT > 0)
+	}
+
+	stop()
+	assert.Eventually(t, func() bool {
+		_, ok := <-ch
+		return !ok
+	}, time.Second, time.Millisecond, "channel not closed")
+}
@@ -0,0 +1,240 @@
+package host
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StreamResetError is returned by a Stream's Read and Write methods after the
+// stream has been reset.  Code is the application error code passed to Reset.
+type StreamResetError struct {
+	Code uint32
+	// Remote is true if the stream was reset by the remote host.
+	Remote bool
+}
+
+func (e StreamResetError) Error() string {
+	if e.Remote {
+		return fmt.Sprintf("stream reset by remote host (code %d)", e.Code)
+	}
+	return fmt.Sprintf("stream reset (code %d)", e.Code)
+}
+
+// Once negotiated, stream data is sent as a sequence of frames, so that a reset
+// can be signalled in-band.  Each frame begins with a type byte and a 32-bit
+// big-endian argument: the length of the payload for data frames, and the
+// application error code for reset frames.
+//
+// Writes are split into data frames of at most maxFrameSize bytes.  If a write
+// is interrupted (e.g. by a deadline), the next write completes the frame.  If
+// the stream is reset in the middle of a frame, the rest of the frame is padded
+// with zeros before the reset frame is sent, so the remote host may read up to
+// maxFrameSize bytes of padding before its Read fails with StreamResetError.
+const (
+	frameData uint8 = iota
+	frameReset
+
+	frameHdrSize = 5
+	maxFrameSize = 16 << 10
+)
+
+// framer encodes and decodes the frames of a stream.  Reads and writes are
+// independently serialized.
+type framer struct {
+	rlock  sync.Mutex
+	rhdr   [frameHdrSize]byte
+	rhdrN  int    // bytes of rhdr already read
+	remain uint32 // unread bytes of the current data frame
+
+	wlock sync.Mutex
+	whdr  [frameHdrSize]byte
+	wpend int // bytes of whdr not yet written
+	wowed int // unwritten bytes of the current data frame
+
+	lock sync.Mutex
+	err  error     // set when the stream is reset
+	wdl  time.Time // write deadline set by the user
+}
+
+func newFramer() *framer { return new(framer) }
+
+func (f *framer) resetErr() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.err
+}
+
+// setReset records the reset, unless the stream was already reset.  It returns
+// false in the latter case.
+func (f *framer) setReset(err StreamResetError) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return false
+	}
+
+	f.err = err
+	return true
+}
+
+// SetWriteDeadline records the user's write deadline, so that it can be
+// restored after Reset has unblocked a pending write.
+func (f *framer) SetWriteDeadline(t time.Time) {
+	f.lock.Lock()
+	f.wdl = t
+	f.lock.Unlock()
+}
+
+func (f *framer) writeDeadline() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.wdl
+}
+
+func (f *framer) Read(r io.Reader, b []byte) (n int, err error) {
+	f.rlock.Lock()
+	defer f.rlock.Unlock()
+
+	if err = f.resetErr(); err != nil || len(b) == 0 {
+		return
+	}
+
+	for f.remain == 0 {
+		// The header may have been partially read by a previous call that
+		// failed, e.g. because of a deadline.
+		n, err = io.ReadFull(r, f.rhdr[f.rhdrN:])
+		if f.rhdrN += n; err != nil {
+			if err == io.ErrUnexpectedEOF || (err == io.EOF && f.rhdrN > 0) {
+				err = errors.Wrap(io.ErrUnexpectedEOF, "read frame header")
+			}
+			return 0, f.maybeReset(err)
+		}
+		f.rhdrN = 0
+
+		arg := binary.BigEndian.Uint32(f.rhdr[1:])
+		switch f.rhdr[0] {
+		case frameData:
+			f.remain = arg
+		case frameReset:
+			f.setReset(StreamResetError{Code: arg, Remote: true})
+			return 0, f.resetErr()
+		default:
+			return 0, errors.Errorf("unknown frame type %d", f.rhdr[0])
+		}
+	}
+
+	if len(b) > int(f.remain) {
+		b = b[:f.remain]
+	}
+
+	n, err = r.Read(b)
+	f.remain -= uint32(n)
+
+	if err == io.EOF && f.remain > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, f.maybeReset(err)
+}
+
+func (f *framer) Write(w io.Writer, b []byte) (n int, err error) {
+	f.wlock.Lock()
+	defer f.wlock.Unlock()
+
+	if err = f.resetErr(); err != nil {
+		return
+	}
+
+	var m int
+	for len(b) > 0 {
+		if f.wpend == 0 && f.wowed == 0 {
+			f.wowed = len(b)
+			if f.wowed > maxFrameSize {
+				f.wowed = maxFrameSize
+			}
+
+			f.whdr[0] = frameData
+			binary.BigEndian.PutUint32(f.whdr[1:], uint32(f.wowed))
+			f.wpend = frameHdrSize
+		}
+
+		if f.wpend > 0 {
+			m, err = w.Write(f.whdr[frameHdrSize-f.wpend:])
+			if f.wpend -= m; err != nil {
+				return n, f.maybeReset(err)
+			}
+		}
+
+		chunk := b
+		if len(chunk) > f.wowed {
+			chunk = chunk[:f.wowed]
+		}
+
+		m, err = w.Write(chunk)
+		n, f.wowed, b = n+m, f.wowed-m, b[m:]
+		if err != nil {
+			return n, f.maybeReset(err)
+		}
+	}
+
+	return
+}
+
+// CloseWrite calls fn once pending writes have completed.
+func (f *framer) CloseWrite(fn func() error) error {
+	f.wlock.Lock()
+	defer f.wlock.Unlock()
+
+	if err := f.resetErr(); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+type resetWriter interface {
+	io.Writer
+	SetWriteDeadline(time.Time) error
+}
+
+// Reset sends a reset frame, aborting any pending write.  It is a no-op if the
+// stream was already reset.
+func (f *framer) Reset(w resetWriter, code uint32) error {
+	if !f.setReset(StreamResetError{Code: code}) {
+		return nil
+	}
+
+	w.SetWriteDeadline(time.Unix(1, 0)) // unblock pending write
+	f.wlock.Lock()
+	defer f.wlock.Unlock()
+	w.SetWriteDeadline(f.writeDeadline())
+
+	// complete the interrupted frame, if any
+	b := make([]byte, 0, f.wpend+f.wowed+frameHdrSize)
+	b = append(b, f.whdr[frameHdrSize-f.wpend:]...)
+	b = append(b, make([]byte, f.wowed)...)
+	f.wpend, f.wowed = 0, 0
+
+	b = append(b, frameReset, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], code)
+
+	_, err := w.Write(b)
+	return err
+}
+
+// maybeReset replaces err with the reset error, if the stream was reset.  IO
+// that was aborted by Reset fails with the same error as subsequent calls.
+func (f *framer) maybeReset(err error) error {
+	if err != nil {
+		if rerr := f.resetErr(); rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,225 @@
+package host
+
+import (
+	"bytes"
+	"io"
+	gonet "net"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFramer(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, r := newFramer(), newFramer()
+
+		n, err := w.Write(&buf, []byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+
+		_, err = w.Write(&buf, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, frameHdrSize+5, buf.Len(), "empty write should not send a frame")
+
+		_, err = w.Write(&buf, []byte(", world"))
+		assert.NoError(t, err)
+
+		b, err := readAll(r, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello, world", string(b))
+	})
+
+	t.Run("ShortRead", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, r := newFramer(), newFramer()
+		w.Write(&buf, []byte("hello"))
+
+		b := make([]byte, 2)
+		n, err := r.Read(&buf, b)
+		assert.NoError(t, err)
+		assert.Equal(t, "he", string(b[:n]))
+		assert.Equal(t, uint32(3), r.remain)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		var buf bytes.Buffer
+		newFramer().Write(&buf, []byte("hello"))
+		buf.Truncate(frameHdrSize + 2)
+
+		_, err := readAll(newFramer(), &buf)
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+
+	t.Run("UnknownFrame", func(t *testing.T) {
+		buf := bytes.NewBuffer([]byte{0xff, 0, 0, 0, 0})
+		_, err := newFramer().Read(buf, make([]byte, 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		c0, c1 := gonet.Pipe()
+		defer c0.Close()
+		defer c1.Close()
+
+		w, r := newFramer(), newFramer()
+		go func() {
+			w.Write(c0, []byte("hello"))
+			w.Reset(c0, 42)
+		}()
+
+		b, err := readAll(r, c1)
+		assert.Equal(t, "hello", string(b))
+		assert.Equal(t, StreamResetError{Code: 42, Remote: true}, err)
+
+		_, err = r.Read(c1, make([]byte, 1))
+		assert.Equal(t, StreamResetError{Code: 42, Remote: true}, err, "reset should be sticky")
+
+		_, err = w.Write(c0, []byte("hello"))
+		assert.Equal(t, StreamResetError{Code: 42}, err)
+		assert.NoError(t, w.Reset(c0, 7), "second reset should be a no-op")
+	})
+
+	t.Run("LargeWrite", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, r := newFramer(), newFramer()
+
+		b := bytes.Repeat([]byte("x"), 2*maxFrameSize+1)
+		n, err := w.Write(&buf, b)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), n)
+		assert.Equal(t, len(b)+3*frameHdrSize, buf.Len(), "should be split into frames")
+
+		got, err := readAll(r, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, b, got)
+	})
+
+	t.Run("InterruptedWrite", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, r := newFramer(), newFramer()
+
+		// header and part of the payload are sent before the deadline
+		n, err := w.Write(&limitWriter{w: &buf, n: 7}, []byte("hello"))
+		assert.Equal(t, errTimeout, err)
+		assert.Equal(t, 2, n)
+
+		n, err = w.Write(&buf, []byte("llo, world"))
+		assert.NoError(t, err)
+		assert.Equal(t, 10, n)
+
+		got, err := readAll(r, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello, world", string(got))
+	})
+
+	t.Run("InterruptedRead", func(t *testing.T) {
+		var buf bytes.Buffer
+		newFramer().Write(&buf, []byte("hello"))
+
+		// the first read returns a byte of the header, the second times out
+		r := iotest.TimeoutReader(iotest.OneByteReader(&buf))
+		f := newFramer()
+
+		_, err := f.Read(r, make([]byte, 5))
+		assert.Equal(t, iotest.ErrTimeout, err)
+
+		got, err := readAll(f, r)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(got))
+	})
+
+	t.Run("ResetBlockedWrite", func(t *testing.T) {
+		c0, c1 := gonet.Pipe()
+		defer c0.Close()
+		defer c1.Close()
+
+		w, r := newFramer(), newFramer()
+		b := bytes.Repeat([]byte("x"), maxFrameSize)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := w.Write(c0, b)
+			errs <- err
+		}()
+
+		// consume part of the frame, so that the write is blocked mid-frame
+		got := make([]byte, 10)
+		_, err := io.ReadFull(readerFunc(func(p []byte) (int, error) {
+			return r.Read(c1, p)
+		}), got)
+		assert.NoError(t, err)
+
+		go w.Reset(c0, 9)
+
+		assert.Equal(t, StreamResetError{Code: 9}, <-errs)
+
+		rest, err := readAll(r, c1)
+		assert.Equal(t, StreamResetError{Code: 9, Remote: true}, err)
+		assert.Len(t, rest, maxFrameSize-len(got), "frame should be padded")
+	})
+
+	t.Run("ResetKeepsDeadline", func(t *testing.T) {
+		dl := time.Now().Add(time.Hour)
+		w := &deadlineWriter{}
+
+		f := newFramer()
+		f.SetWriteDeadline(dl)
+		assert.NoError(t, f.Reset(w, 1))
+		assert.Equal(t, dl, w.dl)
+	})
+}
+
+var errTimeout = errors.New("timeout")
+
+// limitWriter accepts n bytes, then fails with errTimeout once.
+type limitWriter struct {
+	w io.Writer
+	n int
+}
+
+func (lw *limitWriter) Write(b []byte) (int, error) {
+	if lw.n < 0 {
+		return lw.w.Write(b)
+	}
+
+	if len(b) <= lw.n {
+		lw.n -= len(b)
+		return lw.w.Write(b)
+	}
+
+	n, _ := lw.w.Write(b[:lw.n])
+	lw.n = -1
+	return n, errTimeout
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(b []byte) (int, error) { return f(b) }
+
+type deadlineWriter struct {
+	bytes.Buffer
+	dl time.Time
+}
+
+func (w *deadlineWriter) SetWriteDeadline(t time.Time) error {
+	w.dl = t
+	return nil
+}
+
+func readAll(f *framer, r io.Reader) ([]byte, error) {
+	var out []byte
+	b := make([]byte, 3)
+	for {
+		n, err := f.Read(r, b)
+		out = append(out, b[:n]...)
+		if err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return out, err
+		}
+	}
+}
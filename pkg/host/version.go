@@ -0,0 +1,154 @@
+package host
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version is a semantic version of the form MAJOR.MINOR.PATCH.  Pre-release and
+// build metadata are not supported.  The zero value denotes an unversioned
+// path.
+type Version struct {
+	Major, Minor, Patch uint64
+}
+
+// ParseVersion from a string such as "1.2.3".  A missing patch component
+// defaults to zero.  The minor component may only be omitted if the version has
+// a leading "v", e.g. "v2", so that plain integers are not taken for versions.
+func ParseVersion(s string) (v Version, err error) {
+	trimmed := strings.TrimPrefix(s, "v")
+	parts := strings.Split(trimmed, ".")
+	if len(parts) > 3 || (len(parts) < 2 && trimmed == s) {
+		return v, errors.Errorf("invalid version %q", s)
+	}
+
+	dst := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		if *dst[i], err = strconv.ParseUint(p, 10, 64); err != nil {
+			return v, errors.Errorf("invalid version %q", s)
+		}
+	}
+
+	return
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 if v is respectively less than, equal to, or
+// greater than u.
+func (v Version) Compare(u Version) int {
+	for _, d := range [][2]uint64{
+		{v.Major, u.Major},
+		{v.Minor, u.Minor},
+		{v.Patch, u.Patch},
+	} {
+		switch {
+		case d[0] < d[1]:
+			return -1
+		case d[0] > d[1]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// splitVersion separates a path of the form "/proto/1.2.3" into its base and
+// version.  It returns false if the last path segment is not a version.
+func splitVersion(path string) (base string, v Version, ok bool) {
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 {
+		return
+	}
+
+	var err error
+	if v, err = ParseVersion(path[i+1:]); err == nil {
+		base, ok = path[:i], true
+	}
+
+	return
+}
+
+type comparator struct {
+	op string
+	v  Version
+}
+
+func (c comparator) match(v Version) bool {
+	switch n := v.Compare(c.v); c.op {
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	default:
+		return n == 0
+	}
+}
+
+// Range is a set of versions.  A version is in the range if it satisfies every
+// comparator.
+type Range []comparator
+
+// ParseRange from a space-separated list of comparators, e.g.:
+// ">=1.0.0 <2.0.0".  The shorthands "^1.2.0" (compatible with 1.2.0) and
+// "~1.2.0" (patch releases of 1.2) are also accepted, as is "*" (any version).
+func ParseRange(s string) (Range, error) {
+	var r Range
+
+	for _, f := range strings.Fields(s) {
+		if f == "*" {
+			continue
+		}
+
+		op := f[:len(f)-len(strings.TrimLeft(f, "<>=^~"))]
+		v, err := ParseVersion(f[len(op):])
+		if err != nil {
+			return nil, errors.Wrap(err, "parse range")
+		}
+
+		switch op {
+		case "^":
+			r = append(r, comparator{">=", v}, comparator{"<", caretBound(v)})
+		case "~":
+			r = append(r,
+				comparator{">=", v},
+				comparator{"<", Version{Major: v.Major, Minor: v.Minor + 1}})
+		case "", "=", ">", ">=", "<", "<=":
+			r = append(r, comparator{op, v})
+		default:
+			return nil, errors.Errorf("parse range: invalid operator %q", op)
+		}
+	}
+
+	return r, nil
+}
+
+// caretBound returns the smallest version that is incompatible with v.
+func caretBound(v Version) Version {
+	switch {
+	case v.Major > 0:
+		return Version{Major: v.Major + 1}
+	case v.Minor > 0:
+		return Version{Minor: v.Minor + 1}
+	default:
+		return Version{Patch: v.Patch + 1}
+	}
+}
+
+// Contains returns true if v is in the range.
+func (r Range) Contains(v Version) bool {
+	for _, c := range r {
+		if !c.match(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	for s, expected := range map[string]Version{
+		"1.2.3":  {1, 2, 3},
+		"v1.2.3": {1, 2, 3},
+		"1.2":    {1, 2, 0},
+		"v1":     {1, 0, 0},
+	} {
+		v, err := ParseVersion(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, v, s)
+	}
+
+	for _, s := range []string{"", "1", "v", "1.2.3.4", "1.x", "echo"} {
+		_, err := ParseVersion(s)
+		assert.Error(t, err, s)
+	}
+
+	assert.Equal(t, "1.2.3", Version{1, 2, 3}.String())
+}
+
+func TestVersionCompare(t *testing.T) {
+	assert.Equal(t, 0, Version{1, 2, 3}.Compare(Version{1, 2, 3}))
+	assert.Equal(t, -1, Version{1, 2, 3}.Compare(Version{1, 3, 0}))
+	assert.Equal(t, 1, Version{2, 0, 0}.Compare(Version{1, 9, 9}))
+}
+
+func TestSplitVersion(t *testing.T) {
+	base, v, ok := splitVersion("/echo/1.0.0")
+	assert.True(t, ok)
+	assert.Equal(t, "/echo", base)
+	assert.Equal(t, Version{1, 0, 0}, v)
+
+	base, v, ok = splitVersion("/echo/v2")
+	assert.True(t, ok)
+	assert.Equal(t, "/echo", base)
+	assert.Equal(t, Version{2, 0, 0}, v)
+
+	for _, path := range []string{"/echo", "/kv/0", "/test/5"} {
+		_, _, ok = splitVersion(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestRange(t *testing.T) {
+	for rng, cases := range map[string]map[string]bool{
+		"^1.2.0":         {"1.2.0": true, "1.9.0": true, "2.0.0": false, "1.1.9": false},
+		"^0.2.0":         {"0.2.5": true, "0.3.0": false},
+		"^0.0.3":         {"0.0.3": true, "0.0.4": false},
+		"~1.2.0":         {"1.2.9": true, "1.3.0": false},
+		">=1.0.0 <2.0.0": {"1.0.0": true, "1.99.0": true, "2.0.0": false},
+		">1.0.0 <=1.1.0": {"1.0.0": false, "1.1.0": true},
+		"1.0.0":          {"1.0.0": true, "1.0.1": false},
+		"=1.0.0":         {"1.0.0": true, "1.0.1": false},
+		"*":              {"0.0.1": true, "9.9.9": true},
+	} {
+		r, err := ParseRange(rng)
+		assert.NoError(t, err, rng)
+
+		for s, expected := range cases {
+			v, _ := ParseVersion(s)
+			assert.Equal(t, expected, r.Contains(v), "%s in %s", s, rng)
+		}
+	}
+
+	for _, rng := range []string{"!1.0.0", ">=x", "^1", "~>1.0.0"} {
+		_, err := ParseRange(rng)
+		assert.Error(t, err, rng)
+	}
+}
@@ -0,0 +1,37 @@
+// Package metrics defines the interface through which casm packages report
+// measurements, and a Registry that exports them in the Prometheus text
+// exposition format.
+//
+// Instrumented packages accept a Sink, which defaults to Discard.  Metric
+// names follow Prometheus conventions, e.g.: casm_host_connections.
+package metrics
+
+// Label is a dimension of a metric, e.g.: path="/casm/ping/1.0.0".
+type Label struct {
+	Name, Value string
+}
+
+// L is shorthand for Label{Name: name, Value: value}.
+func L(name, value string) Label { return Label{Name: name, Value: value} }
+
+// Sink receives measurements.  Implementations MUST be safe for concurrent
+// use.  A metric's labels MUST have the same names each time it is reported.
+type Sink interface {
+	// IncrCounter adds delta, which MUST NOT be negative, to a counter.
+	IncrCounter(name string, delta float64, labels ...Label)
+
+	// AddGauge adds delta, which may be negative, to a gauge.
+	AddGauge(name string, delta float64, labels ...Label)
+
+	// SetGauge sets the value of a gauge.
+	SetGauge(name string, v float64, labels ...Label)
+}
+
+// Discard is a Sink that drops all measurements.
+var Discard Sink = discard{}
+
+type discard struct{}
+
+func (discard) IncrCounter(string, float64, ...Label) {}
+func (discard) AddGauge(string, float64, ...Label)    {}
+func (discard) SetGauge(string, float64, ...Label)    {}
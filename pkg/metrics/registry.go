@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Type of a metric.
+type Type uint8
+
+const (
+	// Untyped metrics have not been reported or described.
+	Untyped Type = iota
+	// Counter metrics only increase.
+	Counter
+	// Gauge metrics may increase or decrease.
+	Gauge
+)
+
+func (t Type) String() string {
+	switch t {
+	case Counter:
+		return "counter"
+	case Gauge:
+		return "gauge"
+	default:
+		return "untyped"
+	}
+}
+
+// Describer associates help text with metrics.
+type Describer interface {
+	Describe(name string, t Type, help string)
+}
+
+type family struct {
+	t      Type
+	help   string
+	series map[string]*series // keyed by encoded labels
+}
+
+type series struct {
+	labels string // encoded, e.g.: {path="/echo"}
+	v      float64
+}
+
+// Registry is a Sink that aggregates measurements in memory, and serves them
+// over HTTP in the Prometheus text format.  The zero value is NOT ready to
+// use; call NewRegistry.
+type Registry struct {
+	lock sync.Mutex
+	fs   map[string]*family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{fs: make(map[string]*family)}
+}
+
+// Describe a metric.  Describing a metric is optional, but produces HELP and
+// TYPE lines even before the metric is first reported.
+func (r *Registry) Describe(name string, t Type, help string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := r.familyUnsafe(name, t)
+	f.help = help
+}
+
+// IncrCounter satisfies Sink.
+func (r *Registry) IncrCounter(name string, delta float64, labels ...Label) {
+	r.update(name, Counter, labels, func(v float64) float64 { return v + delta })
+}
+
+// AddGauge satisfies Sink.
+func (r *Registry) AddGauge(name string, delta float64, labels ...Label) {
+	r.update(name, Gauge, labels, func(v float64) float64 { return v + delta })
+}
+
+// SetGauge satisfies Sink.
+func (r *Registry) SetGauge(name string, v float64, labels ...Label) {
+	r.update(name, Gauge, labels, func(float64) float64 { return v })
+}
+
+func (r *Registry) update(name string, t Type, labels []Label, fn func(float64) float64) {
+	key := encodeLabels(labels)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := r.familyUnsafe(name, t)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: key}
+		f.series[key] = s
+	}
+	s.v = fn(s.v)
+}
+
+func (r *Registry) familyUnsafe(name string, t Type) *family {
+	f, ok := r.fs[name]
+	if !ok {
+		f = &family{series: make(map[string]*series)}
+		r.fs[name] = f
+	}
+
+	if f.t == Untyped {
+		f.t = t
+	}
+
+	return f
+}
+
+// Value of a series, or zero if it has not been reported.
+func (r *Registry) Value(name string, labels ...Label) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if f, ok := r.fs[name]; ok {
+		if s, ok := f.series[encodeLabels(labels)]; ok {
+			return s.v
+		}
+	}
+
+	return 0
+}
+
+// WriteTo writes all metrics to w in the Prometheus text format.  Metrics and
+// series are sorted, so that the output is deterministic.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	r.lock.Lock()
+	names := make([]string, 0, len(r.fs))
+	for name := range r.fs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.fs[name]
+
+		if f.help != "" {
+			fmt.Fprintf(cw, "# HELP %s %s\n", name, escapeHelp(f.help))
+		}
+		fmt.Fprintf(cw, "# TYPE %s %s\n", name, f.t)
+
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(cw, "%s%s %s\n", name, k, formatValue(f.series[k].v))
+		}
+	}
+	r.lock.Unlock()
+
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+
+	return cw.n, cw.err
+}
+
+// ServeHTTP exports the registry's metrics.  It is typically mounted at
+// /metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// encodeLabels sorts labels by name, and formats them as {a="x",b="y"}.
+func encodeLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	ls := make([]Label, len(labels))
+	copy(ls, labels)
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range ls {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
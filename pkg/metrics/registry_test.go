@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	t.Run("Counter", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.IncrCounter("casm_test_total", 1, L("path", "/echo"), L("dir", "in"))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 10.0, r.Value("casm_test_total", L("dir", "in"), L("path", "/echo")),
+			"label order should not matter")
+	})
+
+	t.Run("Gauge", func(t *testing.T) {
+		r.AddGauge("casm_test_gauge", 3)
+		r.AddGauge("casm_test_gauge", -1)
+		assert.Equal(t, 2.0, r.Value("casm_test_gauge"))
+
+		r.SetGauge("casm_test_gauge", 7)
+		assert.Equal(t, 7.0, r.Value("casm_test_gauge"))
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		r.Describe("casm_test_total", Counter, "Test counter.")
+		r.Describe("casm_test_unused", Gauge, "Never reported.")
+		r.IncrCounter("casm_test_total", 1, L("path", `a"b\c`), L("dir", "out"))
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+		assert.Equal(t, strings.Join([]string{
+			"# TYPE casm_test_gauge gauge",
+			"casm_test_gauge 7",
+			"# HELP casm_test_total Test counter.",
+			"# TYPE casm_test_total counter",
+			`casm_test_total{dir="in",path="/echo"} 10`,
+			`casm_test_total{dir="out",path="a\"b\\c"} 1`,
+			"# HELP casm_test_unused Never reported.",
+			"# TYPE casm_test_unused gauge",
+			"",
+		}, "\n"), rec.Body.String())
+	})
+}
@@ -0,0 +1,162 @@
+// Package msgio provides length-prefixed message framing on top of byte streams
+// such as host.Stream.
+//
+// Each message is preceded by its length, encoded as an unsigned varint.
+// Buffers returned by ReadMsg are drawn from a pool, and SHOULD be returned via
+// ReleaseMsg once the caller is done with them.
+package msgio
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+
+	host "github.com/lthibault/casm/pkg/host"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxSize is the default maximum message size, in bytes.
+const DefaultMaxSize = 1 << 20
+
+// ErrMsgTooLarge is returned when a message exceeds the maximum size.
+var ErrMsgTooLarge = errors.New("message too large")
+
+// Reader reads length-prefixed messages.
+type Reader interface {
+	ReadMsg() ([]byte, error)
+	ReleaseMsg([]byte)
+}
+
+// Writer writes length-prefixed messages.
+type Writer interface {
+	WriteMsg([]byte) error
+}
+
+// ReadWriter groups Reader and Writer.
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+type reader struct {
+	lock sync.Mutex
+	r    io.Reader
+	br   io.ByteReader
+	max  int
+}
+
+// NewReader returns a Reader that refuses messages larger than max bytes.  If
+// max is zero or less, DefaultMaxSize is used.
+//
+// The Reader never consumes more bytes from r than it returns, so r can safely
+// be handed off to another reader between messages.
+func NewReader(r io.Reader, max int) Reader {
+	if max <= 0 {
+		max = DefaultMaxSize
+	}
+
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = &byteReader{Reader: r}
+	}
+
+	return &reader{r: r, br: br, max: max}
+}
+
+// ReadMsg reads the next message.  The returned buffer is only valid until it
+// is passed to ReleaseMsg.
+func (r *reader) ReadMsg() ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n, err := binary.ReadUvarint(r.br)
+	if err == io.EOF {
+		return nil, io.EOF // clean shutdown between messages
+	} else if err != nil {
+		return nil, errors.Wrap(err, "read len")
+	}
+
+	if n > uint64(r.max) {
+		return nil, ErrMsgTooLarge
+	}
+
+	b := pool.Get(int(n))
+	if _, err = io.ReadFull(r.r, b); err != nil {
+		pool.Put(b)
+		return nil, errors.Wrap(err, "read msg")
+	}
+
+	return b, nil
+}
+
+// ReleaseMsg returns a buffer obtained from ReadMsg to the pool.
+func (r *reader) ReleaseMsg(b []byte) { pool.Put(b) }
+
+// byteReader reads varint prefixes one byte at a time, without buffering.
+type byteReader struct {
+	io.Reader
+	b [1]byte
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(r.Reader, r.b[:])
+	return r.b[0], err
+}
+
+type writer struct {
+	lock sync.Mutex
+	w    io.Writer
+	max  int
+}
+
+// NewWriter returns a Writer that refuses messages larger than max bytes.  If
+// max is zero or less, DefaultMaxSize is used.  Messages are written with a
+// single call to w.Write, so concurrent calls to WriteMsg do not interleave.
+func NewWriter(w io.Writer, max int) Writer {
+	if max <= 0 {
+		max = DefaultMaxSize
+	}
+
+	return &writer{w: w, max: max}
+}
+
+// WriteMsg writes b, prefixed by its length.
+func (w *writer) WriteMsg(b []byte) error {
+	if len(b) > w.max {
+		return ErrMsgTooLarge
+	}
+
+	buf := pool.Get(binary.MaxVarintLen64 + len(b))
+	defer pool.Put(buf)
+
+	n := binary.PutUvarint(buf, uint64(len(b)))
+	n += copy(buf[n:], b)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, err := w.w.Write(buf[:n])
+	return errors.Wrap(err, "write msg")
+}
+
+type readWriter struct {
+	Reader
+	Writer
+}
+
+// NewReadWriter combines NewReader and NewWriter.
+func NewReadWriter(rw io.ReadWriter, max int) ReadWriter {
+	return readWriter{Reader: NewReader(rw, max), Writer: NewWriter(rw, max)}
+}
+
+// Stream is a host.Stream that additionally reads and writes framed messages.
+type Stream struct {
+	host.Stream
+	ReadWriter
+}
+
+// Wrap a stream.  Callers SHOULD NOT mix calls to Read/Write with calls to
+// ReadMsg/WriteMsg.
+func Wrap(s host.Stream, max int) Stream {
+	return Stream{Stream: s, ReadWriter: NewReadWriter(s, max)}
+}
@@ -0,0 +1,107 @@
+package msgio
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWriter(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		var buf bytes.Buffer
+		rw := NewReadWriter(&buf, 0)
+
+		msgs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte{'x'}, 300)}
+		for _, m := range msgs {
+			assert.NoError(t, rw.WriteMsg(m))
+		}
+
+		for _, m := range msgs {
+			b, err := rw.ReadMsg()
+			assert.NoError(t, err)
+			assert.Equal(t, m, b)
+			rw.ReleaseMsg(b)
+		}
+
+		_, err := rw.ReadMsg()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("MaxSize", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Equal(t, ErrMsgTooLarge, NewWriter(&buf, 4).WriteMsg([]byte("hello")))
+		assert.Zero(t, buf.Len())
+
+		assert.NoError(t, NewWriter(&buf, 0).WriteMsg([]byte("hello")))
+		_, err := NewReader(&buf, 4).ReadMsg()
+		assert.Equal(t, ErrMsgTooLarge, err)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, NewWriter(&buf, 0).WriteMsg([]byte("hello")))
+		buf.Truncate(3)
+
+		_, err := NewReader(&buf, 0).ReadMsg()
+		assert.Equal(t, io.ErrUnexpectedEOF, errors.Cause(err))
+	})
+
+	t.Run("NoReadAhead", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, NewWriter(&buf, 0).WriteMsg([]byte("hello")))
+		buf.WriteString("trailer")
+
+		// hide bytes.Buffer's ReadByte method
+		r := NewReader(struct{ io.Reader }{&buf}, 0)
+		b, err := r.ReadMsg()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), b)
+		assert.Equal(t, "trailer", buf.String())
+	})
+
+	t.Run("ConcurrentWrites", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, 0)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.WriteMsg(bytes.Repeat([]byte{'x'}, 1024))
+			}()
+		}
+		wg.Wait()
+
+		r := NewReader(&buf, 0)
+		for i := 0; i < 16; i++ {
+			b, err := r.ReadMsg()
+			assert.NoError(t, err)
+			assert.Len(t, b, 1024)
+		}
+	})
+}
+
+func TestBufPool(t *testing.T) {
+	p := newBufPool(minPoolSize, 10)
+
+	for _, tc := range []struct {
+		n, cap int
+	}{
+		{0, 64},
+		{1, 64},
+		{64, 64},
+		{65, 128},
+		{1024, 1024},
+		{1025, 1025}, // larger than the biggest class; not pooled
+	} {
+		b := p.Get(tc.n)
+		assert.Len(t, b, tc.n)
+		assert.Equal(t, tc.cap, cap(b), "n=%d", tc.n)
+		p.Put(b)
+	}
+}
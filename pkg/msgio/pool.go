@@ -0,0 +1,59 @@
+package msgio
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sync"
+)
+
+// minPoolSize is the capacity of the smallest pooled buffer, as a power of two.
+const minPoolSize = 6
+
+// pool holds buffers large enough for a maximally-sized message and its header.
+var pool = newBufPool(minPoolSize, bits.Len(DefaultMaxSize+binary.MaxVarintLen64))
+
+// bufPool maintains one sync.Pool per power-of-two buffer capacity.  Buffers
+// larger than the biggest class are allocated on demand and not pooled.
+type bufPool struct {
+	min   int
+	pools []sync.Pool
+}
+
+func newBufPool(min, max int) *bufPool {
+	p := &bufPool{min: min, pools: make([]sync.Pool, max-min+1)}
+	for i := range p.pools {
+		size := 1 << uint(min+i)
+		p.pools[i].New = func() interface{} { return make([]byte, size) }
+	}
+	return p
+}
+
+// class returns the index of the smallest pool whose buffers hold n bytes.
+func (p *bufPool) class(n int) int {
+	if n <= 1 {
+		return 0
+	}
+
+	c := bits.Len(uint(n - 1))
+	if c < p.min {
+		return 0
+	}
+	return c - p.min
+}
+
+// Get a buffer of length n.
+func (p *bufPool) Get(n int) []byte {
+	if c := p.class(n); c < len(p.pools) {
+		return p.pools[c].Get().([]byte)[:n]
+	}
+	return make([]byte, n)
+}
+
+// Put a buffer back into the pool.  Buffers whose capacity is not a pooled size
+// are discarded.
+func (p *bufPool) Put(b []byte) {
+	c := p.class(cap(b))
+	if c < len(p.pools) && cap(b) == 1<<uint(c+p.min) {
+		p.pools[c].Put(b[:cap(b)])
+	}
+}
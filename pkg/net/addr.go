@@ -1,8 +1,10 @@
 package net
 
 import (
+	"fmt"
 	"io"
 	"net"
+	"strings"
 
 	"github.com/lunixbochs/struc"
 )
@@ -31,6 +33,46 @@ func (a addr) Network() string { return a.network }
 func (a addr) Proto() string   { return a.proto }
 func (a addr) String() string  { return a.addr }
 
+// ParseAddr from its textual representation, "<peer id>@<proto>://<addr>",
+// e.g.:  "0123456789abcdef@tcp://127.0.0.1:9020".  The network is taken to be
+// the same as the protocol.
+func ParseAddr(s string) (Addr, error) {
+	at := strings.IndexByte(s, '@')
+	sep := strings.Index(s, "://")
+	if at < 0 || sep < at {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+
+	id, err := ParsePeerID(s[:at])
+	if err != nil {
+		return nil, err
+	}
+
+	proto, a := s[at+1:sep], s[sep+3:]
+	if proto == "" || a == "" {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+
+	return NewAddr(id, proto, proto, a), nil
+}
+
+// FormatAddr as expected by ParseAddr.
+func FormatAddr(a Addr) string {
+	return fmt.Sprintf("%s@%s://%s", a.ID(), a.Proto(), a.String())
+}
+
+// SendAddr writes a in the binary format used by the casm network protocol.
+func SendAddr(w io.Writer, a Addr) error { return newWireAddr(a).SendTo(w) }
+
+// RecvAddr reads an address written by SendAddr.
+func RecvAddr(r io.Reader) (Addr, error) {
+	a := new(wireAddr)
+	if err := a.RecvFrom(r); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
 type wireAddr struct {
 	PID      PeerID `struc:"uint64"`
 	NetLen   int    `struc:"uint8,sizeof=NetStr"`
@@ -12,6 +12,33 @@ func TestAddr(t *testing.T) {
 	assert.Equal(t, a, a.Addr())
 }
 
+func TestParseAddr(t *testing.T) {
+	id := New()
+
+	a, err := ParseAddr(id.String() + "@tcp://127.0.0.1:9020")
+	assert.NoError(t, err)
+	assert.Equal(t, id, a.ID())
+	assert.Equal(t, "tcp", a.Proto())
+	assert.Equal(t, "tcp", a.Network())
+	assert.Equal(t, "127.0.0.1:9020", a.String())
+	assert.Equal(t, id.String()+"@tcp://127.0.0.1:9020", FormatAddr(a))
+
+	a, err = ParseAddr(id.String() + "@inproc:///host")
+	assert.NoError(t, err)
+	assert.Equal(t, "/host", a.String())
+
+	for _, s := range []string{
+		"",
+		"tcp://127.0.0.1:9020",
+		id.String() + "@127.0.0.1:9020",
+		"nope@tcp://127.0.0.1:9020",
+		id.String() + "@tcp://",
+	} {
+		_, err = ParseAddr(s)
+		assert.Error(t, err, s)
+	}
+}
+
 func TestWireAddr(t *testing.T) {
 	var wa *wireAddr
 	b := new(bytes.Buffer)
@@ -4,6 +4,7 @@ package net
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
 	"time"
 )
 
@@ -19,3 +20,12 @@ func (id PeerID) String() string { return fmt.Sprintf("%016x", uint64(id)) }
 
 // ID satisfies the IDer interface
 func (id PeerID) ID() PeerID { return id }
+
+// ParsePeerID from its string representation, as returned by PeerID.String.
+func ParsePeerID(s string) (PeerID, error) {
+	id, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid peer id %q", s)
+	}
+	return PeerID(id), nil
+}
@@ -9,4 +9,13 @@ import (
 func TestID(t *testing.T) {
 	id := New()
 	assert.Equal(t, id, id.ID())
+
+	t.Run("Parse", func(t *testing.T) {
+		got, err := ParsePeerID(id.String())
+		assert.NoError(t, err)
+		assert.Equal(t, id, got)
+
+		_, err = ParsePeerID("not an id")
+		assert.Error(t, err)
+	})
 }
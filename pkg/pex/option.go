@@ -0,0 +1,99 @@
+package pex
+
+import (
+	"time"
+
+	log "github.com/lthibault/log/pkg"
+)
+
+// Option for PEX.
+type Option func(*PEX) (prev Option)
+
+func setDefaultOpts(opt []Option) []Option {
+	return append(
+		[]Option{
+			OptLogger(nil),
+			OptFanout(3),
+			OptSampleSize(16),
+			OptInterval(time.Second * 30),
+			OptRateLimit(time.Second * 10),
+			OptTTL(time.Minute * 10),
+			OptTimeout(time.Second * 10),
+		},
+		opt...,
+	)
+}
+
+// OptLogger sets the logger.
+func OptLogger(l log.Logger) Option {
+	if l == nil {
+		l = log.New()
+	}
+
+	return func(p *PEX) (prev Option) {
+		prev = OptLogger(p.log)
+		p.log = l
+		return
+	}
+}
+
+// OptFanout sets the number of peers contacted in each gossip round.
+func OptFanout(n int) Option {
+	return func(p *PEX) (prev Option) {
+		prev = OptFanout(p.fanout)
+		p.fanout = n
+		return
+	}
+}
+
+// OptSampleSize sets the maximum number of addresses sent in each exchange.
+// It is capped at 255.
+func OptSampleSize(n int) Option {
+	if n > maxSampleSize {
+		n = maxSampleSize
+	}
+
+	return func(p *PEX) (prev Option) {
+		prev = OptSampleSize(p.sampleSize)
+		p.sampleSize = n
+		return
+	}
+}
+
+// OptInterval sets the delay between gossip rounds.
+func OptInterval(d time.Duration) Option {
+	return func(p *PEX) (prev Option) {
+		prev = OptInterval(p.interval)
+		p.interval = d
+		return
+	}
+}
+
+// OptRateLimit sets the minimum delay between exchanges initiated by the same
+// remote peer.  Exchanges arriving sooner are refused.
+func OptRateLimit(d time.Duration) Option {
+	return func(p *PEX) (prev Option) {
+		prev = OptRateLimit(p.minDelay)
+		p.minDelay = d
+		return
+	}
+}
+
+// OptTTL sets the time for which discovered addresses are kept in the address
+// book.
+func OptTTL(d time.Duration) Option {
+	return func(p *PEX) (prev Option) {
+		prev = OptTTL(p.ttl)
+		p.ttl = d
+		return
+	}
+}
+
+// OptTimeout bounds the duration of each exchange.
+func OptTimeout(d time.Duration) Option {
+	return func(p *PEX) (prev Option) {
+		prev = OptTimeout(p.timeout)
+		p.timeout = d
+		return
+	}
+}
@@ -0,0 +1,257 @@
+// Package pex implements peer exchange.
+//
+// Connected hosts periodically trade random samples of their address books,
+// allowing a cluster to grow from a single bootstrap address.  Each exchange
+// is symmetric:  the initiator sends its sample, and the responder replies with
+// its own.  Addresses learned through PEX are recorded in the address book with
+// host.SourceDiscovery.
+package pex
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	gonet "net"
+	"sync"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// Path on which the PEX handler is registered.
+const Path = "/casm/pex/1.0.0"
+
+const maxSampleSize = 255
+
+// Host is the subset of *host.Host used by PEX.
+type Host interface {
+	casm.IDer
+	Addr() net.Addr
+	Register(string, host.Handler)
+	Dial(context.Context, casm.IDer, string) (gonet.Conn, error)
+	Peers() []net.PeerID
+	Connected() []net.PeerID
+	Addrs(casm.IDer) []net.Addr
+	AddAddr(casm.Addresser, host.AddrSource, time.Duration)
+	Banned(casm.IDer) bool
+}
+
+// PEX exchanges address book samples with connected peers.
+type PEX struct {
+	log log.Logger
+	h   Host
+
+	fanout, sampleSize int
+	interval, minDelay time.Duration
+	ttl, timeout       time.Duration
+
+	lock sync.Mutex
+	last map[net.PeerID]time.Time // last exchange accepted from each peer
+}
+
+// New PEX, registered on the host's mux.  Periodic exchanges begin when Start
+// is called.
+func New(h Host, opt ...Option) *PEX {
+	p := &PEX{h: h, last: make(map[net.PeerID]time.Time)}
+	for _, fn := range setDefaultOpts(opt) {
+		fn(p)
+	}
+
+	h.Register(Path, handler{p})
+	return p
+}
+
+// Start exchanging samples with random peers at regular intervals, until c
+// expires.
+func (p *PEX) Start(c context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-ticker.C:
+				if err := p.Gossip(c); err != nil {
+					p.log.WithError(err).Debug("gossip round failed")
+				}
+			}
+		}
+	}()
+}
+
+// Gossip performs one round of exchanges, with up to fanout randomly-selected
+// connected peers.  It returns the first error encountered, if any.
+func (p *PEX) Gossip(c context.Context) error {
+	peers := p.h.Connected()
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > p.fanout {
+		peers = peers[:p.fanout]
+	}
+
+	var g errgroup.Group
+	for _, id := range peers {
+		id := id
+		g.Go(func() error { return errors.Wrap(p.Exchange(c, id), id.String()) })
+	}
+
+	return g.Wait()
+}
+
+// Exchange samples with a single peer.
+func (p *PEX) Exchange(c context.Context, id casm.IDer) error {
+	c, cancel := context.WithTimeout(c, p.timeout)
+	defer cancel()
+
+	conn, err := p.h.Dial(c, id, Path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	dl, _ := c.Deadline()
+	if err = conn.SetDeadline(dl); err != nil {
+		return errors.Wrap(err, "set deadline")
+	}
+
+	if err = sendSample(conn, p.Sample(id.ID())); err != nil {
+		return errors.Wrap(err, "send sample")
+	}
+
+	as, err := recvSample(conn)
+	if err != nil {
+		return errors.Wrap(err, "recv sample")
+	}
+
+	p.ingest(as)
+	return nil
+}
+
+// Sample returns a random subset of the address book, including the host's own
+// address.  Banned peers, peers with no known address, and the peer for which
+// the sample is intended are excluded.
+func (p *PEX) Sample(exclude net.PeerID) []net.Addr {
+	ids := p.h.Peers()
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+
+	sample := []net.Addr{p.h.Addr()}
+	for _, id := range ids {
+		if len(sample) == p.sampleSize {
+			break
+		}
+
+		if id == exclude || id == p.h.ID() || p.h.Banned(id) {
+			continue
+		}
+
+		if as := p.h.Addrs(id); len(as) > 0 {
+			sample = append(sample, as[0]) // best address
+		}
+	}
+
+	return sample
+}
+
+func (p *PEX) ingest(as []net.Addr) {
+	for _, a := range as {
+		if a.ID() != p.h.ID() && !p.h.Banned(a) {
+			p.h.AddAddr(a, host.SourceDiscovery, p.ttl)
+		}
+	}
+}
+
+// allow returns true if the peer has not completed an exchange within the
+// minimum delay, and records the exchange.
+func (p *PEX) allow(id net.PeerID) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	if t, ok := p.last[id]; ok && now.Sub(t) < p.minDelay {
+		return false
+	}
+
+	// opportunistically forget stale entries
+	for id, t := range p.last {
+		if now.Sub(t) >= p.minDelay {
+			delete(p.last, id)
+		}
+	}
+
+	p.last[id] = now
+	return true
+}
+
+// handler responds to exchanges initiated by remote peers.
+type handler struct{ *PEX }
+
+// Accept refuses peers that initiate exchanges more often than the minimum
+// delay allows.
+func (h handler) Accept(_ string, remote net.Addr) host.AckCode {
+	if !h.allow(remote.ID()) {
+		return host.AckRefused
+	}
+	return host.AckOK
+}
+
+func (h handler) Serve(s host.Stream) {
+	defer s.Close()
+
+	if err := s.SetDeadline(time.Now().Add(h.timeout)); err != nil {
+		return
+	}
+
+	as, err := recvSample(s)
+	if err != nil {
+		h.log.WithError(err).Debug("failed to read sample")
+		return
+	}
+
+	if err = sendSample(s, h.Sample(s.RemoteAddr().ID())); err != nil {
+		h.log.WithError(err).Debug("failed to send sample")
+		return
+	}
+
+	h.ingest(as)
+}
+
+func sendSample(w io.Writer, as []net.Addr) error {
+	if len(as) > maxSampleSize {
+		as = as[:maxSampleSize]
+	}
+
+	if _, err := w.Write([]byte{uint8(len(as))}); err != nil {
+		return err
+	}
+
+	for _, a := range as {
+		if err := net.SendAddr(w, a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func recvSample(r io.Reader) ([]net.Addr, error) {
+	var n [1]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+
+	as := make([]net.Addr, n[0])
+	for i := range as {
+		var err error
+		if as[i], err = net.RecvAddr(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return as, nil
+}
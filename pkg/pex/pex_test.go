@@ -0,0 +1,87 @@
+package pex
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSample(t *testing.T) {
+	var buf bytes.Buffer
+	as := []net.Addr{
+		net.NewAddr(net.New(), "", "inproc", "/a"),
+		net.NewAddr(net.New(), "tcp", "tcp", "127.0.0.1:9020"),
+	}
+
+	assert.NoError(t, sendSample(&buf, as))
+
+	got, err := recvSample(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, got, len(as))
+	for i := range as {
+		assert.Equal(t, as[i].ID(), got[i].ID())
+		assert.Equal(t, as[i].Proto(), got[i].Proto())
+		assert.Equal(t, as[i].String(), got[i].String())
+	}
+}
+
+func TestPEX(t *testing.T) {
+	l := log.New(log.OptLevel(log.NullLevel))
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(l),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hs := make([]*host.Host, 3)
+	as := make([]net.Addr, 3)
+	ps := make([]*PEX, 3)
+	for i := range hs {
+		hs[i] = host.New(opt...)
+		as[i] = net.NewAddr(net.New(), "", "inproc", "/pex/"+string(rune('a'+i)))
+		ps[i] = New(hs[i], OptLogger(l), OptRateLimit(time.Minute))
+		assert.NoError(t, hs[i].Start(c, as[i]))
+	}
+
+	// h1 and h2 each know h0, but not each other.
+	assert.NoError(t, hs[1].Connect(c, as[0]))
+	assert.NoError(t, hs[2].Connect(c, as[0]))
+	assert.Empty(t, hs[1].Addrs(as[2]))
+
+	t.Run("Exchange", func(t *testing.T) {
+		assert.NoError(t, ps[1].Gossip(c))
+
+		assert.Equal(t, []net.Addr{as[2]}, addrsOf(hs[1], as[2]))
+		assert.NoError(t, hs[1].Connect(c, as[2].ID()))
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		err := ps[1].Exchange(c, as[0])
+		assert.Equal(t, host.OpenError{Path: Path, Code: host.AckRefused}, errors.Cause(err))
+	})
+
+	t.Run("SampleExcludesRecipient", func(t *testing.T) {
+		for _, a := range ps[0].Sample(as[1].ID()) {
+			assert.NotEqual(t, as[1].ID(), a.ID())
+		}
+	})
+}
+
+// addrsOf returns the addresses the host knows for the peer, normalized for
+// comparison.
+func addrsOf(h *host.Host, id net.Addr) (out []net.Addr) {
+	for _, a := range h.Addrs(id) {
+		out = append(out, net.NewAddr(a.ID(), a.Network(), a.Proto(), a.String()))
+	}
+	return
+}
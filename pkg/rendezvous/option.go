@@ -0,0 +1,65 @@
+package rendezvous
+
+import (
+	"time"
+
+	log "github.com/lthibault/log/pkg"
+)
+
+// Option for Server.
+type Option func(*Server) (prev Option)
+
+func setDefaultOpts(opt []Option) []Option {
+	return append(
+		[]Option{
+			OptLogger(nil),
+			OptMaxTTL(time.Hour * 2),
+			OptMaxRegistrations(1000),
+			OptMaxNamespaces(100),
+		},
+		opt...,
+	)
+}
+
+// OptLogger sets the logger.
+func OptLogger(l log.Logger) Option {
+	if l == nil {
+		l = log.New()
+	}
+
+	return func(srv *Server) (prev Option) {
+		prev = OptLogger(srv.log)
+		srv.log = l
+		return
+	}
+}
+
+// OptMaxTTL sets the longest TTL granted to a registration.  Requests for a
+// longer (or zero) TTL are granted the maximum.
+func OptMaxTTL(d time.Duration) Option {
+	return func(srv *Server) (prev Option) {
+		prev = OptMaxTTL(srv.maxTTL)
+		srv.maxTTL = d
+		return
+	}
+}
+
+// OptMaxRegistrations sets the maximum number of live registrations per
+// namespace.  Further registrations are refused until existing ones expire.
+func OptMaxRegistrations(n int) Option {
+	return func(srv *Server) (prev Option) {
+		prev = OptMaxRegistrations(srv.maxReg)
+		srv.maxReg = n
+		return
+	}
+}
+
+// OptMaxNamespaces sets the maximum number of namespaces in which each peer
+// may be registered at once.  Zero means no limit.
+func OptMaxNamespaces(n int) Option {
+	return func(srv *Server) (prev Option) {
+		prev = OptMaxNamespaces(srv.maxNS)
+		srv.maxNS = n
+		return
+	}
+}
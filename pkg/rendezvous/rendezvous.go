@@ -0,0 +1,220 @@
+// Package rendezvous implements a simple protocol by which peers find each
+// other by topic.
+//
+// Well-known rendezvous hosts run a Server.  Peers Register their address in a
+// namespace for a limited time, and Discover the addresses registered by
+// others.  Discovery is paginated by an opaque cookie, so that a client may
+// poll a namespace and receive only new registrations.
+package rendezvous
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/rpc"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+)
+
+// Path on which the rendezvous server is registered.
+const Path = "/casm/rendezvous/1.0.0"
+
+// Host is the subset of *host.Host used by the rendezvous protocol.
+type Host interface {
+	casm.IDer
+	Addr() net.Addr
+	Register(string, host.Handler)
+	Open(context.Context, casm.Addresser, string) (host.Stream, error)
+	AddAddr(casm.Addresser, host.AddrSource, time.Duration)
+}
+
+// Server accepts registrations on behalf of a rendezvous host.
+type Server struct {
+	log log.Logger
+
+	maxTTL        time.Duration
+	maxReg, maxNS int
+
+	s *store
+}
+
+// NewServer registers a rendezvous server on the host.
+func NewServer(h Host, opt ...Option) *Server {
+	srv := &Server{}
+	for _, fn := range setDefaultOpts(opt) {
+		fn(srv)
+	}
+
+	srv.s = newStore(srv.maxReg, srv.maxNS)
+
+	rs := rpc.NewServer(srv.log)
+	rs.Register(methodRegister, srv.handler(srv.handleRegister))
+	rs.Register(methodUnregister, srv.handler(srv.handleUnregister))
+	rs.Register(methodDiscover, srv.handler(srv.handleDiscover))
+	h.Register(Path, rs)
+
+	return srv
+}
+
+// handler adapts fn to an rpc.StreamHandler, passing it the caller's ID.
+func (srv *Server) handler(fn func(net.PeerID, []byte) ([]byte, error)) rpc.StreamHandler {
+	return func(s rpc.ServerStream) error {
+		id := s.RemoteAddr().ID()
+		return rpc.Unary(func(_ context.Context, req []byte) ([]byte, error) {
+			return fn(id, req)
+		})(s)
+	}
+}
+
+func (srv *Server) handleRegister(remote net.PeerID, b []byte) ([]byte, error) {
+	var req registerRequest
+	if err := req.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+
+	if req.Addr.ID() != remote {
+		return nil, rpc.Errorf(rpc.CodeUnknown, "addr %s does not match caller", req.Addr.ID())
+	}
+
+	if req.TTL <= 0 || req.TTL > srv.maxTTL {
+		req.TTL = srv.maxTTL
+	}
+
+	switch err := srv.s.Add(req.Namespace, req.Addr, req.TTL); err {
+	case nil:
+	case errNamespaceFull:
+		return nil, rpc.Errorf(rpc.CodeUnavailable, "namespace %s is full", req.Namespace)
+	default:
+		return nil, rpc.Errorf(rpc.CodeResourceExhausted, "%s", err)
+	}
+
+	srv.log.WithFields(log.F{
+		"ns":   req.Namespace,
+		"peer": remote,
+		"ttl":  req.TTL,
+	}).Debug("registered")
+
+	return encodeTTL(req.TTL), nil
+}
+
+func (srv *Server) handleUnregister(remote net.PeerID, b []byte) ([]byte, error) {
+	ns, err := readNamespace(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	srv.s.Remove(ns, remote)
+	return nil, nil
+}
+
+func (srv *Server) handleDiscover(_ net.PeerID, b []byte) ([]byte, error) {
+	var req discoverRequest
+	if err := req.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = MaxDiscoverLimit
+	}
+
+	rs, cookie := srv.s.List(req.Namespace, req.Cookie, limit)
+
+	now := time.Now()
+	res := discoverResponse{Cookie: cookie, Registrations: make([]Registration, len(rs))}
+	for i, r := range rs {
+		res.Registrations[i] = Registration{Addr: r.addr, TTL: r.exp.Sub(now)}
+	}
+
+	return res.MarshalBinary()
+}
+
+// Client registers with, and discovers peers through, rendezvous hosts.
+type Client struct {
+	h  Host
+	cl *rpc.Client
+}
+
+// NewClient returns a Client that issues requests from h.
+func NewClient(l log.Logger, h Host) *Client {
+	return &Client{h: h, cl: rpc.NewClientForPath(l, h, Path)}
+}
+
+// Close the client's streams to rendezvous hosts.
+func (c *Client) Close() error { return c.cl.Close() }
+
+// Register the host's address in the namespace.  The rendezvous host may
+// grant a shorter TTL than requested; the granted TTL is returned.  A ttl of
+// zero requests the maximum.  Registrations expire, so Register should be
+// called periodically.
+func (c *Client) Register(ctx context.Context, rdv casm.Addresser, ns string, ttl time.Duration) (time.Duration, error) {
+	req, err := registerRequest{Namespace: ns, TTL: ttl, Addr: c.h.Addr()}.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := c.cl.Call(ctx, rdv, methodRegister, req)
+	if err != nil {
+		return 0, errors.Wrap(err, "register")
+	}
+
+	return decodeTTL(res)
+}
+
+// Unregister the host from the namespace.
+func (c *Client) Unregister(ctx context.Context, rdv casm.Addresser, ns string) error {
+	var buf bytes.Buffer
+	if err := writeNamespace(&buf, ns); err != nil {
+		return err
+	}
+
+	_, err := c.cl.Call(ctx, rdv, methodUnregister, buf.Bytes())
+	return errors.Wrap(err, "unregister")
+}
+
+// Discover up to limit peers registered in the namespace.  A limit of zero
+// requests the maximum, MaxDiscoverLimit.
+//
+// The returned cookie can be passed to a subsequent call in order to fetch the
+// next page.  When a page is empty, the cookie can be retained and used later
+// to fetch only new registrations.  A nil cookie starts from the beginning.
+//
+// Discovered addresses are added to the host's address book until their
+// registration expires, so they can be passed directly to Host.Connect.
+func (c *Client) Discover(ctx context.Context, rdv casm.Addresser, ns string, limit int, cookie []byte) ([]Registration, []byte, error) {
+	if limit < 0 || limit > MaxDiscoverLimit {
+		return nil, nil, errors.Errorf("limit must be between 0 and %d", MaxDiscoverLimit)
+	}
+
+	cur, err := decodeCookie(cookie)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := discoverRequest{Namespace: ns, Limit: uint8(limit), Cookie: cur}.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := c.cl.Call(ctx, rdv, methodDiscover, req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "discover")
+	}
+
+	var res discoverResponse
+	if err = res.UnmarshalBinary(b); err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range res.Registrations {
+		if r.Addr.ID() != c.h.ID() && r.TTL > 0 {
+			c.h.AddAddr(r.Addr, host.SourceDiscovery, r.TTL)
+		}
+	}
+
+	return res.Registrations, encodeCookie(res.Cookie), nil
+}
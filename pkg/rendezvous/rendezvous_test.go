@@ -0,0 +1,103 @@
+package rendezvous
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/host"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/rpc"
+	log "github.com/lthibault/log/pkg"
+	"github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRendezvous(t *testing.T) {
+	l := log.New(log.OptLevel(log.NullLevel))
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(l),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hs := make([]*host.Host, 4)
+	for i := range hs {
+		hs[i] = host.New(opt...)
+		a := net.NewAddr(net.New(), "", "inproc", fmt.Sprintf("/rendezvous/%d", i))
+		assert.NoError(t, hs[i].Start(c, a))
+	}
+
+	rdv := hs[0]
+	NewServer(rdv, OptLogger(l), OptMaxTTL(time.Minute))
+
+	cs := make([]*Client, len(hs))
+	for i := range cs {
+		cs[i] = NewClient(l, hs[i])
+		defer cs[i].Close()
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		ttl, err := cs[1].Register(c, rdv.Addr(), "workers", time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, ttl, "ttl should be capped")
+
+		_, err = cs[2].Register(c, rdv.Addr(), "workers", time.Second*30)
+		assert.NoError(t, err)
+	})
+
+	t.Run("InvalidNamespace", func(t *testing.T) {
+		_, err := cs[1].Register(c, rdv.Addr(), "", 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("Discover", func(t *testing.T) {
+		rs, cookie, err := cs[3].Discover(c, rdv.Addr(), "workers", 1, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, rs, 1) {
+			assert.Equal(t, hs[1].ID(), rs[0].Addr.ID())
+			assert.NotEmpty(t, hs[3].Addrs(hs[1]), "should populate address book")
+		}
+
+		rs, cookie, err = cs[3].Discover(c, rdv.Addr(), "workers", 1, cookie)
+		assert.NoError(t, err)
+		if assert.Len(t, rs, 1) {
+			assert.Equal(t, hs[2].ID(), rs[0].Addr.ID())
+		}
+
+		rs, _, err = cs[3].Discover(c, rdv.Addr(), "workers", 1, cookie)
+		assert.NoError(t, err)
+		assert.Empty(t, rs)
+
+		assert.NoError(t, hs[3].Connect(c, hs[1].ID()),
+			"discovered peer should be reachable by ID")
+	})
+
+	t.Run("Unregister", func(t *testing.T) {
+		assert.NoError(t, cs[1].Unregister(c, rdv.Addr(), "workers"))
+
+		rs, _, err := cs[3].Discover(c, rdv.Addr(), "workers", 0, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, rs, 1) {
+			assert.Equal(t, hs[2].ID(), rs[0].Addr.ID())
+		}
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		h := host.New(opt...)
+		assert.NoError(t, h.Start(c, net.NewAddr(net.New(), "", "inproc", "/rendezvous/full")))
+		NewServer(h, OptLogger(l), OptMaxRegistrations(1))
+
+		_, err := cs[1].Register(c, h.Addr(), "ns", 0)
+		assert.NoError(t, err)
+
+		_, err = cs[2].Register(c, h.Addr(), "ns", 0)
+		if assert.Error(t, err) {
+			assert.Equal(t, rpc.CodeUnavailable, errors.Cause(err).(*rpc.Error).Code)
+		}
+	})
+}
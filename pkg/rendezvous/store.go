@@ -0,0 +1,167 @@
+package rendezvous
+
+import (
+	"sync"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+// registration of a peer in a namespace.
+type registration struct {
+	addr net.Addr
+	seq  uint64
+	exp  time.Time
+}
+
+// sweepInterval is the minimum period between sweeps of expired
+// registrations from every namespace.
+const sweepInterval = time.Minute
+
+var (
+	errNamespaceFull     = errors.New("namespace is full")
+	errTooManyNamespaces = errors.New("too many namespaces")
+)
+
+// store holds registrations, indexed by namespace.  Within a namespace,
+// registrations are ordered by sequence number, which increases with every
+// registration (including renewals).  Discover uses the sequence number as a
+// pagination cursor.
+//
+// Expired registrations are dropped from a namespace whenever it is accessed,
+// and from all namespaces at most once per sweepInterval, when a registration
+// is added.
+type store struct {
+	lock  sync.Mutex
+	seq   uint64
+	max   int // registrations per namespace
+	maxNS int // namespaces per peer; zero means no limit
+	swept time.Time
+	ns    map[string][]registration
+	peers map[net.PeerID]int // number of namespaces in which each peer is registered
+}
+
+func newStore(max, maxNS int) *store {
+	return &store{
+		max:   max,
+		maxNS: maxNS,
+		ns:    make(map[string][]registration),
+		peers: make(map[net.PeerID]int),
+	}
+}
+
+// Add or renew a registration.  It fails if the namespace is full, or if the
+// peer is registered in too many namespaces.
+func (s *store) Add(ns string, a net.Addr, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) >= sweepInterval {
+		s.sweepUnsafe(now)
+	}
+
+	rs := s.gcUnsafe(ns, now)
+
+	// drop any existing registration for the peer; it is re-appended below
+	renew := false
+	for i, r := range rs {
+		if r.addr.ID() == a.ID() {
+			rs, renew = append(rs[:i], rs[i+1:]...), true
+			break
+		}
+	}
+
+	if !renew {
+		if len(rs) >= s.max {
+			return errNamespaceFull
+		}
+
+		if s.maxNS > 0 && s.peers[a.ID()] >= s.maxNS {
+			return errTooManyNamespaces
+		}
+
+		s.peers[a.ID()]++
+	}
+
+	s.seq++
+	s.ns[ns] = append(rs, registration{addr: a, seq: s.seq, exp: now.Add(ttl)})
+	return nil
+}
+
+// Remove the peer's registration from the namespace.
+func (s *store) Remove(ns string, id net.PeerID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rs := s.ns[ns]
+	for i, r := range rs {
+		if r.addr.ID() == id {
+			s.ns[ns] = append(rs[:i], rs[i+1:]...)
+			s.releaseUnsafe(id)
+			break
+		}
+	}
+
+	if len(s.ns[ns]) == 0 {
+		delete(s.ns, ns)
+	}
+}
+
+// List up to limit registrations with a sequence number greater than cursor.
+// It returns the cursor for the next page.
+func (s *store) List(ns string, cursor uint64, limit int) ([]registration, uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var out []registration
+	for _, r := range s.gcUnsafe(ns, time.Now()) {
+		if len(out) == limit {
+			break
+		}
+
+		if r.seq > cursor {
+			out = append(out, r)
+			cursor = r.seq
+		}
+	}
+
+	return out, cursor
+}
+
+// gcUnsafe drops expired registrations from the namespace, and returns those
+// that remain.
+func (s *store) gcUnsafe(ns string, now time.Time) []registration {
+	rs := s.ns[ns][:0]
+	for _, r := range s.ns[ns] {
+		if now.Before(r.exp) {
+			rs = append(rs, r)
+		} else {
+			s.releaseUnsafe(r.addr.ID())
+		}
+	}
+
+	if len(rs) == 0 {
+		delete(s.ns, ns)
+		return nil
+	}
+
+	s.ns[ns] = rs
+	return rs
+}
+
+// sweepUnsafe drops expired registrations from every namespace.
+func (s *store) sweepUnsafe(now time.Time) {
+	for ns := range s.ns {
+		s.gcUnsafe(ns, now)
+	}
+	s.swept = now
+}
+
+// releaseUnsafe records that the peer has left a namespace.
+func (s *store) releaseUnsafe(id net.PeerID) {
+	if s.peers[id]--; s.peers[id] <= 0 {
+		delete(s.peers, id)
+	}
+}
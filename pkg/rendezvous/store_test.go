@@ -0,0 +1,86 @@
+package rendezvous
+
+import (
+	"testing"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s := newStore(3, 2)
+	as := []net.Addr{
+		net.NewAddr(net.New(), "", "inproc", "/s0"),
+		net.NewAddr(net.New(), "", "inproc", "/s1"),
+		net.NewAddr(net.New(), "", "inproc", "/s2"),
+		net.NewAddr(net.New(), "", "inproc", "/s3"),
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		for _, a := range as[:3] {
+			assert.NoError(t, s.Add("ns", a, time.Hour))
+		}
+		assert.Equal(t, errNamespaceFull, s.Add("ns", as[3], time.Hour), "namespace should be full")
+		assert.NoError(t, s.Add("other", as[3], time.Hour))
+	})
+
+	t.Run("Paginate", func(t *testing.T) {
+		rs, cur := s.List("ns", 0, 2)
+		assert.Len(t, rs, 2)
+		assert.Equal(t, as[0], rs[0].addr)
+
+		rs, cur = s.List("ns", cur, 2)
+		assert.Len(t, rs, 1)
+		assert.Equal(t, as[2], rs[0].addr)
+
+		rs, next := s.List("ns", cur, 2)
+		assert.Empty(t, rs)
+		assert.Equal(t, cur, next, "empty page should not advance cursor")
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		_, cur := s.List("ns", 0, 3)
+
+		assert.NoError(t, s.Add("ns", as[0], time.Hour))
+		rs, _ := s.List("ns", cur, 3)
+		assert.Len(t, rs, 1, "renewal should appear after cursor")
+		assert.Equal(t, as[0], rs[0].addr)
+
+		rs, _ = s.List("ns", 0, 10)
+		assert.Len(t, rs, 3, "renewal should not duplicate registration")
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		s.Remove("ns", as[1].ID())
+		rs, _ := s.List("ns", 0, 10)
+		assert.Len(t, rs, 2)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		assert.NoError(t, s.Add("ephemeral", as[0], time.Millisecond))
+		time.Sleep(time.Millisecond * 5)
+
+		rs, _ := s.List("ephemeral", 0, 10)
+		assert.Empty(t, rs)
+		assert.NotContains(t, s.ns, "ephemeral")
+	})
+
+	t.Run("MaxNamespaces", func(t *testing.T) {
+		assert.NoError(t, s.Add("ns2", as[3], time.Hour))
+		assert.Equal(t, errTooManyNamespaces, s.Add("ns3", as[3], time.Hour))
+		assert.NoError(t, s.Add("ns2", as[3], time.Hour), "renewal refused")
+
+		s.Remove("ns2", as[3].ID())
+		assert.NoError(t, s.Add("ns3", as[3], time.Hour))
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		assert.NoError(t, s.Add("stale", as[2], time.Millisecond))
+		time.Sleep(time.Millisecond * 5)
+
+		s.swept = time.Time{} // force a sweep
+		assert.NoError(t, s.Add("ns3", as[2], time.Hour))
+		assert.NotContains(t, s.ns, "stale")
+	})
+}
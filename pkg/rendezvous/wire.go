@@ -0,0 +1,197 @@
+package rendezvous
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/pkg/errors"
+)
+
+const (
+	methodRegister   = "rendezvous.Register"
+	methodUnregister = "rendezvous.Unregister"
+	methodDiscover   = "rendezvous.Discover"
+
+	// MaxNamespaceLen is the maximum length of a namespace, in bytes.
+	MaxNamespaceLen = 255
+
+	// MaxDiscoverLimit is the maximum number of registrations returned by a
+	// single call to Discover.
+	MaxDiscoverLimit = 255
+)
+
+func writeNamespace(w *bytes.Buffer, ns string) error {
+	if len(ns) == 0 || len(ns) > MaxNamespaceLen {
+		return errors.Errorf("namespace must be between 1 and %d bytes", MaxNamespaceLen)
+	}
+
+	w.WriteByte(uint8(len(ns)))
+	w.WriteString(ns)
+	return nil
+}
+
+func readNamespace(r io.Reader) (string, error) {
+	var n [1]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return "", errors.Wrap(err, "read namespace")
+	}
+
+	b := make([]byte, n[0])
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", errors.Wrap(err, "read namespace")
+	}
+
+	return string(b), nil
+}
+
+// registerRequest asks the rendezvous host to record Addr in Namespace.
+type registerRequest struct {
+	Namespace string
+	TTL       time.Duration
+	Addr      net.Addr
+}
+
+func (req registerRequest) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeNamespace(&buf, req.Namespace); err != nil {
+		return nil, err
+	}
+
+	binary.Write(&buf, binary.BigEndian, uint32(req.TTL/time.Second))
+	err := net.SendAddr(&buf, req.Addr)
+	return buf.Bytes(), err
+}
+
+func (req *registerRequest) UnmarshalBinary(b []byte) (err error) {
+	r := bytes.NewReader(b)
+	if req.Namespace, err = readNamespace(r); err != nil {
+		return
+	}
+
+	var ttl uint32
+	if err = binary.Read(r, binary.BigEndian, &ttl); err != nil {
+		return errors.Wrap(err, "read ttl")
+	}
+	req.TTL = time.Duration(ttl) * time.Second
+
+	req.Addr, err = net.RecvAddr(r)
+	return errors.Wrap(err, "read addr")
+}
+
+// discoverRequest asks for up to Limit registrations following Cookie.
+type discoverRequest struct {
+	Namespace string
+	Limit     uint8
+	Cookie    uint64
+}
+
+func (req discoverRequest) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeNamespace(&buf, req.Namespace); err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte(req.Limit)
+	binary.Write(&buf, binary.BigEndian, req.Cookie)
+	return buf.Bytes(), nil
+}
+
+func (req *discoverRequest) UnmarshalBinary(b []byte) (err error) {
+	r := bytes.NewReader(b)
+	if req.Namespace, err = readNamespace(r); err != nil {
+		return
+	}
+
+	if req.Limit, err = r.ReadByte(); err != nil {
+		return errors.Wrap(err, "read limit")
+	}
+
+	return errors.Wrap(binary.Read(r, binary.BigEndian, &req.Cookie), "read cookie")
+}
+
+// Registration is a peer registered in a namespace.
+type Registration struct {
+	Addr net.Addr
+	TTL  time.Duration // remaining
+}
+
+type discoverResponse struct {
+	Cookie        uint64
+	Registrations []Registration
+}
+
+func (res discoverResponse) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, res.Cookie)
+	buf.WriteByte(uint8(len(res.Registrations)))
+
+	for _, reg := range res.Registrations {
+		binary.Write(&buf, binary.BigEndian, uint32(reg.TTL/time.Second))
+		if err := net.SendAddr(&buf, reg.Addr); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (res *discoverResponse) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if err := binary.Read(r, binary.BigEndian, &res.Cookie); err != nil {
+		return errors.Wrap(err, "read cookie")
+	}
+
+	n, err := r.ReadByte()
+	if err != nil {
+		return errors.Wrap(err, "read count")
+	}
+
+	res.Registrations = make([]Registration, n)
+	for i := range res.Registrations {
+		var ttl uint32
+		if err = binary.Read(r, binary.BigEndian, &ttl); err != nil {
+			return errors.Wrap(err, "read ttl")
+		}
+		res.Registrations[i].TTL = time.Duration(ttl) * time.Second
+
+		if res.Registrations[i].Addr, err = net.RecvAddr(r); err != nil {
+			return errors.Wrap(err, "read addr")
+		}
+	}
+
+	return nil
+}
+
+func encodeTTL(d time.Duration) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(d/time.Second))
+	return b
+}
+
+func decodeTTL(b []byte) (time.Duration, error) {
+	if len(b) != 4 {
+		return 0, errors.New("malformed ttl")
+	}
+
+	return time.Duration(binary.BigEndian.Uint32(b)) * time.Second, nil
+}
+
+func encodeCookie(cur uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, cur)
+	return b
+}
+
+func decodeCookie(b []byte) (uint64, error) {
+	switch len(b) {
+	case 0:
+		return 0, nil
+	case 8:
+		return binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, errors.New("malformed cookie")
+	}
+}
@@ -0,0 +1,326 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	host "github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/msgio"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+)
+
+// Opener opens streams to remote peers.  It is satisfied by host.Host.
+type Opener interface {
+	Open(context.Context, casm.Addresser, string) (host.Stream, error)
+}
+
+// ClientStream is the caller's end of a call.
+type ClientStream interface {
+	// Send a message to the server.  It returns io.EOF if the call has ended,
+	// in which case the call's status can be obtained from Recv.
+	Send([]byte) error
+	// CloseSend signals that the caller will send no more messages.
+	CloseSend() error
+	// Recv the next message from the server.  It returns io.EOF when the call
+	// has completed successfully, and an *Error if the call failed.
+	Recv() ([]byte, error)
+}
+
+// Client issues calls to remote peers.  Calls to the same peer share a single
+// stream, which is opened on demand.
+type Client struct {
+	log  log.Logger
+	o    Opener
+	path string
+
+	lock  sync.Mutex
+	conns map[net.PeerID]*clientConn
+}
+
+// NewClient returns a Client that opens streams on Path using o.
+func NewClient(l log.Logger, o Opener) *Client {
+	return NewClientForPath(l, o, Path)
+}
+
+// NewClientForPath returns a Client that opens streams on the specified path.
+// This allows protocols built on this package to register their own Server
+// on a dedicated path.
+func NewClientForPath(l log.Logger, o Opener, path string) *Client {
+	return &Client{
+		log:   l,
+		o:     o,
+		path:  path,
+		conns: make(map[net.PeerID]*clientConn),
+	}
+}
+
+// Call a unary method.
+func (cl *Client) Call(c context.Context, a casm.Addresser, method string, req []byte) ([]byte, error) {
+	s, err := cl.Stream(c, a, method)
+	if err != nil {
+		return nil, err
+	}
+
+	// io.EOF means the call already ended; its status is reported by Recv.
+	if err = s.Send(req); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if err = s.CloseSend(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	res, err := s.Recv()
+	if err == io.EOF {
+		return nil, errors.New("rpc: missing response")
+	} else if err != nil {
+		return nil, err
+	}
+
+	// wait for the call's status
+	if _, err = s.Recv(); err != io.EOF {
+		if err == nil {
+			err = errors.New("rpc: unexpected message")
+		}
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Stream starts a streaming call.  The call is aborted when c expires.
+func (cl *Client) Stream(c context.Context, a casm.Addresser, method string) (ClientStream, error) {
+	conn, err := cl.conn(c, a)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.Call(c, method)
+}
+
+// Close all streams.  Pending calls fail with CodeUnavailable.
+func (cl *Client) Close() error {
+	cl.lock.Lock()
+	conns := cl.conns
+	cl.conns = make(map[net.PeerID]*clientConn)
+	cl.lock.Unlock()
+
+	for _, conn := range conns {
+		conn.s.Close()
+	}
+
+	return nil
+}
+
+func (cl *Client) conn(c context.Context, a casm.Addresser) (*clientConn, error) {
+	id := a.Addr().ID()
+
+	cl.lock.Lock()
+	conn, ok := cl.conns[id]
+	cl.lock.Unlock()
+	if ok {
+		return conn, nil
+	}
+
+	s, err := cl.o.Open(c, a, cl.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open stream")
+	}
+
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	// another caller may have opened a stream concurrently
+	if conn, ok = cl.conns[id]; ok {
+		s.Close()
+		return conn, nil
+	}
+
+	conn = &clientConn{
+		s:     s,
+		rw:    msgio.NewReadWriter(s, 0),
+		calls: make(map[uint64]*clientStream),
+	}
+	cl.conns[id] = conn
+
+	go func() {
+		err := conn.readLoop()
+		cl.log.WithError(err).WithField("remote", s.RemoteAddr()).Debug("rpc stream closed")
+
+		cl.lock.Lock()
+		if cl.conns[id] == conn {
+			delete(cl.conns, id)
+		}
+		cl.lock.Unlock()
+	}()
+
+	return conn, nil
+}
+
+// clientConn multiplexes calls over a single stream.
+type clientConn struct {
+	s  host.Stream
+	rw msgio.ReadWriter
+
+	lock  sync.Mutex
+	next  uint64
+	calls map[uint64]*clientStream
+	err   error
+}
+
+func (conn *clientConn) Call(c context.Context, method string) (*clientStream, error) {
+	conn.lock.Lock()
+	if conn.err != nil {
+		conn.lock.Unlock()
+		return nil, conn.err
+	}
+
+	conn.next++
+	cs := &clientStream{
+		conn: conn,
+		id:   conn.next,
+		c:    c,
+		in:   newInbox(DefaultMaxPending),
+		done: make(chan struct{}),
+	}
+	conn.calls[cs.id] = cs
+	conn.lock.Unlock()
+
+	f := frame{Type: frameCall, ID: cs.id, Body: []byte(method)}
+	f.Span, _ = trace.Extract(c)
+	if dl, ok := c.Deadline(); ok {
+		// A negative timeout signals a deadline that has already passed,
+		// since zero means none.
+		if f.Timeout = time.Until(dl); f.Timeout == 0 {
+			f.Timeout = -1
+		}
+	}
+
+	if err := conn.send(f); err != nil {
+		cs.finish(err)
+		return nil, err
+	}
+
+	go cs.watch()
+	return cs, nil
+}
+
+func (conn *clientConn) send(f frame) error {
+	b, _ := f.MarshalBinary()
+	if err := conn.rw.WriteMsg(b); err != nil {
+		return &Error{Code: CodeUnavailable, Msg: err.Error()}
+	}
+	return nil
+}
+
+func (conn *clientConn) readLoop() (err error) {
+	defer func() {
+		conn.s.Close()
+
+		conn.lock.Lock()
+		conn.err = &Error{Code: CodeUnavailable, Msg: "stream closed"}
+		calls := conn.calls
+		conn.calls = nil
+		conn.lock.Unlock()
+
+		for _, cs := range calls {
+			cs.finish(conn.err)
+		}
+	}()
+
+	for {
+		b, err := conn.rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+
+		var f frame
+		err = f.UnmarshalBinary(b)
+		conn.rw.ReleaseMsg(b)
+		if err != nil {
+			return errors.Wrap(err, "read frame")
+		}
+
+		conn.lock.Lock()
+		cs, ok := conn.calls[f.ID]
+		conn.lock.Unlock()
+		if !ok {
+			continue // call was cancelled; drop late frames
+		}
+
+		switch f.Type {
+		case frameData:
+			if !cs.in.Push(f.Body) {
+				cs.conn.send(frame{Type: frameCancel, ID: cs.id})
+				cs.finish(&Error{Code: CodeResourceExhausted, Msg: "too many pending messages"})
+			}
+		case frameEnd:
+			if f.Code == CodeOK {
+				cs.finish(io.EOF)
+			} else {
+				cs.finish(&Error{Code: f.Code, Msg: string(f.Body)})
+			}
+		}
+	}
+}
+
+type clientStream struct {
+	conn *clientConn
+	id   uint64
+	c    context.Context
+	in   *inbox
+
+	once sync.Once
+	done chan struct{}
+}
+
+// watch for the expiration of the call's context, and notify the server.
+func (cs *clientStream) watch() {
+	select {
+	case <-cs.done:
+	case <-cs.c.Done():
+		cs.conn.send(frame{Type: frameCancel, ID: cs.id})
+		cs.finish(toError(cs.c.Err()))
+	}
+}
+
+// finish the call.  Messages already received remain available to Recv.
+func (cs *clientStream) finish(err error) {
+	cs.once.Do(func() {
+		cs.conn.lock.Lock()
+		delete(cs.conn.calls, cs.id)
+		cs.conn.lock.Unlock()
+
+		cs.in.Close(err)
+		close(cs.done)
+	})
+}
+
+func (cs *clientStream) Send(b []byte) error {
+	select {
+	case <-cs.done:
+		return io.EOF
+	default:
+		return cs.conn.send(frame{Type: frameData, ID: cs.id, Body: b})
+	}
+}
+
+func (cs *clientStream) CloseSend() error {
+	select {
+	case <-cs.done:
+		return nil
+	default:
+		return cs.conn.send(frame{Type: frameCloseSend, ID: cs.id})
+	}
+}
+
+func (cs *clientStream) Recv() ([]byte, error) {
+	// The context is not consulted here; watch() closes the inbox on expiry.
+	return cs.in.Pop(context.Background())
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/lthibault/casm/pkg/trace"
+	"github.com/pkg/errors"
+)
+
+type frameType uint8
+
+const (
+	// frameCall opens a call.  It carries the method, timeout and span.
+	frameCall frameType = iota
+	// frameData carries one message in either direction.
+	frameData
+	// frameCloseSend indicates the caller will send no more messages.
+	frameCloseSend
+	// frameCancel aborts a call.  It is sent by the caller.
+	frameCancel
+	// frameEnd terminates a call.  It is sent by the callee, and carries the
+	// call's status.
+	frameEnd
+)
+
+// frame is the unit of transmission.  Each frame is sent as a single msgio
+// message, with the following layout:
+//
+//	type     uint8
+//	id       uvarint
+//	timeout  varint, nanoseconds remaining (call only; zero means none)
+//	span     trace.Size bytes (call only; zeros means none)
+//	code     uint8 (end only)
+//	body     remaining bytes: method (call), payload (data), message (end)
+type frame struct {
+	Type    frameType
+	ID      uint64
+	Timeout time.Duration // relative, so that clocks need not agree
+	Span    trace.SpanContext
+	Code    Code
+	Body    []byte
+}
+
+func (f frame) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 1+2*binary.MaxVarintLen64+trace.Size+1+len(f.Body))
+	b[0] = byte(f.Type)
+	n := 1 + binary.PutUvarint(b[1:], f.ID)
+
+	switch f.Type {
+	case frameCall:
+		n += binary.PutVarint(b[n:], int64(f.Timeout))
+
+		span, _ := f.Span.MarshalBinary()
+		n += copy(b[n:], span)
+	case frameEnd:
+		b[n] = byte(f.Code)
+		n++
+	}
+
+	n += copy(b[n:], f.Body)
+	return b[:n], nil
+}
+
+// UnmarshalBinary decodes a frame.  The body is copied, so b may be reused.
+func (f *frame) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("empty frame")
+	}
+
+	f.Type = frameType(b[0])
+	b = b[1:]
+
+	id, n := binary.Uvarint(b)
+	if n <= 0 {
+		return errors.New("invalid call id")
+	}
+	f.ID, b = id, b[n:]
+
+	switch f.Type {
+	case frameCall:
+		timeout, n := binary.Varint(b)
+		if n <= 0 {
+			return errors.New("invalid timeout")
+		}
+		f.Timeout, b = time.Duration(timeout), b[n:]
+
+		if len(b) < trace.Size {
+			return errors.New("missing span")
+		}
+		if err := f.Span.UnmarshalBinary(b[:trace.Size]); err != nil {
+			return err
+		}
+		b = b[trace.Size:]
+	case frameEnd:
+		if len(b) == 0 {
+			return errors.New("missing status code")
+		}
+		f.Code, b = Code(b[0]), b[1:]
+	case frameData, frameCloseSend, frameCancel:
+	default:
+		return errors.Errorf("invalid frame type %d", f.Type)
+	}
+
+	f.Body = append([]byte(nil), b...)
+	return nil
+}
@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lthibault/casm/pkg/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrame(t *testing.T) {
+	_, sc := trace.Start(context.Background())
+
+	for _, f := range []frame{
+		{Type: frameCall, ID: 1, Timeout: time.Second, Body: []byte("echo")},
+		{Type: frameCall, ID: 1, Timeout: -1, Body: []byte("echo")},
+		{Type: frameCall, ID: 2, Body: []byte("echo")},
+		{Type: frameCall, ID: 3, Span: sc, Body: []byte("echo")},
+		{Type: frameData, ID: 300, Body: []byte("payload")},
+		{Type: frameData, ID: 300},
+		{Type: frameCloseSend, ID: 4},
+		{Type: frameCancel, ID: 5},
+		{Type: frameEnd, ID: 6, Code: CodeNotFound, Body: []byte("echo")},
+	} {
+		b, err := f.MarshalBinary()
+		assert.NoError(t, err)
+
+		var got frame
+		assert.NoError(t, got.UnmarshalBinary(b))
+		assert.Equal(t, f, got)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		var f frame
+		assert.Error(t, f.UnmarshalBinary(nil))
+		assert.Error(t, f.UnmarshalBinary([]byte{byte(frameEnd), 1}))
+		assert.Error(t, f.UnmarshalBinary([]byte{0xff, 1}))
+	})
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+)
+
+// inbox is a queue of incoming messages for a single call.  Push does not block,
+// so that a slow consumer cannot stall the other calls sharing its stream;
+// instead, the queue is bounded, and the call is aborted when it overflows.
+type inbox struct {
+	lock  sync.Mutex
+	max   int // zero or less means unbounded
+	msgs  [][]byte
+	err   error
+	ready chan struct{}
+}
+
+func newInbox(max int) *inbox {
+	return &inbox{max: max, ready: make(chan struct{}, 1)}
+}
+
+// Push a message onto the queue.  It returns false if the queue is full, in
+// which case the message is dropped.
+func (q *inbox) Push(b []byte) bool {
+	q.lock.Lock()
+	if q.max > 0 && len(q.msgs) >= q.max {
+		q.lock.Unlock()
+		return false
+	}
+
+	if q.err == nil {
+		q.msgs = append(q.msgs, b)
+	}
+	q.lock.Unlock()
+	q.signal()
+	return true
+}
+
+// Close the inbox.  Subsequent calls to Pop return err once the queue has been
+// drained.  Only the first call to Close has any effect.
+func (q *inbox) Close(err error) {
+	q.lock.Lock()
+	if q.err == nil {
+		q.err = err
+	}
+	q.lock.Unlock()
+	q.signal()
+}
+
+func (q *inbox) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *inbox) Pop(c context.Context) ([]byte, error) {
+	for {
+		q.lock.Lock()
+		if len(q.msgs) > 0 {
+			b := q.msgs[0]
+			q.msgs[0] = nil
+			q.msgs = q.msgs[1:]
+			q.lock.Unlock()
+			return b, nil
+		}
+		err := q.err
+		q.lock.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-q.ready:
+		case <-c.Done():
+			return nil, c.Err()
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package rpc
+
+// Option for Server.
+type Option func(*Server) (prev Option)
+
+func setDefaultOpts(opt []Option) []Option {
+	return append(
+		[]Option{
+			OptMaxCalls(128),
+			OptMaxPending(DefaultMaxPending),
+		},
+		opt...,
+	)
+}
+
+// OptMaxCalls limits the number of calls that a remote peer may have in
+// progress concurrently.  Further calls fail with CodeResourceExhausted.  A
+// value of zero or less removes the limit.
+func OptMaxCalls(n int) Option {
+	return func(srv *Server) (prev Option) {
+		prev = OptMaxCalls(srv.maxCalls)
+		srv.maxCalls = n
+		return
+	}
+}
+
+// OptMaxPending limits the number of messages that may be queued for a call
+// before the handler receives them.  A call whose queue overflows fails with
+// CodeResourceExhausted.  A value of zero or less removes the limit.
+func OptMaxPending(n int) Option {
+	return func(srv *Server) (prev Option) {
+		prev = OptMaxPending(srv.maxPending)
+		srv.maxPending = n
+		return
+	}
+}
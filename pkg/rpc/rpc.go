@@ -0,0 +1,104 @@
+// Package rpc implements request/response calls over Host streams.
+//
+// All calls between two peers are multiplexed over a single stream, opened on
+// Path.  Each call carries a request ID and, optionally, the deadline of the
+// caller's context.  Unary, client-streaming, server-streaming and
+// bidirectional calls are all expressed as a Stream; Client.Call is a
+// convenience for the unary case.
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Path on which the RPC server is registered.
+const Path = "/casm/rpc/1.0.0"
+
+// Code classifies an Error.
+type Code uint8
+
+const (
+	// CodeOK indicates success.
+	CodeOK Code = iota
+	// CodeUnknown is used for errors that carry no other code.
+	CodeUnknown
+	// CodeNotFound indicates that the remote peer has no such method.
+	CodeNotFound
+	// CodeCanceled indicates that the call was cancelled by the caller.
+	CodeCanceled
+	// CodeDeadlineExceeded indicates that the call's deadline expired.
+	CodeDeadlineExceeded
+	// CodeUnavailable indicates that the underlying stream failed.
+	CodeUnavailable
+	// CodeInternal indicates that the handler panicked.
+	CodeInternal
+	// CodeResourceExhausted indicates that the peer has too many calls in
+	// progress, or that too many messages are queued for the call.
+	CodeResourceExhausted
+)
+
+// DefaultMaxPending is the default number of messages that may be queued for a
+// call before the receiver consumes them.
+const DefaultMaxPending = 256
+
+func (c Code) String() string {
+	switch c {
+	case CodeOK:
+		return "ok"
+	case CodeUnknown:
+		return "unknown"
+	case CodeNotFound:
+		return "not found"
+	case CodeCanceled:
+		return "canceled"
+	case CodeDeadlineExceeded:
+		return "deadline exceeded"
+	case CodeUnavailable:
+		return "unavailable"
+	case CodeInternal:
+		return "internal"
+	case CodeResourceExhausted:
+		return "resource exhausted"
+	default:
+		return fmt.Sprintf("code(%d)", uint8(c))
+	}
+}
+
+// Error is transported between peers when a call fails.
+type Error struct {
+	Code Code
+	Msg  string
+}
+
+// Errorf returns an Error with the specified code.  Handlers may return it to
+// control the code observed by the caller.
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &Error{Code: code, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("rpc: %s", e.Code)
+	}
+	return fmt.Sprintf("rpc: %s: %s", e.Code, e.Msg)
+}
+
+// toError converts err into an *Error suitable for transport.
+func toError(err error) *Error {
+	switch cause := errors.Cause(err); cause {
+	case nil:
+		return nil
+	case context.Canceled:
+		return &Error{Code: CodeCanceled}
+	case context.DeadlineExceeded:
+		return &Error{Code: CodeDeadlineExceeded}
+	default:
+		if e, ok := cause.(*Error); ok {
+			return e
+		}
+		return &Error{Code: CodeUnknown, Msg: err.Error()}
+	}
+}
@@ -0,0 +1,275 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	casm "github.com/lthibault/casm/pkg"
+	host "github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/msgio"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
+	log "github.com/lthibault/log/pkg"
+	inproc "github.com/lthibault/pipewerks/pkg/transport/inproc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingOpener struct {
+	Opener
+	n int
+}
+
+func (o *countingOpener) Open(c context.Context, a casm.Addresser, path string) (host.Stream, error) {
+	o.n++
+	return o.Opener.Open(c, a, path)
+}
+
+func TestRPC(t *testing.T) {
+	l := log.New(log.OptLevel(log.NullLevel))
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(l),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := host.New(opt...), host.New(opt...)
+	a1 := net.NewAddr(net.New(), "", "inproc", "/rpc/h1")
+	assert.NoError(t, h0.Start(c, net.NewAddr(net.New(), "", "inproc", "/rpc/h0")))
+	assert.NoError(t, h1.Start(c, a1))
+
+	srv := NewServer(l)
+	h1.Register(Path, srv)
+
+	srv.RegisterUnary("echo", func(_ context.Context, b []byte) ([]byte, error) {
+		return b, nil
+	})
+	srv.RegisterUnary("fail", func(context.Context, []byte) ([]byte, error) {
+		return nil, Errorf(CodeUnknown, "boom")
+	})
+	srv.RegisterUnary("block", func(c context.Context, _ []byte) ([]byte, error) {
+		<-c.Done()
+		return nil, c.Err()
+	})
+	srv.Register("sum", func(s ServerStream) error { // client streaming
+		var n int
+		for {
+			b, err := s.Recv()
+			if err == io.EOF {
+				return s.Send([]byte{byte(n)})
+			} else if err != nil {
+				return err
+			}
+			n += int(b[0])
+		}
+	})
+	srv.Register("count", func(s ServerStream) error { // server streaming
+		for i := 0; i < 3; i++ {
+			if err := s.Send([]byte{byte(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	srv.Register("whoami", func(s ServerStream) error {
+		return s.Send([]byte(s.RemoteAddr().String()))
+	})
+	srv.Register("deadline", func(s ServerStream) error {
+		_, ok := s.Context().Deadline()
+		return s.Send([]byte{map[bool]byte{true: 1}[ok]})
+	})
+	srv.RegisterUnary("panic", func(context.Context, []byte) ([]byte, error) {
+		panic("boom")
+	})
+	srv.Register("span", func(s ServerStream) error {
+		sc, _ := trace.Extract(s.Context())
+		b, _ := sc.MarshalBinary()
+		return s.Send(b)
+	})
+
+	o := &countingOpener{Opener: h0}
+	cl := NewClient(l, o)
+	defer cl.Close()
+
+	t.Run("Unary", func(t *testing.T) {
+		res, err := cl.Call(c, a1, "echo", []byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(res))
+	})
+
+	t.Run("RemoteAddr", func(t *testing.T) {
+		res, err := cl.Call(c, a1, "whoami", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "/rpc/h0", string(res))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := cl.Call(c, a1, "fail", nil)
+		assert.Equal(t, &Error{Code: CodeUnknown, Msg: "boom"}, err)
+
+		_, err = cl.Call(c, a1, "missing", nil)
+		assert.Equal(t, CodeNotFound, err.(*Error).Code)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		res, err := cl.Call(c, a1, "deadline", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0}, res)
+
+		cx, cancel := context.WithTimeout(c, time.Minute)
+		defer cancel()
+
+		res, err = cl.Call(cx, a1, "deadline", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{1}, res)
+
+		cx, cancel = context.WithTimeout(c, time.Millisecond*10)
+		defer cancel()
+
+		_, err = cl.Call(cx, a1, "block", nil)
+		assert.Equal(t, CodeDeadlineExceeded, errors.Cause(err).(*Error).Code)
+	})
+
+	t.Run("Trace", func(t *testing.T) {
+		cx, sc := trace.Start(c)
+
+		res, err := cl.Call(cx, a1, "span", nil)
+		assert.NoError(t, err)
+
+		var got trace.SpanContext
+		assert.NoError(t, got.UnmarshalBinary(res))
+		assert.Equal(t, sc, got)
+	})
+
+	t.Run("ClientStreaming", func(t *testing.T) {
+		s, err := cl.Stream(c, a1, "sum")
+		assert.NoError(t, err)
+
+		for i := 1; i <= 3; i++ {
+			assert.NoError(t, s.Send([]byte{byte(i)}))
+		}
+		assert.NoError(t, s.CloseSend())
+
+		b, err := s.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{6}, b)
+
+		_, err = s.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("ServerStreaming", func(t *testing.T) {
+		s, err := cl.Stream(c, a1, "count")
+		assert.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			b, err := s.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, []byte{byte(i)}, b)
+		}
+
+		_, err = s.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		_, err := cl.Call(c, a1, "panic", nil)
+		assert.Equal(t, CodeInternal, errors.Cause(err).(*Error).Code)
+
+		// the session survives the panic
+		res, err := cl.Call(c, a1, "echo", []byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(res))
+	})
+
+	t.Run("Multiplexed", func(t *testing.T) {
+		assert.Equal(t, 1, o.n, "calls did not share a stream")
+	})
+}
+
+func TestLimits(t *testing.T) {
+	l := log.New(log.OptLevel(log.NullLevel))
+	opt := []host.Option{
+		host.OptTransport(net.NewTransport(inproc.New())),
+		host.OptLogger(l),
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h0, h1 := host.New(opt...), host.New(opt...)
+	a1 := net.NewAddr(net.New(), "", "inproc", "/rpc/limits/h1")
+	assert.NoError(t, h0.Start(c, net.NewAddr(net.New(), "", "inproc", "/rpc/limits/h0")))
+	assert.NoError(t, h1.Start(c, a1))
+
+	srv := NewServer(l, OptMaxCalls(1), OptMaxPending(1))
+	h1.Register(Path, srv)
+
+	srv.RegisterUnary("echo", func(_ context.Context, b []byte) ([]byte, error) {
+		return b, nil
+	})
+
+	started := make(chan struct{}, 1)
+	srv.Register("stall", func(s ServerStream) error {
+		started <- struct{}{}
+		<-s.Context().Done()
+		return s.Context().Err()
+	})
+
+	cl := NewClient(l, h0)
+	defer cl.Close()
+
+	t.Run("MaxCalls", func(t *testing.T) {
+		cx, cancel := context.WithCancel(c)
+		defer cancel()
+
+		_, err := cl.Stream(cx, a1, "stall")
+		assert.NoError(t, err)
+		<-started
+
+		_, err = cl.Call(c, a1, "stall", nil)
+		assert.Equal(t, CodeResourceExhausted, errors.Cause(err).(*Error).Code)
+	})
+
+	t.Run("MaxPending", func(t *testing.T) {
+		// wait for the previous call to be released
+		assert.Eventually(t, func() bool {
+			_, err := cl.Call(c, a1, "echo", nil)
+			return err == nil
+		}, time.Second, time.Millisecond)
+
+		s, err := cl.Stream(c, a1, "stall")
+		assert.NoError(t, err)
+		<-started
+
+		for i := 0; i < 3; i++ {
+			s.Send([]byte{byte(i)})
+		}
+
+		_, err = s.Recv()
+		assert.Equal(t, CodeResourceExhausted, errors.Cause(err).(*Error).Code)
+	})
+
+	t.Run("DuplicateID", func(t *testing.T) {
+		s, err := h0.Open(c, a1, Path)
+		assert.NoError(t, err)
+		defer s.Close()
+
+		rw := msgio.NewReadWriter(s, 0)
+		call, _ := frame{Type: frameCall, ID: 1, Body: []byte("stall")}.MarshalBinary()
+		assert.NoError(t, rw.WriteMsg(call))
+		<-started
+		assert.NoError(t, rw.WriteMsg(call))
+
+		// the server terminates the session
+		for {
+			if _, err = rw.ReadMsg(); err != nil {
+				break
+			}
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+
+	host "github.com/lthibault/casm/pkg/host"
+	"github.com/lthibault/casm/pkg/msgio"
+	net "github.com/lthibault/casm/pkg/net"
+	"github.com/lthibault/casm/pkg/trace"
+	log "github.com/lthibault/log/pkg"
+	"github.com/pkg/errors"
+)
+
+// ServerStream is the server's end of a call.
+type ServerStream interface {
+	// Context expires when the call is cancelled by the caller, when its
+	// deadline passes, or when the underlying stream is closed.
+	Context() context.Context
+	// Method being called.
+	Method() string
+	// RemoteAddr of the caller.
+	RemoteAddr() net.Addr
+	// Recv the next message from the caller.  It returns io.EOF when the
+	// caller has finished sending.
+	Recv() ([]byte, error)
+	// Send a message to the caller.
+	Send([]byte) error
+}
+
+// StreamHandler serves a call.  The call ends when the handler returns; its
+// error, if any, is transported to the caller.
+type StreamHandler func(ServerStream) error
+
+// UnaryHandler serves a call that has exactly one request and one response.
+type UnaryHandler func(context.Context, []byte) ([]byte, error)
+
+// Unary adapts a UnaryHandler to a StreamHandler.
+func Unary(h UnaryHandler) StreamHandler {
+	return func(s ServerStream) error {
+		req, err := s.Recv()
+		if err != nil {
+			return errors.Wrap(err, "recv request")
+		}
+
+		res, err := h(s.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return s.Send(res)
+	}
+}
+
+// Server dispatches incoming calls to registered methods.  It satisfies
+// host.Handler, and should be registered on Path:
+//
+//	h.Register(rpc.Path, srv)
+//
+// Handlers that panic fail the call with CodeInternal.
+type Server struct {
+	log        log.Logger
+	maxCalls   int
+	maxPending int
+
+	lock sync.RWMutex
+	m    map[string]StreamHandler
+}
+
+// NewServer returns a Server with no registered methods.
+func NewServer(l log.Logger, opt ...Option) *Server {
+	srv := &Server{log: l, m: make(map[string]StreamHandler)}
+	for _, option := range setDefaultOpts(opt) {
+		option(srv)
+	}
+	return srv
+}
+
+// Register a handler for the method, replacing any existing handler.
+func (srv *Server) Register(method string, h StreamHandler) {
+	srv.lock.Lock()
+	srv.m[method] = h
+	srv.lock.Unlock()
+}
+
+// RegisterUnary is a convenience for Register(method, Unary(h)).
+func (srv *Server) RegisterUnary(method string, h UnaryHandler) {
+	srv.Register(method, Unary(h))
+}
+
+// Unregister the method.
+func (srv *Server) Unregister(method string) {
+	srv.lock.Lock()
+	delete(srv.m, method)
+	srv.lock.Unlock()
+}
+
+func (srv *Server) lookup(method string) (h StreamHandler, ok bool) {
+	srv.lock.RLock()
+	h, ok = srv.m[method]
+	srv.lock.RUnlock()
+	return
+}
+
+// Serve the calls multiplexed over s, until s is closed.
+func (srv *Server) Serve(s host.Stream) {
+	defer s.Close()
+
+	sess := &serverSession{
+		srv:    srv,
+		remote: s.RemoteAddr(),
+		rw:     msgio.NewReadWriter(s, 0),
+		calls:  make(map[uint64]*serverStream),
+	}
+
+	var cancel context.CancelFunc
+	sess.c, cancel = context.WithCancel(s.Context())
+	defer cancel()
+
+	if err := sess.readLoop(); err != nil && errors.Cause(err) != io.EOF {
+		srv.log.WithError(err).
+			WithField("remote", s.RemoteAddr()).
+			Debug("rpc session terminated")
+	}
+}
+
+type serverSession struct {
+	c      context.Context
+	srv    *Server
+	remote net.Addr
+	rw     msgio.ReadWriter
+
+	lock  sync.Mutex
+	calls map[uint64]*serverStream
+}
+
+func (sess *serverSession) readLoop() error {
+	for {
+		b, err := sess.rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+
+		var f frame
+		err = f.UnmarshalBinary(b)
+		sess.rw.ReleaseMsg(b)
+		if err != nil {
+			return errors.Wrap(err, "read frame")
+		}
+
+		if err = sess.dispatch(f); err != nil {
+			return err
+		}
+	}
+}
+
+func (sess *serverSession) dispatch(f frame) error {
+	if f.Type == frameCall {
+		return sess.call(f)
+	}
+
+	sess.lock.Lock()
+	ss, ok := sess.calls[f.ID]
+	sess.lock.Unlock()
+	if !ok {
+		return nil // call already ended; drop late frames
+	}
+
+	switch f.Type {
+	case frameData:
+		if !ss.in.Push(f.Body) {
+			ss.abort(&Error{Code: CodeResourceExhausted, Msg: "too many pending messages"})
+		}
+	case frameCloseSend:
+		ss.in.Close(io.EOF)
+	case frameCancel:
+		ss.cancel()
+	}
+
+	return nil
+}
+
+// call starts serving f.  A call ID that is already in use is a protocol
+// error, and terminates the session.
+func (sess *serverSession) call(f frame) error {
+	sess.lock.Lock()
+	_, dup := sess.calls[f.ID]
+	sess.lock.Unlock()
+	if dup {
+		return errors.Errorf("call id %d already in use", f.ID)
+	}
+
+	method := string(f.Body)
+	h, ok := sess.srv.lookup(method)
+	if !ok {
+		sess.send(frame{
+			Type: frameEnd,
+			ID:   f.ID,
+			Code: CodeNotFound,
+			Body: []byte(method),
+		})
+		return nil
+	}
+
+	sess.lock.Lock()
+	if max := sess.srv.maxCalls; max > 0 && len(sess.calls) >= max {
+		sess.lock.Unlock()
+		sess.send(frame{
+			Type: frameEnd,
+			ID:   f.ID,
+			Code: CodeResourceExhausted,
+			Body: []byte("too many calls"),
+		})
+		return nil
+	}
+	sess.lock.Unlock()
+
+	ss := &serverStream{
+		sess:   sess,
+		id:     f.ID,
+		method: method,
+		in:     newInbox(sess.srv.maxPending),
+	}
+	c := trace.Inject(sess.c, f.Span)
+	if f.Timeout == 0 {
+		ss.c, ss.cancel = context.WithCancel(c)
+	} else {
+		// the deadline is relative to our clock, not the caller's
+		ss.c, ss.cancel = context.WithTimeout(c, f.Timeout)
+	}
+
+	sess.lock.Lock()
+	sess.calls[f.ID] = ss
+	sess.lock.Unlock()
+
+	go ss.serve(h)
+	return nil
+}
+
+func (sess *serverSession) send(f frame) error {
+	b, _ := f.MarshalBinary()
+	return sess.rw.WriteMsg(b)
+}
+
+type serverStream struct {
+	sess   *serverSession
+	c      context.Context
+	cancel context.CancelFunc
+	id     uint64
+	method string
+	in     *inbox
+
+	once sync.Once
+	err  *Error // set if the call was aborted by the server
+}
+
+func (ss *serverStream) serve(h StreamHandler) {
+	defer ss.cancel()
+
+	e := toError(ss.run(h))
+	ss.once.Do(func() {}) // no further aborts
+	if ss.err != nil {
+		e = ss.err
+	}
+
+	end := frame{Type: frameEnd, ID: ss.id}
+	if e != nil {
+		end.Code, end.Body = e.Code, []byte(e.Msg)
+	}
+
+	ss.sess.lock.Lock()
+	delete(ss.sess.calls, ss.id)
+	ss.sess.lock.Unlock()
+
+	ss.sess.send(end)
+}
+
+// run the handler, recovering from panics.
+func (ss *serverStream) run(h StreamHandler) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			ss.sess.srv.log.WithFields(log.F{
+				"method": ss.method,
+				"remote": ss.sess.remote,
+				"panic":  fmt.Sprint(v),
+				"stack":  string(debug.Stack()),
+			}).Error("rpc handler panicked")
+			err = &Error{Code: CodeInternal, Msg: "handler panicked"}
+		}
+	}()
+
+	return h(ss)
+}
+
+// abort the call with the specified error, which is reported to the caller
+// in place of the handler's.
+func (ss *serverStream) abort(e *Error) {
+	ss.once.Do(func() {
+		ss.err = e
+		ss.in.Close(e)
+		ss.cancel()
+	})
+}
+
+func (ss *serverStream) Context() context.Context { return ss.c }
+func (ss *serverStream) Method() string           { return ss.method }
+func (ss *serverStream) RemoteAddr() net.Addr     { return ss.sess.remote }
+
+func (ss *serverStream) Recv() ([]byte, error) { return ss.in.Pop(ss.c) }
+
+func (ss *serverStream) Send(b []byte) error {
+	if err := ss.c.Err(); err != nil {
+		return err
+	}
+
+	return ss.sess.send(frame{Type: frameData, ID: ss.id, Body: b})
+}
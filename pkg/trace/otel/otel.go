@@ -0,0 +1,62 @@
+// Package otel adapts casm's trace propagation to OpenTelemetry.
+//
+// Install the adapter once, at startup:
+//
+//	trace.SetPropagator(otel.Propagator{})
+//
+// Spans started with an OpenTelemetry tracer are then propagated across
+// Host.Open, and handlers' stream contexts carry the caller's span as a
+// remote parent.
+package otel
+
+import (
+	"context"
+
+	"github.com/lthibault/casm/pkg/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Propagator satisfies trace.Propagator using OpenTelemetry span contexts.
+type Propagator struct{}
+
+// Extract satisfies trace.Propagator.
+func (Propagator) Extract(c context.Context) (trace.SpanContext, bool) {
+	return FromOTel(oteltrace.SpanContextFromContext(c))
+}
+
+// Inject satisfies trace.Propagator.
+func (Propagator) Inject(c context.Context, sc trace.SpanContext) context.Context {
+	return oteltrace.ContextWithRemoteSpanContext(c, ToOTel(sc))
+}
+
+// FromOTel converts an OpenTelemetry SpanContext.
+func FromOTel(sc oteltrace.SpanContext) (trace.SpanContext, bool) {
+	if !sc.IsValid() {
+		return trace.SpanContext{}, false
+	}
+
+	out := trace.SpanContext{
+		TraceID: trace.TraceID(sc.TraceID()),
+		SpanID:  trace.SpanID(sc.SpanID()),
+	}
+	if sc.IsSampled() {
+		out.Flags |= trace.FlagSampled
+	}
+
+	return out, true
+}
+
+// ToOTel converts a SpanContext received from a remote host.
+func ToOTel(sc trace.SpanContext) oteltrace.SpanContext {
+	var flags oteltrace.TraceFlags
+	if sc.Sampled() {
+		flags = oteltrace.FlagsSampled
+	}
+
+	return oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID(sc.TraceID),
+		SpanID:     oteltrace.SpanID(sc.SpanID),
+		TraceFlags: flags,
+		Remote:     true,
+	})
+}
@@ -0,0 +1,69 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lthibault/casm/pkg/trace"
+	"github.com/stretchr/testify/assert"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestRoundTrip(t *testing.T) {
+	_, sc := trace.Start(context.Background())
+
+	for name, flags := range map[string]trace.Flags{
+		"Sampled":   trace.FlagSampled,
+		"Unsampled": 0,
+	} {
+		t.Run(name, func(t *testing.T) {
+			sc := sc
+			sc.Flags = flags
+
+			o := ToOTel(sc)
+			assert.True(t, o.IsValid())
+			assert.True(t, o.IsRemote(), "span received from a remote host")
+			assert.Equal(t, flags == trace.FlagSampled, o.IsSampled())
+			assert.Equal(t, sc.TraceID, trace.TraceID(o.TraceID()))
+			assert.Equal(t, sc.SpanID, trace.SpanID(o.SpanID()))
+
+			got, ok := FromOTel(o)
+			assert.True(t, ok)
+			assert.Equal(t, sc, got)
+		})
+	}
+
+	t.Run("Local", func(t *testing.T) {
+		o := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+			TraceID:    oteltrace.TraceID(sc.TraceID),
+			SpanID:     oteltrace.SpanID(sc.SpanID),
+			TraceFlags: oteltrace.FlagsSampled,
+		})
+		assert.False(t, o.IsRemote())
+
+		got, ok := FromOTel(o)
+		assert.True(t, ok)
+		assert.Equal(t, sc, got)
+		assert.True(t, ToOTel(got).IsRemote())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, ok := FromOTel(oteltrace.SpanContext{})
+		assert.False(t, ok)
+	})
+}
+
+func TestPropagator(t *testing.T) {
+	_, sc := trace.Start(context.Background())
+	c := context.Background()
+
+	_, ok := Propagator{}.Extract(c)
+	assert.False(t, ok)
+
+	c = Propagator{}.Inject(c, sc)
+	assert.True(t, oteltrace.SpanContextFromContext(c).IsRemote())
+
+	got, ok := Propagator{}.Extract(c)
+	assert.True(t, ok)
+	assert.Equal(t, sc, got)
+}
@@ -0,0 +1,185 @@
+// Package trace propagates distributed tracing context between casm hosts.
+//
+// The package does not record or export spans.  It only defines the identity
+// of a span (SpanContext), its wire encoding, and a Propagator that moves it
+// in and out of a context.Context.  Host.Open sends the caller's SpanContext
+// along with the stream's path, and the remote host injects it into the
+// context of the handler's stream, so that spans started by the handler are
+// children of the caller's span.
+//
+// By default, SpanContexts are stored in the context by ContextWithSpan.
+// Applications that use a tracing library should install an adapter with
+// SetPropagator; see package otel for OpenTelemetry.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Size of an encoded SpanContext, in bytes.
+const Size = 16 + 8 + 1
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid returns false if the ID is zero.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid returns false if the ID is zero.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// Flags of a span.
+type Flags uint8
+
+// FlagSampled indicates that the trace is being recorded.
+const FlagSampled Flags = 1
+
+// SpanContext identifies a span.  The zero value is invalid, and indicates
+// the absence of a span.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   Flags
+}
+
+// IsValid returns true if both the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Sampled returns true if FlagSampled is set.
+func (sc SpanContext) Sampled() bool { return sc.Flags&FlagSampled != 0 }
+
+// MarshalBinary encodes the SpanContext as Size bytes: the trace ID, the span
+// ID and the flags.  An invalid SpanContext is encoded as zeros.
+func (sc SpanContext) MarshalBinary() ([]byte, error) {
+	b := make([]byte, Size)
+	if sc.IsValid() {
+		copy(b, sc.TraceID[:])
+		copy(b[16:], sc.SpanID[:])
+		b[24] = byte(sc.Flags)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a SpanContext encoded by MarshalBinary.
+func (sc *SpanContext) UnmarshalBinary(b []byte) error {
+	if len(b) != Size {
+		return errors.Errorf("span context must be %d bytes, got %d", Size, len(b))
+	}
+
+	copy(sc.TraceID[:], b)
+	copy(sc.SpanID[:], b[16:])
+	sc.Flags = Flags(b[24])
+
+	if !sc.IsValid() {
+		*sc = SpanContext{}
+	}
+
+	return nil
+}
+
+// SendTo a specified writer.
+func (sc SpanContext) SendTo(w io.Writer) error {
+	b, _ := sc.MarshalBinary()
+	_, err := w.Write(b)
+	return err
+}
+
+// RecvFrom a specified reader.
+func (sc *SpanContext) RecvFrom(r io.Reader) error {
+	b := make([]byte, Size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	return sc.UnmarshalBinary(b)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of c that carries the SpanContext.
+func ContextWithSpan(c context.Context, sc SpanContext) context.Context {
+	return context.WithValue(c, spanKey{}, sc)
+}
+
+// SpanFromContext returns the SpanContext stored by ContextWithSpan.
+func SpanFromContext(c context.Context) (sc SpanContext, ok bool) {
+	sc, ok = c.Value(spanKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Start a span that is a child of the span in c, or the root of a new trace if
+// c has none.  The span is stored in the returned context with
+// ContextWithSpan.  It is intended for applications that propagate spans
+// without a tracing library.
+func Start(c context.Context) (context.Context, SpanContext) {
+	sc, ok := SpanFromContext(c)
+	if !ok {
+		rand.Read(sc.TraceID[:])
+		sc.Flags = FlagSampled
+	}
+
+	rand.Read(sc.SpanID[:])
+	return ContextWithSpan(c, sc), sc
+}
+
+// Propagator moves SpanContexts in and out of a context.Context.
+type Propagator interface {
+	// Extract the current span from c.
+	Extract(c context.Context) (SpanContext, bool)
+	// Inject a remote span into c, such that spans started from the returned
+	// context are its children.
+	Inject(c context.Context, sc SpanContext) context.Context
+}
+
+// ContextPropagator stores SpanContexts with ContextWithSpan.  It is the
+// default Propagator.
+type ContextPropagator struct{}
+
+// Extract satisfies Propagator.
+func (ContextPropagator) Extract(c context.Context) (SpanContext, bool) { return SpanFromContext(c) }
+
+// Inject satisfies Propagator.
+func (ContextPropagator) Inject(c context.Context, sc SpanContext) context.Context {
+	return ContextWithSpan(c, sc)
+}
+
+type propagatorBox struct{ Propagator }
+
+var global atomic.Value
+
+func init() { SetPropagator(nil) }
+
+// SetPropagator installs the Propagator used by casm packages.  Passing nil
+// restores the default, ContextPropagator.
+func SetPropagator(p Propagator) {
+	if p == nil {
+		p = ContextPropagator{}
+	}
+	global.Store(propagatorBox{p})
+}
+
+// Extract the current span from c, using the installed Propagator.
+func Extract(c context.Context) (SpanContext, bool) {
+	return global.Load().(propagatorBox).Extract(c)
+}
+
+// Inject a remote span into c, using the installed Propagator.  It is a no-op
+// if sc is invalid.
+func Inject(c context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return c
+	}
+	return global.Load().(propagatorBox).Inject(c, sc)
+}
@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanContext(t *testing.T) {
+	_, sc := Start(context.Background())
+	assert.True(t, sc.IsValid())
+	assert.True(t, sc.Sampled())
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, sc.SendTo(&buf))
+		assert.Equal(t, Size, buf.Len())
+
+		var got SpanContext
+		assert.NoError(t, got.RecvFrom(&buf))
+		assert.Equal(t, sc, got)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		b, err := SpanContext{SpanID: sc.SpanID, Flags: FlagSampled}.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, make([]byte, Size), b, "invalid span should encode as zeros")
+
+		var got SpanContext
+		assert.NoError(t, got.UnmarshalBinary(b))
+		assert.False(t, got.IsValid())
+
+		assert.Error(t, got.UnmarshalBinary(b[1:]))
+	})
+}
+
+func TestStart(t *testing.T) {
+	c, root := Start(context.Background())
+	_, child := Start(c)
+
+	assert.Equal(t, root.TraceID, child.TraceID, "child should inherit trace")
+	assert.NotEqual(t, root.SpanID, child.SpanID)
+}
+
+type stubPropagator struct{ sc SpanContext }
+
+func (p stubPropagator) Extract(context.Context) (SpanContext, bool) { return p.sc, true }
+
+func (p stubPropagator) Inject(c context.Context, sc SpanContext) context.Context {
+	return context.WithValue(c, p, sc)
+}
+
+func TestPropagator(t *testing.T) {
+	_, sc := Start(context.Background())
+	c := context.Background()
+
+	t.Run("Default", func(t *testing.T) {
+		_, ok := Extract(c)
+		assert.False(t, ok)
+
+		got, ok := Extract(Inject(c, sc))
+		assert.True(t, ok)
+		assert.Equal(t, sc, got)
+
+		assert.Equal(t, c, Inject(c, SpanContext{}), "invalid span should not be injected")
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		p := stubPropagator{sc: sc}
+		SetPropagator(p)
+		defer SetPropagator(nil)
+
+		got, ok := Extract(c)
+		assert.True(t, ok)
+		assert.Equal(t, sc, got)
+		assert.Equal(t, sc, Inject(c, sc).Value(p))
+	})
+}